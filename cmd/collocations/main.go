@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/config"
+	"goreddit/internal/storage"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// maxPages bounds how many pages of stored posts are read
+const maxPages = 1000
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: collocations [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Finds pairs of words that stored posts use together more often than\n")
+	fmt.Fprintf(os.Stderr, "chance, like \"student loan\", and writes them one per line for\n")
+	fmt.Fprintf(os.Stderr, "topics.phrase_files, so topics keep them whole.\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	since := flag.Duration("since", 30*24*time.Hour, "read posts created in this window")
+	lang := flag.String("lang", analysis.DefaultLanguage, "language of the posts to read")
+	minCount := flag.Int("min-count", 0, "times a pair must occur (default 5)")
+	minPMI := flag.Float64("min-pmi", 0, "bits of pointwise mutual information a pair needs (default 3)")
+	limit := flag.Int("limit", 0, "most pairs to write (default 200)")
+	out := flag.String("out", "", "file to write; standard output if empty")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	extractor, err := analysis.NewTopicExtractor(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create topic extractor: %v", err)
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Titles and bodies are separate texts, so pairs don't span them
+	var texts []string
+	filter := storage.PostFilter{Since: time.Now().Add(-*since), Languages: []string{*lang}, Limit: 500}
+	posts := 0
+	for page := 0; page < maxPages; page++ {
+		result, err := store.QueryPosts(context.Background(), filter)
+		if err != nil {
+			log.Fatalf("Failed to read posts: %v", err)
+		}
+		for _, post := range result.Posts {
+			texts = append(texts, post.Title, post.Body)
+		}
+		posts += len(result.Posts)
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+
+	found := extractor.Collocations(texts, *lang, analysis.CollocationOptions{MinCount: *minCount, MinPMI: *minPMI, Limit: *limit})
+	log.Printf("Found %d collocations in %d posts", len(found), posts)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "# Collocations of %d %s posts from the %s before %s, by cmd/collocations\n",
+		posts, *lang, *since, time.Now().UTC().Format(time.RFC3339))
+	for _, c := range found {
+		fmt.Fprintf(buf, "%s\n", c.Phrase)
+		log.Printf("%-30s %6d %5.1f", c.Phrase, c.Count, c.PMI)
+	}
+	if err := buf.Flush(); err != nil {
+		log.Fatalf("Failed to write collocations: %v", err)
+	}
+}
@@ -21,13 +21,18 @@ func main() {
 	// Use a unique group ID for standalone consumer
 	cfg.Kafka.GroupID = cfg.Kafka.GroupID + "-standalone"
 
-	// Create PostgreSQL store
-	store, err := storage.NewPostgresStore(cfg)
+	// Create the configured store
+	store, err := storage.New(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
 
+	retention, err := storage.RetentionPolicyFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid retention config: %v", err)
+	}
+
 	// Create Kafka consumer
 	consumer, err := kafka.NewConsumer(cfg, store)
 	if err != nil {
@@ -39,6 +44,9 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Create upcoming partitions and expire old posts in the background
+	go storage.RunMaintenance(ctx, store, retention)
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"goreddit/internal/config"
+	"goreddit/internal/storage"
+	"log"
+	"os"
+	"strconv"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: migrate <command>\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  up        apply all pending migrations\n")
+	fmt.Fprintf(os.Stderr, "  down [n]  roll back the last n migrations (default 1)\n")
+	fmt.Fprintf(os.Stderr, "  version   print the current schema version\n")
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := storage.NewMigrator(db, cfg.Storage.Driver)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", applied)
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid step count %q", flag.Arg(1))
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		log.Printf("Rolled back %d migration(s)", rolledBack)
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read version: %v", err)
+		}
+		fmt.Println(version)
+
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"goreddit/internal/config"
+	"goreddit/internal/reenrich"
+	"goreddit/internal/storage"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: reenrich [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Runs the analyzers of the current config over stored posts again and\n")
+	fmt.Fprintf(os.Stderr, "updates the posts and rollups whose analyses changed. Posts already\n")
+	fmt.Fprintf(os.Stderr, "enriched by the current analyzer versions are skipped unless -all is set.\n")
+	fmt.Fprintf(os.Stderr, "Progress is checkpointed after every batch; run again to resume.\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without saving")
+	all := flag.Bool("all", false, "also enrich posts already enriched by the current analyzer versions")
+	batch := flag.Int("batch", 200, "posts per batch and between checkpoints")
+	checkpoint := flag.String("checkpoint", "reenrich.checkpoint.json", "file to keep progress in; empty disables resuming")
+	subreddits := flag.String("subreddits", "", "comma-separated subreddits to limit the run to")
+	since := flag.Duration("since", 0, "limit the run to posts created in this window, e.g. 168h")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	opts := reenrich.Options{DryRun: *dryRun, All: *all, BatchSize: *batch, Checkpoint: *checkpoint}
+	if *subreddits != "" {
+		opts.Filter.Subreddits = strings.Split(*subreddits, ",")
+	}
+	if *since > 0 {
+		opts.Filter.Since = time.Now().Add(-*since)
+	}
+	job, err := reenrich.NewJob(cfg, store, opts)
+	if err != nil {
+		log.Fatalf("Failed to create job: %v", err)
+	}
+
+	// Stop at the next post on SIGINT or SIGTERM; the checkpoint lets a later
+	// run resume
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Enriching posts with %s", job.Versions())
+	stats, err := job.Run(ctx)
+	report(stats, *dryRun)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Interrupted; run again to resume from %s", *checkpoint)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Re-enrichment failed: %v", err)
+	}
+}
+
+func report(stats reenrich.Stats, dryRun bool) {
+	verb := "saved"
+	if dryRun {
+		verb = "would save"
+	}
+	fmt.Printf("scanned %d posts: %d current, %d changed, %s %d\n", stats.Scanned, stats.Current, stats.Changed, verb, stats.Saved)
+	fmt.Printf("  sentiment %d, topics %d, entities %d, summaries %d, languages %d, domains %d, emotions %d, toxic %d\n",
+		stats.Sentiment, stats.Topics, stats.Entities, stats.Summaries, stats.Languages, stats.Domains, stats.Emotions, stats.Toxic)
+	for _, change := range stats.LabelChanges() {
+		fmt.Printf("  sentiment label %s\n", change)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: toxicity <command> [flags] <labeled.csv>\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  train  fit a model to the examples and write it to -out\n")
+	fmt.Fprintf(os.Stderr, "  eval   report how a model (-model, or the embedded one) scores the examples\n\n")
+	fmt.Fprintf(os.Stderr, "The CSV needs a header with a text (or comment_text) column and a label\n")
+	fmt.Fprintf(os.Stderr, "(or toxic) column holding 0/1, true/false or a fraction of raters.\n")
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "train":
+		train(flag.Args()[1:])
+	case "eval":
+		eval(flag.Args()[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func train(args []string) {
+	flags := flag.NewFlagSet("train", flag.ExitOnError)
+	out := flags.String("out", "toxicity_model.json", "path of the model file to write")
+	version := flags.String("version", time.Now().UTC().Format("20060102-150405"), "version recorded in the model")
+	holdout := flags.Float64("holdout", 0.2, "share of examples held out to evaluate on before training on all of them")
+	epochs := flags.Int("epochs", 0, "passes over the examples (default 500)")
+	minCount := flags.Int("min-count", 0, "examples a word must appear in to get a weight (default 2)")
+	flags.Parse(args)
+	examples := readExamples(flags)
+
+	opts := analysis.ToxicityTrainOptions{Version: *version, Epochs: *epochs, MinCount: *minCount}
+	if *holdout > 0 && *holdout < 1 {
+		// A fixed seed keeps reports comparable between runs on the same file
+		shuffled := append([]analysis.ToxicityExample(nil), examples...)
+		rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		split := len(shuffled) - int(float64(len(shuffled))**holdout)
+		model, err := analysis.TrainToxicityModel(shuffled[:split], opts)
+		if err != nil {
+			log.Fatalf("Training failed: %v", err)
+		}
+		log.Printf("Held out %d of %d examples: %s", len(shuffled)-split, len(shuffled), evaluate(model, shuffled[split:]))
+	}
+
+	model, err := analysis.TrainToxicityModel(examples, opts)
+	if err != nil {
+		log.Fatalf("Training failed: %v", err)
+	}
+	log.Printf("Training set: %s", evaluate(model, examples))
+	if err := analysis.SaveToxicityModel(*out, model); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote model %s with %d weights to %s", model.Version, len(model.Weights), *out)
+}
+
+func eval(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	path := flags.String("model", "", "model file to evaluate (default the embedded model)")
+	flags.Parse(args)
+	examples := readExamples(flags)
+
+	model, err := analysis.DefaultToxicityModel()
+	if *path != "" {
+		model, err = analysis.LoadToxicityModel(*path)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("model %s: %s\n", model.Version, evaluate(model, examples))
+}
+
+// readExamples reads the CSV named by the command's only argument
+func readExamples(flags *flag.FlagSet) []analysis.ToxicityExample {
+	if flags.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open examples: %v", err)
+	}
+	defer file.Close()
+
+	examples, err := analysis.ReadToxicityCSV(file)
+	if err != nil {
+		log.Fatalf("Failed to read examples: %v", err)
+	}
+	if len(examples) == 0 {
+		log.Fatalf("No examples in %s", flags.Arg(0))
+	}
+	return examples
+}
+
+func evaluate(model analysis.ToxicityModel, examples []analysis.ToxicityExample) analysis.BinaryMetrics {
+	scorer, err := analysis.NewToxicityScorer(model)
+	if err != nil {
+		log.Fatalf("Failed to create scorer: %v", err)
+	}
+	return scorer.Evaluate(examples, reddit.ToxicThreshold)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goreddit/internal/analysis"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: train-sentiment [flags] <labeled.csv|labeled.ndjson>\n\n")
+	fmt.Fprintf(os.Stderr, "Cross-validates a naive Bayes sentiment model on labeled posts, reports\n")
+	fmt.Fprintf(os.Stderr, "precision, recall and F1 per label, then trains on all of them and writes\n")
+	fmt.Fprintf(os.Stderr, "the model to -out. Point sentiment.model in the config at the file to use it.\n\n")
+	fmt.Fprintf(os.Stderr, "Posts need a text (or a title and optional body) and a label (or sentiment)\n")
+	fmt.Fprintf(os.Stderr, "of positive, neutral or negative. CSV files need a header row; NDJSON has\n")
+	fmt.Fprintf(os.Stderr, "one JSON object per line.\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	out := flag.String("out", "sentiment_model.json", "path of the model file to write")
+	version := flag.String("version", time.Now().UTC().Format("20060102-150405"), "version recorded in the model and on the posts it scores")
+	folds := flag.Int("folds", 5, "cross-validation folds; 0 skips cross-validation")
+	format := flag.String("format", "", "csv or ndjson (default from the file extension)")
+	threshold := flag.Float64("threshold", 0.1, "scores closer to 0 than this are neutral, as sentiment.neutral_threshold")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	examples := readExamples(path, *format)
+
+	if *folds > 0 {
+		report, err := analysis.CrossValidateBayes(examples, *folds, *threshold)
+		if err != nil {
+			log.Fatalf("Cross-validation failed: %v", err)
+		}
+		log.Printf("Cross-validated over %d folds:", *folds)
+		printReport(report)
+	}
+
+	model, err := analysis.TrainBayesModel(examples, *version)
+	if err != nil {
+		log.Fatalf("Training failed: %v", err)
+	}
+	analyzer, err := model.Analyzer(*threshold)
+	if err != nil {
+		log.Fatalf("Training failed: %v", err)
+	}
+	log.Printf("Training set:")
+	printReport(analysis.EvaluateSentiment(analyzer, examples))
+	if err := analysis.SaveBayesModel(*out, model); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote model %s trained on %d positive and negative examples to %s", model.Version, model.Examples, *out)
+}
+
+func readExamples(path, format string) []analysis.SentimentExample {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open examples: %v", err)
+	}
+	defer file.Close()
+
+	examples, err := analysis.ReadSentimentExamples(file, format)
+	if err != nil {
+		log.Fatalf("Failed to read examples: %v", err)
+	}
+	if len(examples) == 0 {
+		log.Fatalf("No examples in %s", path)
+	}
+	return examples
+}
+
+func printReport(report analysis.SentimentReport) {
+	for _, label := range []analysis.Label{analysis.Positive, analysis.Neutral, analysis.Negative} {
+		metrics := report.Classes[label]
+		fmt.Printf("  %-8s precision=%.3f recall=%.3f f1=%.3f (%d labeled)\n",
+			label, metrics.Precision(), metrics.Recall(), metrics.F1(), metrics.TruePositives+metrics.FalseNegatives)
+	}
+	fmt.Printf("  accuracy=%.3f macro-f1=%.3f n=%d\n", report.Accuracy(), report.MacroF1(), report.Total)
+}
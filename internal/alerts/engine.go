@@ -0,0 +1,339 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReloadInterval = 30 * time.Second
+	defaultTimeout        = 10 * time.Second
+	defaultRetries        = 3
+	retryBackoff          = time.Second // doubled after each failed attempt
+	deliveryQueueSize     = 100
+)
+
+// RuleStore keeps the rules created through the API and settles which
+// consumer fires an alert
+type RuleStore interface {
+	ListAlertRules(ctx context.Context) ([]storage.AlertRule, error)
+	ClaimAlert(ctx context.Context, claim storage.AlertClaim) (bool, error)
+}
+
+// RulesFromConfig reads and validates the rules in the alerts section
+func RulesFromConfig(cfg *config.Config) ([]Rule, error) {
+	rules := make([]Rule, 0, len(cfg.Alerts.Rules))
+	seen := make(map[string]bool)
+	for i, raw := range cfg.Alerts.Rules {
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %d: %w", i+1, err)
+		}
+		rule, err := ParseRule(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %d: %w", i+1, err)
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate alert rule id %q", rule.ID)
+		}
+		seen[rule.ID] = true
+		rule.Source = SourceConfig
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadRules returns the config rules together with the stored ones, by ID.
+// A stored rule that no longer parses is logged and skipped, and config
+// rules take precedence over stored rules with the same ID.
+func LoadRules(ctx context.Context, configRules []Rule, store RuleStore) ([]Rule, error) {
+	stored, err := store.ListAlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := append([]Rule(nil), configRules...)
+	seen := make(map[string]bool, len(configRules))
+	for _, rule := range configRules {
+		seen[rule.ID] = true
+	}
+	for _, record := range stored {
+		if seen[record.ID] {
+			continue
+		}
+		rule, err := ParseRule(record.Definition)
+		if err != nil {
+			log.Printf("Skipping stored alert rule %s: %v", record.ID, err)
+			continue
+		}
+		rule.Source = SourceAPI
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// Alert is the JSON body posted to a rule's webhooks
+type Alert struct {
+	// Text sums the alert up in a line, which is what chat webhooks show
+	Text    string      `json:"text"`
+	RuleID  string      `json:"rule_id"`
+	Rule    string      `json:"rule,omitempty"`
+	FiredAt time.Time   `json:"fired_at"`
+	Post    reddit.Post `json:"post"`
+}
+
+type delivery struct {
+	url   string
+	alert Alert
+	// public limits the delivery to public addresses
+	public bool
+}
+
+// Engine evaluates alert rules against enriched posts and delivers the
+// alerts to webhooks in the background, retrying failed deliveries with
+// backoff. Rules created through the API are reloaded periodically. Dedup
+// windows and hourly caps are settled through the store, so several
+// consumers sharing a database alert once between them.
+type Engine struct {
+	store       RuleStore
+	configRules []Rule
+	client      *http.Client
+	// publicClient delivers the alerts of rules created through the API,
+	// unless alerts.allow_private_webhooks is set
+	publicClient *http.Client
+	retries      int
+	backoff      time.Duration
+	reloadEvery  time.Duration
+
+	mutex sync.RWMutex
+	rules []Rule
+
+	queue  chan delivery
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewEngine validates the config rules and loads the stored ones
+func NewEngine(cfg *config.Config, store RuleStore) (*Engine, error) {
+	configRules, err := RulesFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		store:       store,
+		configRules: configRules,
+		client:      &http.Client{Timeout: cfg.Alerts.Timeout},
+		retries:     defaultRetries,
+		backoff:     retryBackoff,
+		reloadEvery: cfg.Alerts.ReloadInterval,
+		rules:       configRules,
+		queue:       make(chan delivery, deliveryQueueSize),
+	}
+	if e.client.Timeout <= 0 {
+		e.client.Timeout = defaultTimeout
+	}
+	e.publicClient = e.client
+	if !cfg.Alerts.AllowPrivateWebhooks {
+		e.publicClient = newPublicClient(e.client.Timeout)
+	}
+	// 0 disables retries, so only an unset or negative count takes the default
+	if retries := cfg.Alerts.Retries; retries != nil && *retries >= 0 {
+		e.retries = *retries
+	}
+	if e.reloadEvery <= 0 {
+		e.reloadEvery = defaultReloadInterval
+	}
+
+	if err := e.Reload(context.Background()); err != nil {
+		log.Printf("Failed to load alert rules from storage: %v", err)
+	}
+	return e, nil
+}
+
+// Start runs the delivery worker and the rule reloader until Close
+func (e *Engine) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	e.wg.Add(2)
+	go func() {
+		defer e.wg.Done()
+		e.deliverAll(ctx)
+	}()
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.reloadEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := e.Reload(ctx); err != nil {
+					log.Printf("Failed to reload alert rules: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the engine, dropping alerts not yet delivered
+func (e *Engine) Close() {
+	if e.cancel != nil {
+		e.cancel()
+		e.wg.Wait()
+	}
+}
+
+// Reload replaces the rules with the config rules and the stored ones
+func (e *Engine) Reload(ctx context.Context) error {
+	rules, err := LoadRules(ctx, e.configRules, e.store)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.rules = rules
+	e.mutex.Unlock()
+	return nil
+}
+
+// Evaluate queues an alert for every rule the post matches that isn't held
+// back by its dedup window or hourly cap, and returns the IDs of those rules
+func (e *Engine) Evaluate(ctx context.Context, post reddit.Post, now time.Time) []string {
+	e.mutex.RLock()
+	rules := e.rules
+	e.mutex.RUnlock()
+
+	key := post.StoryID
+	if key == "" {
+		key = post.ID
+	}
+
+	var fired []string
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(&post) {
+			continue
+		}
+		claimed, err := e.store.ClaimAlert(ctx, storage.AlertClaim{
+			RuleID:     rule.ID,
+			Key:        key,
+			Dedup:      time.Duration(rule.Dedup),
+			MaxPerHour: rule.MaxPerHour,
+			At:         now,
+		})
+		if err != nil {
+			log.Printf("Failed to claim alert %s for post %s: %v", rule.ID, post.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		fired = append(fired, rule.ID)
+		alert := Alert{
+			Text:    fmt.Sprintf("[%s] r/%s: %s %s", ruleLabel(rule), post.Subreddit, post.Title, post.URL),
+			RuleID:  rule.ID,
+			Rule:    rule.Name,
+			FiredAt: now.UTC(),
+			Post:    post,
+		}
+		for _, hook := range rule.Webhooks {
+			select {
+			case e.queue <- delivery{url: hook, alert: alert, public: rule.Source == SourceAPI}:
+			default:
+				log.Printf("Alert queue full, dropping alert %s for post %s to %s", rule.ID, post.ID, hook)
+			}
+		}
+	}
+	return fired
+}
+
+func ruleLabel(rule *Rule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return rule.ID
+}
+
+// deliverAll sends queued alerts one at a time until ctx is done
+func (e *Engine) deliverAll(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-e.queue:
+			if err := e.deliver(ctx, d); err != nil {
+				log.Printf("Failed to deliver alert %s for post %s to %s: %v", d.alert.RuleID, d.alert.Post.ID, d.url, err)
+			}
+		}
+	}
+}
+
+// deliver posts an alert to a webhook, retrying network errors, rate limits
+// and server errors with exponential backoff
+func (e *Engine) deliver(ctx context.Context, d delivery) error {
+	body, err := json.Marshal(d.alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	backoff := e.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(ctx, d, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= e.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying
+func (e *Engine) post(ctx context.Context, d delivery, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goreddit-alerts")
+
+	client := e.client
+	if d.public {
+		client = e.publicClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
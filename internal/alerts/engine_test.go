@@ -0,0 +1,182 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngine(t *testing.T) {
+	// The webhook fails once, then takes alerts
+	var requests atomic.Int32
+	received := make(chan Alert, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var alert Alert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+		received <- alert
+	}))
+	defer hook.Close()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = storage.DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+	cfg.Alerts.Rules = []map[string]interface{}{{
+		"id":       "quakes",
+		"when":     map[string]interface{}{"keyword": "earthquake"},
+		"webhooks": []interface{}{hook.URL},
+	}}
+	// The test webhook listens on loopback
+	cfg.Alerts.AllowPrivateWebhooks = true
+	store, err := storage.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A rule created through the API
+	if err := store.SaveAlertRule(context.Background(), storage.AlertRule{
+		ID:         "japan",
+		Definition: []byte(`{"id": "japan", "when": {"entity": "Japan"}, "webhooks": ["` + hook.URL + `"]}`),
+	}); err != nil {
+		t.Fatalf("Failed to save rule: %v", err)
+	}
+
+	e, err := NewEngine(cfg, store)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	e.backoff = time.Millisecond
+	e.Start()
+	defer e.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	post := reddit.Post{ID: "a", StoryID: "a", Title: "Earthquake in Japan", Subreddit: "worldnews",
+		Entities: []reddit.Entity{{Name: "Japan", Type: reddit.EntityPlace}}}
+	if fired := e.Evaluate(ctx, post, now); !reflect.DeepEqual(fired, []string{"japan", "quakes"}) {
+		t.Fatalf("Expected both rules to fire, got %v", fired)
+	}
+
+	// A cross-post of the same story is held back by the dedup window
+	crossPost := reddit.Post{ID: "b", StoryID: "a", Title: "Earthquake hits", Subreddit: "news"}
+	if fired := e.Evaluate(ctx, crossPost, now.Add(time.Minute)); len(fired) != 0 {
+		t.Errorf("Expected the cross-post not to fire, got %v", fired)
+	}
+	if fired := e.Evaluate(ctx, reddit.Post{ID: "c", Title: "Budget passes"}, now); len(fired) != 0 {
+		t.Errorf("Expected an unrelated post not to fire, got %v", fired)
+	}
+
+	rules := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case alert := <-received:
+			rules[alert.RuleID] = true
+			if alert.Post.ID != "a" || alert.Text == "" {
+				t.Errorf("Unexpected alert: %+v", alert)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for alerts, got %v", rules)
+		}
+	}
+	if !rules["japan"] || !rules["quakes"] {
+		t.Errorf("Expected alerts from both rules, got %v", rules)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected one retried and one direct delivery, got %d requests", n)
+	}
+}
+
+func TestEngineRetries(t *testing.T) {
+	var requests atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer hook.Close()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = storage.DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	count := func(n int) *int { return &n }
+	for _, tt := range []struct {
+		retries *int
+		want    int
+	}{
+		{nil, defaultRetries},
+		{count(-1), defaultRetries},
+		{count(0), 0},
+		{count(2), 2},
+	} {
+		cfg.Alerts.Retries = tt.retries
+		e, err := NewEngine(cfg, store)
+		if err != nil {
+			t.Fatalf("Failed to create engine: %v", err)
+		}
+		e.backoff = time.Millisecond
+
+		requests.Store(0)
+		if err := e.deliver(context.Background(), delivery{url: hook.URL}); err == nil {
+			t.Error("Expected a failed delivery")
+		}
+		if n := requests.Load(); int(n) != tt.want+1 {
+			t.Errorf("Expected %d retries, got %d requests", tt.want, n)
+		}
+	}
+}
+
+func TestEngineKeepsAPIRulesOffInternalAddresses(t *testing.T) {
+	var requests atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer hook.Close()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = storage.DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+	retries := 0
+	cfg.Alerts.Retries = &retries
+	store, err := storage.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	e, err := NewEngine(cfg, store)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// A rule from the config may post to loopback, one from the API may not
+	ctx := context.Background()
+	if err := e.deliver(ctx, delivery{url: hook.URL}); err != nil {
+		t.Errorf("Expected a config rule's delivery to go through, got %v", err)
+	}
+	if err := e.deliver(ctx, delivery{url: hook.URL, public: true}); err == nil || !strings.Contains(err.Error(), "isn't public") {
+		t.Errorf("Expected an API rule's delivery to loopback to be refused, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected one request, got %d", n)
+	}
+}
@@ -0,0 +1,265 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/reddit"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDedup = time.Hour
+	maxDedup     = 7 * 24 * time.Hour // as long as storage remembers fired alerts
+)
+
+// Rule sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+var ruleIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Rule sends a post to webhooks when it meets a condition
+type Rule struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Disabled bool      `json:"disabled,omitempty"`
+	When     Condition `json:"when"`
+	Webhooks []string  `json:"webhooks"`
+	// Dedup is how long the rule stays quiet about a story after alerting on
+	// it, so cross-posts alert once; defaults to an hour
+	Dedup Duration `json:"dedup,omitempty"`
+	// MaxPerHour caps how many alerts the rule sends an hour; 0 is no cap
+	MaxPerHour int `json:"max_per_hour,omitempty"`
+	// Source says where the rule is defined, "config" or "api". It's set when
+	// rules are loaded and ignored in definitions.
+	Source string `json:"source,omitempty"`
+}
+
+// Condition is a test of a post. Each field that is set must hold; All, Any
+// and Not combine further conditions.
+type Condition struct {
+	All []Condition `json:"all,omitempty"`
+	Any []Condition `json:"any,omitempty"`
+	Not *Condition  `json:"not,omitempty"`
+
+	// Keyword is a word or phrase in the title or body, in any case
+	Keyword string `json:"keyword,omitempty"`
+	// Regex is a regular expression matched against the title and body
+	Regex string `json:"regex,omitempty"`
+	// Entity is a normalized entity name, like "United States", in any case
+	Entity       string   `json:"entity,omitempty"`
+	Topic        string   `json:"topic,omitempty"`
+	Subreddit    string   `json:"subreddit,omitempty"`
+	MinScore     *int32   `json:"min_score,omitempty"`
+	MinSentiment *float64 `json:"min_sentiment,omitempty"`
+	MaxSentiment *float64 `json:"max_sentiment,omitempty"`
+	// MinToxicity is the lowest toxicity, from 0 to 1, that matches
+	MinToxicity *float64 `json:"min_toxicity,omitempty"`
+
+	keyword, regex *regexp.Regexp
+}
+
+// Duration is a time.Duration written as a string, like "30m", in JSON
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: expected a string like \"30m\"", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ParseRule reads a rule from its JSON definition and validates it
+func ParseRule(data []byte) (Rule, error) {
+	var rule Rule
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rule); err != nil {
+		return Rule{}, fmt.Errorf("invalid rule: %w", err)
+	}
+	rule.Source = ""
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Validate checks a rule, applies defaults and compiles its patterns. A rule
+// must be validated before it's matched.
+func (r *Rule) Validate() error {
+	if !ruleIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("invalid rule id %q: use 1 to 64 letters, digits, '-' or '_'", r.ID)
+	}
+	if len(r.Webhooks) == 0 {
+		return fmt.Errorf("rule %s has no webhooks", r.ID)
+	}
+	for _, hook := range r.Webhooks {
+		u, err := url.Parse(hook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("rule %s has an invalid webhook URL %q", r.ID, hook)
+		}
+	}
+	if r.Dedup < 0 || time.Duration(r.Dedup) > maxDedup {
+		return fmt.Errorf("rule %s: dedup must be between 0 and %s", r.ID, maxDedup)
+	}
+	if r.Dedup == 0 {
+		r.Dedup = Duration(defaultDedup)
+	}
+	if r.MaxPerHour < 0 {
+		return fmt.Errorf("rule %s: max_per_hour can't be negative", r.ID)
+	}
+	if err := r.When.compile(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Matches reports whether a post meets the rule's condition
+func (r *Rule) Matches(post *reddit.Post) bool {
+	return !r.Disabled && r.When.matches(post, postText(post))
+}
+
+// compile checks the condition and compiles its patterns
+func (c *Condition) compile() error {
+	empty := true
+	for i := range c.All {
+		if err := c.All[i].compile(); err != nil {
+			return err
+		}
+		empty = false
+	}
+	for i := range c.Any {
+		if err := c.Any[i].compile(); err != nil {
+			return err
+		}
+		empty = false
+	}
+	if c.Not != nil {
+		if err := c.Not.compile(); err != nil {
+			return err
+		}
+		empty = false
+	}
+
+	if c.Keyword != "" {
+		words := strings.Fields(c.Keyword)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		// Whole words only, so "war" doesn't match "award"
+		c.keyword = regexp.MustCompile(`(?i)(?:^|[^\pL\pN])` + strings.Join(words, `\s+`) + `(?:$|[^\pL\pN])`)
+		empty = false
+	}
+	if c.Regex != "" {
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex %q: %w", c.Regex, err)
+		}
+		c.regex = re
+		empty = false
+	}
+	if c.Entity != "" || c.Topic != "" || c.Subreddit != "" ||
+		c.MinScore != nil || c.MinSentiment != nil || c.MaxSentiment != nil || c.MinToxicity != nil {
+		empty = false
+	}
+	if empty {
+		return errors.New("empty condition")
+	}
+	return nil
+}
+
+// matches reports whether every test set in the condition holds for post,
+// whose title and body are text
+func (c *Condition) matches(post *reddit.Post, text string) bool {
+	for i := range c.All {
+		if !c.All[i].matches(post, text) {
+			return false
+		}
+	}
+	if len(c.Any) > 0 {
+		matched := false
+		for i := range c.Any {
+			if c.Any[i].matches(post, text) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.Not != nil && c.Not.matches(post, text) {
+		return false
+	}
+
+	if c.keyword != nil && !c.keyword.MatchString(text) {
+		return false
+	}
+	if c.regex != nil && !c.regex.MatchString(text) {
+		return false
+	}
+	if c.Entity != "" && !hasEntity(post.Entities, c.Entity) {
+		return false
+	}
+	if c.Topic != "" && !containsFold(post.Topics, c.Topic) {
+		return false
+	}
+	if c.Subreddit != "" && !strings.EqualFold(post.Subreddit, c.Subreddit) {
+		return false
+	}
+	if c.MinScore != nil && post.Score < *c.MinScore {
+		return false
+	}
+	if c.MinSentiment != nil && post.Sentiment < *c.MinSentiment {
+		return false
+	}
+	if c.MaxSentiment != nil && post.Sentiment > *c.MaxSentiment {
+		return false
+	}
+	if c.MinToxicity != nil && post.Toxicity < *c.MinToxicity {
+		return false
+	}
+	return true
+}
+
+func postText(post *reddit.Post) string {
+	if post.Body == "" {
+		return post.Title
+	}
+	return post.Title + "\n" + post.Body
+}
+
+func hasEntity(entities []reddit.Entity, name string) bool {
+	for _, entity := range entities {
+		if strings.EqualFold(entity.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
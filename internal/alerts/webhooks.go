@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CheckPublicWebhooks rejects webhooks pointing at loopback, private,
+// link-local or otherwise internal hosts, so that rules created through the
+// API can't make the consumers reach into their own network. Hosts named by
+// DNS are checked again when they're dialed; see NewEngine.
+func (r *Rule) CheckPublicWebhooks() error {
+	for _, hook := range r.Webhooks {
+		u, err := url.Parse(hook)
+		if err != nil {
+			return fmt.Errorf("rule %s has an invalid webhook URL %q", r.ID, hook)
+		}
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+			return fmt.Errorf("rule %s has a webhook on internal host %q", r.ID, host)
+		}
+		if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+			return fmt.Errorf("rule %s has a webhook on internal address %s", r.ID, host)
+		}
+	}
+	return nil
+}
+
+// Redacted returns the rule with its webhooks cut down to their scheme and
+// host, since their paths and queries often carry secret tokens
+func (r Rule) Redacted() Rule {
+	hooks := make([]string, len(r.Webhooks))
+	for i, hook := range r.Webhooks {
+		hooks[i] = "redacted"
+		if u, err := url.Parse(hook); err == nil && u.Host != "" {
+			hooks[i] = u.Scheme + "://" + u.Host + "/redacted"
+		}
+	}
+	r.Webhooks = hooks
+	return r
+}
+
+// publicIP reports whether ip is routable on the internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// newPublicClient creates a webhook client that only connects to public
+// addresses, checked after DNS resolution so that a name can't resolve to
+// an internal one. It doesn't use a proxy, which would be dialed instead.
+func newPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("webhook address %s isn't public", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"fmt"
+	"goreddit/internal/config"
+)
+
+// Label is the overall polarity of a piece of text
+type Label string
+
+const (
+	Positive Label = "positive"
+	Neutral  Label = "neutral"
+	Negative Label = "negative"
+)
+
+// defaultNeutralThreshold is the score magnitude below which text is neutral
+const defaultNeutralThreshold = 0.1
+
+// Result is the sentiment of a piece of text
+type Result struct {
+	// Score runs from -1.0 (negative) to 1.0 (positive)
+	Score float64 `json:"score"`
+	Label Label   `json:"label"`
+	// Confidence is how sure the analyzer is of Label, from 0.0 to 1.0
+	Confidence float64 `json:"confidence"`
+}
+
+// Analyzer scores the sentiment of text. Implementations are safe for
+// concurrent use.
+type Analyzer interface {
+	// Name identifies the analyzer, e.g. in config
+	Name() string
+	// Version identifies the model the analyzer scores with, so scores can be
+	// traced to it
+	Version() string
+	// Supports reports whether the analyzer can score text in a language,
+	// given as an ISO 639-1 code
+	Supports(lang string) bool
+	Analyze(text string) Result
+}
+
+// New creates the analyzer selected by sentiment.analyzer in the config,
+// loading the model file named by sentiment.model if there is one
+func New(cfg *config.Config) (Analyzer, error) {
+	threshold := NeutralThreshold(cfg)
+
+	switch cfg.Sentiment.Analyzer {
+	case "", BayesName:
+		if cfg.Sentiment.Model != "" {
+			return LoadBayes(cfg.Sentiment.Model, threshold)
+		}
+		return NewBayes(threshold)
+	case LexiconName:
+		if cfg.Sentiment.Model != "" {
+			return nil, fmt.Errorf("sentiment.model only applies to the %s analyzer", BayesName)
+		}
+		return NewLexicon(threshold)
+	default:
+		return nil, fmt.Errorf("unknown sentiment analyzer %q", cfg.Sentiment.Analyzer)
+	}
+}
+
+// NeutralThreshold is sentiment.neutral_threshold, or its default
+func NeutralThreshold(cfg *config.Config) float64 {
+	if cfg.Sentiment.NeutralThreshold <= 0 {
+		return defaultNeutralThreshold
+	}
+	return cfg.Sentiment.NeutralThreshold
+}
+
+// LabelOf labels a stored score the way the analyzers label their results
+func LabelOf(score, threshold float64) Label {
+	return result(score, threshold).Label
+}
+
+// result labels a score, treating magnitudes below threshold as neutral.
+// Confidence is the model's certainty in the label it picked.
+func result(score, threshold float64) Result {
+	switch {
+	case score >= threshold:
+		return Result{Score: score, Label: Positive, Confidence: (1 + score) / 2}
+	case score <= -threshold:
+		return Result{Score: score, Label: Negative, Confidence: (1 - score) / 2}
+	default:
+		abs := score
+		if abs < 0 {
+			abs = -abs
+		}
+		return Result{Score: score, Label: Neutral, Confidence: 1 - abs}
+	}
+}
+
+// ModelID identifies an analyzer and its model version, like "bayes:imdb"
+func ModelID(a Analyzer) string {
+	return a.Name() + ":" + a.Version()
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"sync"
+	"testing"
+)
+
+func TestResultLabels(t *testing.T) {
+	tests := []struct {
+		score      float64
+		label      Label
+		confidence float64
+	}{
+		{1, Positive, 1},
+		{0.5, Positive, 0.75},
+		{0.05, Neutral, 0.95},
+		{0, Neutral, 1},
+		{-0.05, Neutral, 0.95},
+		{-0.5, Negative, 0.75},
+		{-1, Negative, 1},
+	}
+
+	for _, tt := range tests {
+		got := result(tt.score, 0.1)
+		if got.Score != tt.score || got.Label != tt.label || got.Confidence != tt.confidence {
+			t.Errorf("result(%v): expected %s/%v, got %+v", tt.score, tt.label, tt.confidence, got)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cfg := &config.Config{}
+	analyzer, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create default analyzer: %v", err)
+	}
+	if analyzer.Name() != BayesName {
+		t.Errorf("Expected the default analyzer to be %s, got %s", BayesName, analyzer.Name())
+	}
+
+	cfg.Sentiment.Analyzer = "unknown"
+	if _, err := New(cfg); err == nil {
+		t.Error("Expected an error for an unknown analyzer")
+	}
+}
+
+func TestBayesAnalyze(t *testing.T) {
+	bayes, err := NewBayes(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to create analyzer: %v", err)
+	}
+
+	tests := []struct {
+		text  string
+		label Label
+	}{
+		{"This is a wonderful, brilliant and amazing result", Positive},
+		{"Terrible awful disaster, the worst outcome", Negative},
+		{"", Neutral},
+		{"12345 !!!", Neutral},
+	}
+
+	for _, tt := range tests {
+		got := bayes.Analyze(tt.text)
+		if got.Label != tt.label {
+			t.Errorf("Analyze(%q): expected %s, got %+v", tt.text, tt.label, got)
+		}
+		if got.Score < -1 || got.Score > 1 || got.Confidence < 0 || got.Confidence > 1 {
+			t.Errorf("Analyze(%q): out of range %+v", tt.text, got)
+		}
+	}
+
+	// Long posts stay graded instead of saturating at ±1
+	long := ""
+	for i := 0; i < 200; i++ {
+		long += "a good film with a bad ending "
+	}
+	if got := bayes.Analyze(long); got.Score > 0.99 || got.Score < -0.99 {
+		t.Errorf("Expected a graded score for a long mixed post, got %+v", got)
+	}
+}
+
+func TestBayesConcurrentUse(t *testing.T) {
+	bayes, err := NewBayes(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to create analyzer: %v", err)
+	}
+	want := bayes.Analyze("an excellent and moving story")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := bayes.Analyze("an excellent and moving story"); got != want {
+					t.Errorf("Expected %+v, got %+v", want, got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,136 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/cdipaolo/goml/text"
+	"github.com/cdipaolo/sentiment"
+)
+
+// BayesName selects the Bayes analyzer in config
+const BayesName = "bayes"
+
+// bundledBayesVersion is the version of the model bundled with the package
+const bundledBayesVersion = "imdb"
+
+// maxEvidenceWords caps how many words add to the certainty of a score
+const maxEvidenceWords = 16
+
+// Bayes wraps a naive Bayes model from github.com/cdipaolo/sentiment: the
+// bundled one, which was trained on IMDB reviews, or one trained with
+// cmd/train-sentiment. The model is restored once; Analyze only reads it, so
+// one Bayes can be shared between goroutines.
+type Bayes struct {
+	model     *text.NaiveBayes
+	version   string
+	threshold float64
+	// wordTotals counts the words seen in each class, and vocabulary the
+	// distinct words, for the per-class word likelihoods
+	wordTotals [2]float64
+	vocabulary float64
+}
+
+// newBayes wraps a two-class model, counting its words once
+func newBayes(model *text.NaiveBayes, version string, neutralThreshold float64) (*Bayes, error) {
+	// The model only exposes its words one at a time, so they're read from
+	// its JSON form
+	data, err := json.Marshal(&model.Words)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sentiment model words: %w", err)
+	}
+	var words map[string]struct{ Count []uint64 }
+	if err := json.Unmarshal(data, &words); err != nil {
+		return nil, fmt.Errorf("failed to read sentiment model words: %w", err)
+	}
+	b := &Bayes{model: model, version: version, threshold: neutralThreshold, vocabulary: float64(len(words))}
+	for _, w := range words {
+		for class := 0; class < len(b.wordTotals) && class < len(w.Count); class++ {
+			b.wordTotals[class] += float64(w.Count[class])
+		}
+	}
+	return b, nil
+}
+
+// NewBayes restores the bundled English model
+func NewBayes(neutralThreshold float64) (*Bayes, error) {
+	models, err := sentiment.Restore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore sentiment model: %w", err)
+	}
+	model, ok := models[sentiment.English]
+	if !ok {
+		return nil, fmt.Errorf("sentiment model has no English classifier")
+	}
+	return newBayes(model, bundledBayesVersion, neutralThreshold)
+}
+
+// LoadBayes restores a model file written by SaveBayesModel
+func LoadBayes(path string, neutralThreshold float64) (*Bayes, error) {
+	model, err := LoadBayesModel(path)
+	if err != nil {
+		return nil, err
+	}
+	return model.Analyzer(neutralThreshold)
+}
+
+func (b *Bayes) Name() string {
+	return BayesName
+}
+
+// Version is "imdb" for the bundled model, or the version of a trained one
+func (b *Bayes) Version() string {
+	return b.version
+}
+
+// Supports reports whether lang is English, the only language the model knows
+func (b *Bayes) Supports(lang string) bool {
+	return lang == "en"
+}
+
+// Analyze grades text by the model's log odds of it being positive. The model's
+// own Predict and Probability reuse a transformer that isn't safe to share and
+// multiply raw probabilities, which underflows on long posts, so the log odds
+// are computed here instead. Each word's likelihood is smoothed and divided by
+// its class's word total, so a class with more training text doesn't claim
+// every word. Summed log odds grow with every known word, which would push any
+// long post to ±1, so the score uses the mean log odds per word weighted by the
+// square root of the word count, up to maxEvidenceWords, plus the class prior.
+func (b *Bayes) Analyze(input string) Result {
+	// Class 0 is negative and class 1 is positive
+	var logOdds float64
+	known := 0
+	for _, word := range strings.Split(bayesText(input), " ") {
+		w, ok := b.model.Words.Get(word)
+		if !ok {
+			continue
+		}
+		logOdds += math.Log((float64(w.Count[1])+1)/(b.wordTotals[1]+b.vocabulary)) -
+			math.Log((float64(w.Count[0])+1)/(b.wordTotals[0]+b.vocabulary))
+		known++
+	}
+	if known == 0 {
+		return result(0, b.threshold)
+	}
+
+	logOdds = logOdds / float64(known) * math.Sqrt(math.Min(float64(known), maxEvidenceWords))
+	logOdds += math.Log(b.model.Probabilities[1]) - math.Log(b.model.Probabilities[0])
+	return result(math.Tanh(logOdds/2), b.threshold)
+}
+
+// bayesText mirrors the sanitizer and tokenizer the models are trained with:
+// it keeps lowercased letters, with words separated by single spaces
+func bayesText(input string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || unicode.IsLetter(r) {
+			return unicode.ToLower(r)
+		}
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return -1
+	}, input)
+}
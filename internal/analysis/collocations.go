@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultCollocationMinCount = 5
+	defaultCollocationMinPMI   = 3
+	defaultCollocationLimit    = 200
+)
+
+// CollocationOptions tune Collocations
+type CollocationOptions struct {
+	// MinCount is how many times a pair must occur, 5 by default
+	MinCount int
+	// MinPMI is the pointwise mutual information, in bits, a pair needs:
+	// how much more often its words occur together than they would by
+	// chance. 3 by default.
+	MinPMI float64
+	// Limit caps how many are returned, the most frequent first; 200 by default
+	Limit int
+}
+
+// Collocation is a pair of words that occurs together more often than chance
+type Collocation struct {
+	Phrase string // the lemmas of its words, like "student loan"
+	Count  int
+	PMI    float64
+}
+
+// Collocations finds the pairs of adjacent content words that occur together
+// often enough, and more often than chance, in texts of one language, such as
+// "student loans" or "heat wave". Pairs are counted by their lemmas and
+// don't span punctuation; pairs that are already known phrases are left out.
+// Listing them in a phrase file keeps them whole as topics.
+func (e *TopicExtractor) Collocations(texts []string, lang string, opts CollocationOptions) []Collocation {
+	if opts.MinCount <= 0 {
+		opts.MinCount = defaultCollocationMinCount
+	}
+	if opts.MinPMI <= 0 {
+		opts.MinPMI = defaultCollocationMinPMI
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultCollocationLimit
+	}
+	stopwords, ok := e.stopwords[strings.ToLower(lang)]
+	if !ok {
+		stopwords = e.stopwords[DefaultLanguage]
+	}
+
+	words := make(map[string]int)
+	pairs := make(map[[2]string]int)
+	total := 0
+	for _, text := range texts {
+		tokens := tokenizeTopics(text)
+		for i, tok := range tokens {
+			if !collocationWord(tok, stopwords) {
+				continue
+			}
+			words[tok.lemma]++
+			total++
+			if i+1 < len(tokens) && collocationWord(tokens[i+1], stopwords) {
+				pairs[[2]string{tok.lemma, tokens[i+1].lemma}]++
+			}
+		}
+	}
+
+	var found []Collocation
+	for pair, count := range pairs {
+		if count < opts.MinCount || pair[0] == pair[1] || e.knownPhrase(pair[:]) {
+			continue
+		}
+		pmi := math.Log2(float64(count) * float64(total) / (float64(words[pair[0]]) * float64(words[pair[1]])))
+		if pmi >= opts.MinPMI {
+			found = append(found, Collocation{Phrase: pair[0] + " " + pair[1], Count: count, PMI: pmi})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Count != found[j].Count {
+			return found[i].Count > found[j].Count
+		}
+		return found[i].Phrase < found[j].Phrase
+	})
+	if len(found) > opts.Limit {
+		found = found[:opts.Limit]
+	}
+	return found
+}
+
+// collocationWord reports whether a token can be part of a collocation: a
+// word with a letter that isn't a stopword or too short to be a topic
+func collocationWord(tok topicToken, stopwords map[string]bool) bool {
+	if tok.boundary || stopwords[tok.lower] || stopwords[tok.lemma] || len([]rune(tok.lower)) < minTopicLength {
+		return false
+	}
+	for _, r := range tok.lower {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPhrase reports whether lemmas are exactly a known phrase
+func (e *TopicExtractor) knownPhrase(lemmas []string) bool {
+	for _, p := range e.phrases[lemmas[0]] {
+		if strings.Join(p.lemmas, " ") == strings.Join(lemmas, " ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+)
+
+//go:embed lexicon/emotions.txt
+var emotionLexicon []byte
+
+// EmotionAnalyzer scores text in the eight emotions of the NRC Emotion
+// Lexicon, so that anger can be told from fear where sentiment only says
+// both are negative. Each word counts towards every emotion it's associated
+// with, except when negated, since "not afraid" says little about which
+// emotion is meant. It runs next to the sentiment analyzer.
+type EmotionAnalyzer struct {
+	// associations maps a word to the indices of its emotions in
+	// reddit.EmotionNames
+	associations map[string][]int
+}
+
+// NewEmotionAnalyzer loads the embedded emotion lexicon
+func NewEmotionAnalyzer() (*EmotionAnalyzer, error) {
+	associations, err := parseEmotionLexicon(emotionLexicon)
+	if err != nil {
+		return nil, err
+	}
+	return &EmotionAnalyzer{associations: associations}, nil
+}
+
+// parseEmotionLexicon reads tab-separated token/emotions lines, skipping comments
+func parseEmotionLexicon(data []byte) (map[string][]int, error) {
+	index := make(map[string]int, len(reddit.EmotionNames))
+	for i, name := range reddit.EmotionNames {
+		index[name] = i
+	}
+
+	associations := make(map[string][]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		token, emotions, ok := strings.Cut(text, "\t")
+		if !ok {
+			return nil, fmt.Errorf("emotion lexicon line %d: expected token and emotions separated by a tab", line)
+		}
+		var indices []int
+		for _, emotion := range strings.Split(emotions, ",") {
+			i, ok := index[strings.TrimSpace(emotion)]
+			if !ok {
+				return nil, fmt.Errorf("emotion lexicon line %d: unknown emotion %q", line, emotion)
+			}
+			indices = append(indices, i)
+		}
+		associations[strings.ToLower(token)] = indices
+	}
+	return associations, scanner.Err()
+}
+
+// Supports reports whether lang is English, the language of the lexicon
+func (e *EmotionAnalyzer) Supports(lang string) bool {
+	return lang == "en"
+}
+
+// Analyze returns the emotion vector of text
+func (e *EmotionAnalyzer) Analyze(text string) reddit.Emotions {
+	counts := make([]float64, len(reddit.EmotionNames))
+	total := 0.0
+	words := tokenize(text)
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		indices, ok := e.associations[lower]
+		if !ok {
+			indices, ok = e.associations[lemma(lower)]
+		}
+		if !ok || negated(words, i) {
+			continue
+		}
+		for _, index := range indices {
+			counts[index]++
+			total++
+		}
+	}
+
+	var emotions reddit.Emotions
+	if total == 0 {
+		return emotions
+	}
+	for i, field := range emotions.Fields() {
+		*field = counts[i] / total
+	}
+	return emotions
+}
@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"goreddit/internal/reddit"
+	"math"
+	"testing"
+)
+
+func TestParseEmotionLexicon(t *testing.T) {
+	associations, err := parseEmotionLexicon([]byte("# comment\n\nRiot\tanger,fear\njoy\tjoy\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse emotion lexicon: %v", err)
+	}
+	if len(associations) != 2 || len(associations["riot"]) != 2 || associations["joy"][0] != 4 {
+		t.Errorf("Unexpected associations: %v", associations)
+	}
+
+	for _, data := range []string{"riot anger\n", "riot\tannoyance\n"} {
+		if _, err := parseEmotionLexicon([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestEmotionAnalyzer(t *testing.T) {
+	analyzer, err := NewEmotionAnalyzer()
+	if err != nil {
+		t.Fatalf("Failed to load emotion lexicon: %v", err)
+	}
+
+	dominant := func(e reddit.Emotions) string {
+		best, name := 0.0, ""
+		for i, value := range e.Fields() {
+			if *value > best {
+				best, name = *value, reddit.EmotionNames[i]
+			}
+		}
+		return name
+	}
+
+	tests := []struct {
+		text, emotion string
+	}{
+		{"Outrage as senators blame each other over the corruption scandal", "anger"},
+		{"Residents terrified as the flood warning spreads", "fear"},
+		{"Crowds celebrate the peace deal with joy", "joy"},
+		{"A nation mourns the victims of the tragedy", "sadness"},
+	}
+	for _, tt := range tests {
+		emotions := analyzer.Analyze(tt.text)
+		if got := dominant(emotions); got != tt.emotion {
+			t.Errorf("Expected %q to be mostly %s, got %s (%+v)", tt.text, tt.emotion, got, emotions)
+		}
+		sum := 0.0
+		for _, value := range emotions.Fields() {
+			sum += *value
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Expected the emotions of %q to sum to 1, got %v", tt.text, sum)
+		}
+	}
+
+	// Plurals match through their singular
+	if emotions := analyzer.Analyze("More bombings overnight"); emotions.Fear == 0 {
+		t.Errorf("Expected fear from a plural, got %+v", emotions)
+	}
+	// Negated and unknown words carry no emotion
+	for _, text := range []string{"Officials say they are not scared", "Parliament meets on Tuesday", ""} {
+		if emotions := analyzer.Analyze(text); emotions != (reddit.Emotions{}) {
+			t.Errorf("Expected no emotions in %q, got %+v", text, emotions)
+		}
+	}
+}
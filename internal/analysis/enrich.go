@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"strings"
+)
+
+// builtinVersion covers the analyzers that only change with the code and the
+// lists embedded in it: links, languages, entities and emotions. Bump it when
+// they change, so the reenrich job picks up the posts they scored.
+const builtinVersion = "1"
+
+// Enricher runs every configured analyzer over a post. It's shared by the
+// consumers, which enrich posts as they arrive, and the reenrich job, which
+// enriches stored posts again after the analyzers change. It's safe for
+// concurrent use.
+type Enricher struct {
+	analyzer  Analyzer
+	emotions  *EmotionAnalyzer
+	toxicity  *ToxicityScorer
+	languages *LanguageDetector
+	topics    *TopicExtractor
+	entities  *EntityExtractor
+	summaries *Summarizer
+	versions  string
+}
+
+// NewEnricher creates the analyzers selected and tuned by the config
+func NewEnricher(cfg *config.Config) (*Enricher, error) {
+	analyzer, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := NewTopicExtractor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := NewEntityExtractor()
+	if err != nil {
+		return nil, err
+	}
+	emotions, err := NewEmotionAnalyzer()
+	if err != nil {
+		return nil, err
+	}
+	toxicityModel, err := ToxicityModelFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	toxicity, err := NewToxicityScorer(toxicityModel)
+	if err != nil {
+		return nil, err
+	}
+	languages, err := NewLanguageDetector()
+	if err != nil {
+		return nil, err
+	}
+	summaryOpts, err := SummaryOptionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Enricher{
+		analyzer:  analyzer,
+		emotions:  emotions,
+		toxicity:  toxicity,
+		languages: languages,
+		topics:    topics,
+		entities:  entities,
+		summaries: NewSummarizer(summaryOpts),
+	}
+	e.versions = strings.Join([]string{
+		"builtin=" + builtinVersion,
+		"sentiment=" + ModelID(e.analyzer),
+		"summary=" + e.summaries.Version(),
+		"topics=" + e.topics.Version(),
+		"toxicity=" + e.toxicity.Version(),
+	}, " ")
+	return e, nil
+}
+
+// Versions lists the version of each analyzer, like
+// "builtin=1 sentiment=bayes:imdb summary=1000x3 topics=1f2e3d4c toxicity=builtin-2".
+// It's recorded on every enriched post, so posts enriched by analyzers that
+// have changed since can be found.
+func (e *Enricher) Versions() string {
+	return e.versions
+}
+
+// Enrich canonicalizes the link of a post and sets its domain, language,
+// topics, entities, summary, sentiment, emotions and toxicity from its title,
+// body and link. Analyses with no model for the post's language are skipped
+// rather than run on text they'd misread. Stories are left to the caller,
+// since they depend on the posts seen before.
+func (e *Enricher) Enrich(post *reddit.Post) {
+	text := post.Title
+	if post.Body != "" {
+		text += " " + post.Body
+	}
+
+	if link := ParseLink(*post); link.URL != "" {
+		post.URL, post.Domain = link.URL, link.Domain
+	}
+
+	post.Language = e.languages.Detect(text)
+	lang := post.Language
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+
+	post.Topics = nil
+	if e.topics.Supports(lang) {
+		post.Topics = e.topics.Extract(text, lang)
+	}
+	post.Entities = nil
+	if e.entities.Supports(lang) {
+		post.Entities = e.entities.Extract(text)
+	}
+	post.Summary = ""
+	if e.summaries.Supports(lang) {
+		post.Summary = e.summaries.Summarize(post.Body, lang)
+	}
+	post.Sentiment, post.SentimentModel, post.Unsupported = 0, "", !e.analyzer.Supports(lang)
+	if !post.Unsupported {
+		post.Sentiment = e.analyzer.Analyze(text).Score
+		post.SentimentModel = ModelID(e.analyzer)
+	}
+	post.Unscored = !e.emotions.Supports(lang) || !e.toxicity.Supports(lang)
+	post.Emotions = reddit.Emotions{}
+	if e.emotions.Supports(lang) {
+		post.Emotions = e.emotions.Analyze(text)
+	}
+	post.Toxicity = 0
+	if e.toxicity.Supports(lang) {
+		post.Toxicity = e.toxicity.Score(text)
+	}
+	post.AnalyzerVersions = e.versions
+}
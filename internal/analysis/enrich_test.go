@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"strings"
+	"testing"
+)
+
+func TestEnricher(t *testing.T) {
+	cfg := &config.Config{}
+	enricher, err := NewEnricher(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+	if versions := enricher.Versions(); !strings.Contains(versions, "sentiment=bayes:imdb") || !strings.Contains(versions, "topics=") {
+		t.Errorf("Unexpected versions %q", versions)
+	}
+
+	post := reddit.Post{
+		Title: "Earthquake kills hundreds in Turkey",
+		URL:   "https://www.bbc.co.uk/news/world?utm_source=reddit",
+	}
+	enricher.Enrich(&post)
+	if post.Domain != "bbc.co.uk" || post.Language != "en" || len(post.Topics) == 0 || post.Sentiment >= 0 ||
+		post.SentimentModel != "bayes:imdb" || post.AnalyzerVersions != enricher.Versions() || post.Unsupported || post.Unscored {
+		t.Errorf("Unexpected enriched post: %+v", post)
+	}
+
+	// Posts in other languages are marked as neither analyzed nor scored
+	german := reddit.Post{Title: "Die Regierung hat heute über den neuen Haushalt für das nächste Jahr abgestimmt"}
+	enricher.Enrich(&german)
+	if german.Language != "de" || !german.Unsupported || !german.Unscored || german.Toxicity != 0 {
+		t.Errorf("Unexpected enriched German post: %+v", german)
+	}
+
+	// Changing the stopwords changes the topics version
+	cfg.Topics.Stopwords = map[string][]string{"en": {"earthquake"}}
+	changed, err := NewEnricher(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+	if changed.Versions() == enricher.Versions() {
+		t.Errorf("Expected new versions after changing stopwords, got %q", changed.Versions())
+	}
+}
@@ -0,0 +1,196 @@
+package analysis
+
+import (
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+	"unicode"
+
+	prose "github.com/jdkato/prose/v2"
+)
+
+// proseTypes maps the labels of prose's entity model to the types kept on
+// posts. Its other labels, like FACILITY, are dropped.
+var proseTypes = map[string]string{
+	"PERSON":       reddit.EntityPerson,
+	"GPE":          reddit.EntityPlace,
+	"ORGANIZATION": reddit.EntityOrganization,
+}
+
+// entityAliases maps common variants, keyed lowercase without periods, to one
+// canonical entity. The type here overrides the model's, which regularly tags
+// organizations like the EU as places.
+var entityAliases = map[string]reddit.Entity{
+	"us":                          {Name: "United States", Type: reddit.EntityPlace},
+	"usa":                         {Name: "United States", Type: reddit.EntityPlace},
+	"united states":               {Name: "United States", Type: reddit.EntityPlace},
+	"united states of america":    {Name: "United States", Type: reddit.EntityPlace},
+	"america":                     {Name: "United States", Type: reddit.EntityPlace},
+	"uk":                          {Name: "United Kingdom", Type: reddit.EntityPlace},
+	"britain":                     {Name: "United Kingdom", Type: reddit.EntityPlace},
+	"great britain":               {Name: "United Kingdom", Type: reddit.EntityPlace},
+	"united kingdom":              {Name: "United Kingdom", Type: reddit.EntityPlace},
+	"uae":                         {Name: "United Arab Emirates", Type: reddit.EntityPlace},
+	"united arab emirates":        {Name: "United Arab Emirates", Type: reddit.EntityPlace},
+	"russian federation":          {Name: "Russia", Type: reddit.EntityPlace},
+	"prc":                         {Name: "China", Type: reddit.EntityPlace},
+	"eu":                          {Name: "European Union", Type: reddit.EntityOrganization},
+	"european union":              {Name: "European Union", Type: reddit.EntityOrganization},
+	"un":                          {Name: "United Nations", Type: reddit.EntityOrganization},
+	"united nations":              {Name: "United Nations", Type: reddit.EntityOrganization},
+	"nato":                        {Name: "NATO", Type: reddit.EntityOrganization},
+	"fed":                         {Name: "Federal Reserve", Type: reddit.EntityOrganization},
+	"federal reserve":             {Name: "Federal Reserve", Type: reddit.EntityOrganization},
+	"white house":                 {Name: "White House", Type: reddit.EntityOrganization},
+	"kremlin":                     {Name: "Kremlin", Type: reddit.EntityOrganization},
+	"pentagon":                    {Name: "Pentagon", Type: reddit.EntityOrganization},
+	"world health organization":   {Name: "World Health Organization", Type: reddit.EntityOrganization},
+	"imf":                         {Name: "International Monetary Fund", Type: reddit.EntityOrganization},
+	"international monetary fund": {Name: "International Monetary Fund", Type: reddit.EntityOrganization},
+	"fbi":                         {Name: "FBI", Type: reddit.EntityOrganization},
+	"cia":                         {Name: "CIA", Type: reddit.EntityOrganization},
+	"nasa":                        {Name: "NASA", Type: reddit.EntityOrganization},
+	"opec":                        {Name: "OPEC", Type: reddit.EntityOrganization},
+	"idf":                         {Name: "IDF", Type: reddit.EntityOrganization},
+}
+
+// EntityExtractor finds the people, places and organizations in text. The
+// prose model is loaded once and only read afterwards, so an extractor can be
+// shared between goroutines.
+type EntityExtractor struct {
+	model *prose.Model
+}
+
+// NewEntityExtractor loads prose's English entity model
+func NewEntityExtractor() (*EntityExtractor, error) {
+	// prose only exposes its bundled model through a document
+	doc, err := prose.NewDocument("", prose.WithSegmentation(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entity model: %w", err)
+	}
+	return &EntityExtractor{model: doc.Model}, nil
+}
+
+// Supports reports whether lang is English, the language prose's model was
+// trained on
+func (e *EntityExtractor) Supports(lang string) bool {
+	return lang == "en"
+}
+
+// Extract returns the distinct entities in text, normalized, in order of
+// first mention
+func (e *EntityExtractor) Extract(text string) []reddit.Entity {
+	doc, err := prose.NewDocument(text, prose.UsingModel(e.model), prose.WithSegmentation(false))
+	if err != nil {
+		return nil
+	}
+
+	var entities []reddit.Entity
+	for _, found := range doc.Entities() {
+		// Unmapped labels still count when the text is a known alias, since
+		// the model calls the White House a FACILITY
+		entityType := proseTypes[found.Label]
+		if entity, ok := normalizeEntity(found.Text, entityType); ok && entity.Type != "" {
+			entities = append(entities, entity)
+		}
+	}
+
+	// The model tends to miss bare acronyms like "EU" or "US" in headlines
+	for _, token := range doc.Tokens() {
+		if isAcronym(token.Text) {
+			if alias, ok := entityAliases[aliasKey(token.Text)]; ok {
+				entities = append(entities, alias)
+			}
+		}
+	}
+
+	return dedupeEntities(entities)
+}
+
+// normalizeEntity strips possessives, punctuation and a leading "the",
+// resolves aliases and title-cases names written in all caps. An unknown
+// entityType is returned empty unless the text is an alias.
+func normalizeEntity(text, entityType string) (reddit.Entity, bool) {
+	text = strings.ReplaceAll(text, "’", "'")
+	text = strings.Join(strings.Fields(text), " ")
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "'s"), "'")
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+	text = strings.TrimRight(text, ".")
+	if len(text) > 4 && strings.EqualFold(text[:4], "the ") {
+		text = text[4:]
+	}
+	if text == "" {
+		return reddit.Entity{}, false
+	}
+
+	if alias, ok := entityAliases[aliasKey(text)]; ok {
+		return alias, true
+	}
+	if strings.ToUpper(text) == text && !isAcronym(text) {
+		text = titleCase(text)
+	}
+	return reddit.Entity{Name: text, Type: entityType}, true
+}
+
+// dedupeEntities drops repeated entities and folds a surname mentioned on its
+// own into the full name of a person mentioned in the same text
+func dedupeEntities(entities []reddit.Entity) []reddit.Entity {
+	var fullNames []string
+	for _, entity := range entities {
+		if entity.Type == reddit.EntityPerson && strings.Contains(entity.Name, " ") {
+			fullNames = append(fullNames, entity.Name)
+		}
+	}
+
+	seen := make(map[reddit.Entity]bool, len(entities))
+	unique := make([]reddit.Entity, 0, len(entities))
+	for _, entity := range entities {
+		// The model often mistypes lone surnames, so any type is folded
+		if !strings.Contains(entity.Name, " ") {
+			for _, full := range fullNames {
+				if strings.HasSuffix(full, " "+entity.Name) {
+					entity = reddit.Entity{Name: full, Type: reddit.EntityPerson}
+					break
+				}
+			}
+		}
+		key := reddit.Entity{Name: strings.ToLower(entity.Name), Type: entity.Type}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, entity)
+		}
+	}
+	return unique
+}
+
+func aliasKey(text string) string {
+	return strings.ToLower(strings.ReplaceAll(text, ".", ""))
+}
+
+// isAcronym reports whether text is 2 to 5 capital letters, with or without
+// periods, like "EU" or "U.S."
+func isAcronym(text string) bool {
+	letters := 0
+	for _, r := range text {
+		switch {
+		case r == '.':
+		case unicode.IsUpper(r):
+			letters++
+		default:
+			return false
+		}
+	}
+	return letters >= 2 && letters <= 5
+}
+
+func titleCase(text string) string {
+	words := strings.Fields(strings.ToLower(text))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
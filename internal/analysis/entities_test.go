@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"goreddit/internal/reddit"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeEntity(t *testing.T) {
+	tests := []struct {
+		text, label string
+		want        reddit.Entity
+	}{
+		{"Russia's", reddit.EntityPlace, reddit.Entity{Name: "Russia", Type: reddit.EntityPlace}},
+		{"the U.S.", reddit.EntityPlace, reddit.Entity{Name: "United States", Type: reddit.EntityPlace}},
+		{"America", reddit.EntityPlace, reddit.Entity{Name: "United States", Type: reddit.EntityPlace}},
+		{"European Union", reddit.EntityPlace, reddit.Entity{Name: "European Union", Type: reddit.EntityOrganization}},
+		{"White House", "", reddit.Entity{Name: "White House", Type: reddit.EntityOrganization}},
+		{"VLADIMIR  PUTIN", reddit.EntityPerson, reddit.Entity{Name: "Vladimir Putin", Type: reddit.EntityPerson}},
+		{"OPEC’s", reddit.EntityOrganization, reddit.Entity{Name: "OPEC", Type: reddit.EntityOrganization}},
+		{"Geneva", "", reddit.Entity{Name: "Geneva"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizeEntity(tt.text, tt.label)
+		if !ok || got != tt.want {
+			t.Errorf("normalizeEntity(%q, %q): expected %+v, got %+v (%v)", tt.text, tt.label, tt.want, got, ok)
+		}
+	}
+	if _, ok := normalizeEntity("'s", reddit.EntityPerson); ok {
+		t.Error("Expected nothing left of a bare possessive")
+	}
+}
+
+func TestDedupeEntities(t *testing.T) {
+	got := dedupeEntities([]reddit.Entity{
+		{Name: "Biden", Type: reddit.EntityPlace},
+		{Name: "Joe Biden", Type: reddit.EntityPerson},
+		{Name: "Ukraine", Type: reddit.EntityPlace},
+		{Name: "UKRAINE", Type: reddit.EntityPlace},
+	})
+	want := []reddit.Entity{
+		{Name: "Joe Biden", Type: reddit.EntityPerson},
+		{Name: "Ukraine", Type: reddit.EntityPlace},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestEntityExtractor(t *testing.T) {
+	extractor, err := NewEntityExtractor()
+	if err != nil {
+		t.Fatalf("Failed to create extractor: %v", err)
+	}
+
+	got := extractor.Extract("Joe Biden meets Vladimir Putin in Geneva as NATO warns Russia's allies; EU and US react")
+	want := map[reddit.Entity]bool{
+		{Name: "Joe Biden", Type: reddit.EntityPerson}:            true,
+		{Name: "Vladimir Putin", Type: reddit.EntityPerson}:       true,
+		{Name: "Geneva", Type: reddit.EntityPlace}:                true,
+		{Name: "NATO", Type: reddit.EntityOrganization}:           true,
+		{Name: "Russia", Type: reddit.EntityPlace}:                true,
+		{Name: "European Union", Type: reddit.EntityOrganization}: true,
+		{Name: "United States", Type: reddit.EntityPlace}:         true,
+	}
+	for _, entity := range got {
+		if !want[entity] {
+			t.Errorf("Unexpected entity %+v", entity)
+		}
+		delete(want, entity)
+	}
+	for entity := range want {
+		t.Errorf("Missing entity %+v", entity)
+	}
+
+	if got := extractor.Extract(""); len(got) != 0 {
+		t.Errorf("Expected no entities in empty text, got %+v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package analysis
+
+import (
+	"embed"
+	"io/fs"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxNgram = 3
+	// defaultLanguageBonus is the log-odds head start of DefaultLanguage, since
+	// most posts are in it and short titles are often ambiguous
+	defaultLanguageBonus = 8.0
+	// maxDetectRunes bounds how much of a long text is looked at
+	maxDetectRunes = 1000
+)
+
+//go:embed lexicon/languages/*.txt
+var languageSamples embed.FS
+
+// scriptLanguages maps scripts used by a single supported language to it
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// LanguageDetector identifies the language of a text offline. Languages with
+// a script of their own, like Korean or Greek, are told by their script.
+// Languages sharing one, like the Latin and Cyrillic ones, are told apart by
+// a naive Bayes model of character n-grams, trained at startup on the sample
+// texts in lexicon/languages.
+type LanguageDetector struct {
+	// profiles holds each language's n-gram log probabilities by script
+	profiles map[*unicode.RangeTable]map[string]*ngramProfile
+}
+
+type ngramProfile struct {
+	logProbs map[string]float64
+	// unseen is the log probability of an n-gram missing from the sample
+	unseen float64
+}
+
+// NewLanguageDetector builds the n-gram profiles from the embedded samples
+func NewLanguageDetector() (*LanguageDetector, error) {
+	d := &LanguageDetector{profiles: make(map[*unicode.RangeTable]map[string]*ngramProfile)}
+
+	paths, err := fs.Glob(languageSamples, "lexicon/languages/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := languageSamples.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lang := strings.TrimSuffix(strings.TrimPrefix(path, "lexicon/languages/"), ".txt")
+		sample := strings.Join(readWordList(data), " ")
+
+		counts := make(map[string]int)
+		total := 0
+		for _, gram := range ngrams(sample) {
+			counts[gram]++
+			total++
+		}
+		profile := &ngramProfile{logProbs: make(map[string]float64, len(counts))}
+		// Add-one smoothing over the sample's n-grams plus one for all unseen ones
+		denominator := math.Log(float64(total + len(counts) + 1))
+		for gram, count := range counts {
+			profile.logProbs[gram] = math.Log(float64(count+1)) - denominator
+		}
+		profile.unseen = -denominator
+
+		script := dominantScript(sample)
+		if d.profiles[script] == nil {
+			d.profiles[script] = make(map[string]*ngramProfile)
+		}
+		d.profiles[script][lang] = profile
+	}
+	return d, nil
+}
+
+// Detect returns the ISO 639-1 code of the text's language, like "en" or
+// "de", or "" if it has no letters or is in a script no language is known for
+func (d *LanguageDetector) Detect(text string) string {
+	if runes := []rune(text); len(runes) > maxDetectRunes {
+		text = string(runes[:maxDetectRunes])
+	}
+
+	script := dominantScript(text)
+	if script == nil {
+		return ""
+	}
+	for _, sl := range scriptLanguages {
+		if sl.script == script {
+			// Japanese mixes kana with Han characters
+			if script == unicode.Han && hasScript(text, unicode.Hiragana, unicode.Katakana) {
+				return "ja"
+			}
+			return sl.lang
+		}
+	}
+
+	profiles := d.profiles[script]
+	if len(profiles) == 0 {
+		return ""
+	}
+	if len(profiles) == 1 {
+		for lang := range profiles {
+			return lang
+		}
+	}
+
+	grams := ngrams(text)
+	best, bestScore := "", math.Inf(-1)
+	for lang, profile := range profiles {
+		score := 0.0
+		if lang == DefaultLanguage {
+			score = defaultLanguageBonus
+		}
+		for _, gram := range grams {
+			if p, ok := profile.logProbs[gram]; ok {
+				score += p
+			} else {
+				score += profile.unseen
+			}
+		}
+		if score > bestScore || score == bestScore && lang < best {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
+
+// ngrams returns the 1- to 3-grams of each lowercased word, padded with
+// spaces so that word starts and ends count
+func ngrams(text string) []string {
+	var grams []string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		runes := []rune(" " + strings.Trim(word, "'") + " ")
+		for n := 1; n <= maxNgram; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if gram := string(runes[i : i+n]); gram != " " {
+					grams = append(grams, gram)
+				}
+			}
+		}
+	}
+	return grams
+}
+
+// allScripts are the scripts dominantScript tells apart
+var allScripts = []*unicode.RangeTable{
+	unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew,
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Devanagari, unicode.Thai,
+}
+
+// dominantScript returns the script most of the text's letters are in, or
+// nil if it has none
+func dominantScript(text string) *unicode.RangeTable {
+	counts := make(map[*unicode.RangeTable]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, script := range allScripts {
+			if unicode.Is(script, r) {
+				counts[script]++
+				break
+			}
+		}
+	}
+
+	var best *unicode.RangeTable
+	for _, script := range allScripts {
+		if counts[script] > 0 && (best == nil || counts[script] > counts[best]) {
+			best = script
+		}
+	}
+	return best
+}
+
+func hasScript(text string, scripts ...*unicode.RangeTable) bool {
+	for _, r := range text {
+		if unicode.In(r, scripts...) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestLanguageDetector(t *testing.T) {
+	d, err := NewLanguageDetector()
+	if err != nil {
+		t.Fatalf("Failed to create language detector: %v", err)
+	}
+
+	headlines := readHeadlines(t, "testdata/languages.tsv")
+	correct := 0
+	for _, h := range headlines {
+		if got := d.Detect(h.text); got == h.label {
+			correct++
+		} else {
+			t.Logf("Misdetected %q: expected %s, got %s", h.text, h.label, got)
+		}
+	}
+	if accuracy := float64(correct) / float64(len(headlines)); accuracy < 0.9 {
+		t.Errorf("Expected at least 90%% accuracy on short headlines, got %.2f (%d/%d)", accuracy, correct, len(headlines))
+	}
+
+	tests := map[string]string{
+		"":          "",
+		"12345 !!!": "",
+		"서울에서 대규모 집회가 열렸다":                    "ko",
+		"東京で大規模なデモが行われた":                      "ja",
+		"北京举行大规模抗议活动":                         "zh",
+		"Η κυβέρνηση ανακοίνωσε νέα μέτρα":    "el",
+		"Уряд оголосив нові заходи підтримки": "uk",
+		"Правительство объявило новые меры":   "ru",
+	}
+	for text, want := range tests {
+		if got := d.Detect(text); got != want {
+			t.Errorf("Detect(%q): expected %q, got %q", text, want, got)
+		}
+	}
+}
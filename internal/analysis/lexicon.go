@@ -0,0 +1,322 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LexiconName selects the Lexicon analyzer in config
+const LexiconName = "lexicon"
+
+// lexiconVersion is the version of the embedded lexicon and rules
+const lexiconVersion = "1"
+
+//go:embed lexicon/sentiment.txt
+var sentimentLexicon []byte
+
+// Constants from VADER (Hutto & Gilbert, 2014), which this analyzer follows
+const (
+	boosterIncrement   = 0.293 // added to a valence by an intensifier
+	capsIncrement      = 0.733 // added to a valence written in caps among lowercase words
+	negationScalar     = -0.74 // multiplies a negated valence
+	exclaimIncrement   = 0.292 // per exclamation mark, up to maxExclaims
+	maxExclaims        = 4
+	questionIncrement  = 0.18 // per question mark, from the second up to maxQuestions
+	maxQuestions       = 3
+	maxQuestionEmph    = 0.96 // emphasis for more than maxQuestions question marks
+	negationWindow     = 3    // words before a sentiment word checked for negation
+	normalizationAlpha = 15   // approximates the maximum expected raw sum
+)
+
+// negations flip the valence of a sentiment word following within negationWindow
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nobody": true,
+	"nothing": true, "neither": true, "nor": true, "nowhere": true, "without": true,
+	"cannot": true, "cant": true, "can't": true, "dont": true, "don't": true,
+	"doesnt": true, "doesn't": true, "didnt": true, "didn't": true, "isnt": true,
+	"isn't": true, "arent": true, "aren't": true, "wasnt": true, "wasn't": true,
+	"werent": true, "weren't": true, "wont": true, "won't": true, "wouldnt": true,
+	"wouldn't": true, "shouldnt": true, "shouldn't": true, "couldnt": true,
+	"couldn't": true, "hasnt": true, "hasn't": true, "havent": true, "haven't": true,
+	"hadnt": true, "hadn't": true, "aint": true, "ain't": true, "rarely": true,
+	"seldom": true, "despite": true,
+}
+
+// boosters intensify (positive) or dampen (negative) the following sentiment word
+var boosters = map[string]float64{
+	"absolutely": boosterIncrement, "amazingly": boosterIncrement, "completely": boosterIncrement,
+	"deeply": boosterIncrement, "enormously": boosterIncrement, "entirely": boosterIncrement,
+	"especially": boosterIncrement, "exceptionally": boosterIncrement, "extremely": boosterIncrement,
+	"fully": boosterIncrement, "greatly": boosterIncrement, "highly": boosterIncrement,
+	"hugely": boosterIncrement, "incredibly": boosterIncrement, "massively": boosterIncrement,
+	"most": boosterIncrement, "particularly": boosterIncrement, "really": boosterIncrement,
+	"remarkably": boosterIncrement, "so": boosterIncrement, "substantially": boosterIncrement,
+	"totally": boosterIncrement, "tremendously": boosterIncrement, "truly": boosterIncrement,
+	"utterly": boosterIncrement, "very": boosterIncrement, "more": boosterIncrement,
+	"almost": -boosterIncrement, "barely": -boosterIncrement, "hardly": -boosterIncrement,
+	"less": -boosterIncrement, "marginally": -boosterIncrement, "partly": -boosterIncrement,
+	"slightly": -boosterIncrement, "somewhat": -boosterIncrement, "kinda": -boosterIncrement,
+	"sorta": -boosterIncrement, "occasionally": -boosterIncrement, "little": -boosterIncrement,
+}
+
+// Polarity is the full output of the Lexicon analyzer
+type Polarity struct {
+	// Compound is the normalized overall score, from -1.0 to 1.0
+	Compound float64 `json:"compound"`
+	// Positive, Neutral and Negative are the proportions of the text in each
+	// category and sum to 1.0
+	Positive float64 `json:"pos"`
+	Neutral  float64 `json:"neu"`
+	Negative float64 `json:"neg"`
+}
+
+// Lexicon is a rule-based analyzer in the style of VADER. Each word's valence
+// comes from an embedded lexicon and is adjusted for preceding intensifiers
+// and negations, emphasis in caps, contrast around "but", and trailing
+// exclamation and question marks. It suits short text like headlines, where
+// statistical models trained on reviews have little to go on.
+type Lexicon struct {
+	valences  map[string]float64
+	threshold float64
+}
+
+// NewLexicon loads the embedded lexicon
+func NewLexicon(neutralThreshold float64) (*Lexicon, error) {
+	valences, err := parseLexicon(sentimentLexicon)
+	if err != nil {
+		return nil, err
+	}
+	return &Lexicon{valences: valences, threshold: neutralThreshold}, nil
+}
+
+// parseLexicon reads tab-separated token/valence lines, skipping comments
+func parseLexicon(data []byte) (map[string]float64, error) {
+	valences := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		token, value, ok := strings.Cut(text, "\t")
+		if !ok {
+			return nil, fmt.Errorf("lexicon line %d: expected token and valence separated by a tab", line)
+		}
+		valence, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("lexicon line %d: invalid valence %q", line, value)
+		}
+		valences[strings.ToLower(token)] = valence
+	}
+	return valences, scanner.Err()
+}
+
+func (l *Lexicon) Name() string {
+	return LexiconName
+}
+
+// Version changes when the lexicon or the rules do
+func (l *Lexicon) Version() string {
+	return lexiconVersion
+}
+
+// Supports reports whether lang is English, the language of the lexicon
+func (l *Lexicon) Supports(lang string) bool {
+	return lang == "en"
+}
+
+func (l *Lexicon) Analyze(text string) Result {
+	return result(l.Polarity(text).Compound, l.threshold)
+}
+
+// Polarity scores text
+func (l *Lexicon) Polarity(text string) Polarity {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return Polarity{Neutral: 1}
+	}
+	capsDiff := mixedCaps(words)
+
+	valences := make([]float64, len(words))
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		valence, ok := l.valences[lower]
+		if !ok || boosters[lower] != 0 {
+			continue
+		}
+
+		if capsDiff && isUpper(word) {
+			valence += math.Copysign(capsIncrement, valence)
+		}
+
+		for back := 1; back <= negationWindow && i-back >= 0; back++ {
+			prev := words[i-back]
+			// Intensifiers further back count for less
+			if boost := boosters[strings.ToLower(prev)]; boost != 0 {
+				if capsDiff && isUpper(prev) {
+					boost += math.Copysign(capsIncrement, boost)
+				}
+				if valence < 0 {
+					boost = -boost
+				}
+				valence += boost * (1 - 0.05*float64(back-1))
+			}
+		}
+
+		if negated(words, i) {
+			valence *= negationScalar
+		}
+		valences[i] = valence
+	}
+
+	butContrast(words, valences)
+
+	var sum float64
+	for _, v := range valences {
+		sum += v
+	}
+	emphasis := punctuationEmphasis(text)
+	if sum > 0 {
+		sum += emphasis
+	} else if sum < 0 {
+		sum -= emphasis
+	}
+
+	return Polarity{
+		Compound: sum / math.Sqrt(sum*sum+normalizationAlpha),
+	}.withProportions(valences, emphasis)
+}
+
+// withProportions splits the text into positive, neutral and negative shares.
+// Each sentiment word counts one more than its valence, so that weak words
+// aren't swamped by neutral ones.
+func (p Polarity) withProportions(valences []float64, emphasis float64) Polarity {
+	var pos, neg, neu float64
+	for _, v := range valences {
+		switch {
+		case v > 0:
+			pos += v + 1
+		case v < 0:
+			neg += -v + 1
+		default:
+			neu++
+		}
+	}
+	if pos > neg {
+		pos += emphasis
+	} else if neg > pos {
+		neg += emphasis
+	}
+
+	total := pos + neg + neu
+	p.Positive, p.Negative, p.Neutral = pos/total, neg/total, neu/total
+	return p
+}
+
+// tokenize splits text on whitespace and strips surrounding punctuation,
+// dropping single-letter words. Curly apostrophes are straightened so that
+// contractions like "didn’t" match.
+func tokenize(text string) []string {
+	var words []string
+	text = strings.ReplaceAll(text, "’", "'")
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len([]rune(word)) > 1 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// mixedCaps reports whether some but not all words are written in caps, in
+// which case the ones that are are emphasized
+func mixedCaps(words []string) bool {
+	upper := 0
+	for _, word := range words {
+		if isUpper(word) {
+			upper++
+		}
+	}
+	return upper > 0 && upper < len(words)
+}
+
+func isUpper(word string) bool {
+	hasLetter := false
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		hasLetter = hasLetter || unicode.IsLetter(r)
+	}
+	return hasLetter
+}
+
+// negated reports whether a negation precedes words[i] within negationWindow.
+// "never so" and "never this" intensify rather than negate, as in VADER.
+func negated(words []string, i int) bool {
+	for back := 1; back <= negationWindow && i-back >= 0; back++ {
+		prev := strings.ToLower(words[i-back])
+		if strings.HasSuffix(prev, "n't") {
+			return true
+		}
+		if !negations[prev] {
+			continue
+		}
+		if prev == "never" && back > 1 {
+			next := strings.ToLower(words[i-back+1])
+			if next == "so" || next == "this" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// butContrast dampens sentiment before "but" and strengthens it after, since
+// the clause after "but" usually carries the writer's point
+func butContrast(words []string, valences []float64) {
+	but := -1
+	for i, word := range words {
+		if strings.EqualFold(word, "but") {
+			but = i
+			break
+		}
+	}
+	if but < 0 {
+		return
+	}
+	for i := range valences {
+		switch {
+		case i < but:
+			valences[i] *= 0.5
+		case i > but:
+			valences[i] *= 1.5
+		}
+	}
+}
+
+// punctuationEmphasis adds intensity for exclamation and repeated question marks
+func punctuationEmphasis(text string) float64 {
+	exclaims := strings.Count(text, "!")
+	if exclaims > maxExclaims {
+		exclaims = maxExclaims
+	}
+	emphasis := float64(exclaims) * exclaimIncrement
+
+	switch questions := strings.Count(text, "?"); {
+	case questions > maxQuestions:
+		emphasis += maxQuestionEmph
+	case questions > 1:
+		emphasis += float64(questions) * questionIncrement
+	}
+	return emphasis
+}
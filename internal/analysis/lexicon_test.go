@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestLexicon(t *testing.T) *Lexicon {
+	t.Helper()
+	lexicon, err := NewLexicon(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to load lexicon: %v", err)
+	}
+	return lexicon
+}
+
+func TestParseLexicon(t *testing.T) {
+	valences, err := parseLexicon([]byte("# comment\n\nGood\t1.9\nbad\t-2.5\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse lexicon: %v", err)
+	}
+	if len(valences) != 2 || valences["good"] != 1.9 || valences["bad"] != -2.5 {
+		t.Errorf("Unexpected valences: %v", valences)
+	}
+
+	for _, data := range []string{"good 1.9\n", "good\tvery\n"} {
+		if _, err := parseLexicon([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestLexiconRules(t *testing.T) {
+	lexicon := newTestLexicon(t)
+	compound := func(text string) float64 { return lexicon.Polarity(text).Compound }
+
+	tests := []struct {
+		name           string
+		weaker, strong string
+	}{
+		{"intensifier", "The plan is good", "The plan is extremely good"},
+		{"dampener", "The plan is slightly good", "The plan is good"},
+		{"negative intensifier", "The plan is extremely bad", "The plan is bad"},
+		{"caps emphasis", "The plan is good", "The plan is GOOD"},
+		{"exclamation", "The plan is good", "The plan is good!!"},
+		{"clause after but", "The plan is good but the rollout was bad", "The plan is bad but the rollout was good"},
+	}
+	for _, tt := range tests {
+		if weak, strong := compound(tt.weaker), compound(tt.strong); !(strong > weak) {
+			t.Errorf("%s: expected %q (%v) to score above %q (%v)", tt.name, tt.strong, strong, tt.weaker, weak)
+		}
+	}
+
+	for _, text := range []string{"The plan is not good", "The plan isn't good", "The plan isn’t good", "Never a good plan"} {
+		if got := compound(text); got >= 0 {
+			t.Errorf("Expected %q to be negated, got %v", text, got)
+		}
+	}
+	if got := compound("ALL CAPS IS NOT EMPHASIS FOR GOOD NEWS"); math.Abs(got-compound("all caps is not emphasis for good news")) > 1e-9 {
+		t.Errorf("Expected all-caps text to score like lowercase, got %v", got)
+	}
+}
+
+func TestLexiconPolarity(t *testing.T) {
+	lexicon := newTestLexicon(t)
+
+	for _, text := range []string{"", "Parliament to vote on housing bill", "Killed and wounded as war rages, but rescuers save survivors!"} {
+		p := lexicon.Polarity(text)
+		if sum := p.Positive + p.Neutral + p.Negative; math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%q: proportions sum to %v", text, sum)
+		}
+		if p.Compound < -1 || p.Compound > 1 {
+			t.Errorf("%q: compound %v out of range", text, p.Compound)
+		}
+	}
+
+	if p := lexicon.Polarity("Parliament to vote on housing bill"); p.Compound != 0 || p.Neutral != 1 {
+		t.Errorf("Expected a neutral headline, got %+v", p)
+	}
+}
+
+// TestHeadlineAccuracy checks both analyzers against the labeled headlines.
+// The Bayes model was trained on movie reviews and is kept for comparison;
+// the lexicon analyzer should beat it by a clear margin.
+func TestHeadlineAccuracy(t *testing.T) {
+	headlines := readHeadlines(t, "testdata/headlines.tsv")
+	lexicon := newTestLexicon(t)
+	bayes, err := NewBayes(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to create analyzer: %v", err)
+	}
+
+	accuracy := func(a Analyzer) float64 {
+		correct := 0
+		for _, h := range headlines {
+			if a.Analyze(h.text).Label == Label(h.label) {
+				correct++
+			} else if testing.Verbose() {
+				t.Logf("%s: %q labeled %s, got %+v", a.Name(), h.text, h.label, a.Analyze(h.text))
+			}
+		}
+		return float64(correct) / float64(len(headlines))
+	}
+
+	lexiconAccuracy, bayesAccuracy := accuracy(lexicon), accuracy(bayes)
+	t.Logf("Accuracy over %d headlines: lexicon %.2f, bayes %.2f", len(headlines), lexiconAccuracy, bayesAccuracy)
+
+	if lexiconAccuracy < 0.8 {
+		t.Errorf("Expected lexicon accuracy of at least 0.80, got %.2f", lexiconAccuracy)
+	}
+	if lexiconAccuracy < bayesAccuracy+0.2 {
+		t.Errorf("Expected lexicon accuracy (%.2f) well above bayes (%.2f)", lexiconAccuracy, bayesAccuracy)
+	}
+}
+
+// labeledHeadline is a line of a testdata file: a label, a tab and the text
+type labeledHeadline struct {
+	label string
+	text  string
+}
+
+func readHeadlines(t *testing.T, path string) []labeledHeadline {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open headlines: %v", err)
+	}
+	defer f.Close()
+
+	var headlines []labeledHeadline
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		label, text, ok := strings.Cut(line, "\t")
+		if !ok {
+			t.Fatalf("Malformed headline %q", line)
+		}
+		headlines = append(headlines, labeledHeadline{label, text})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read headlines: %v", err)
+	}
+	return headlines
+}
@@ -0,0 +1,250 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"goreddit/internal/reddit"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// trackingParams are query parameters that say where a link was shared, not
+// what it points to
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "ref": true, "ref_src": true, "cmpid": true,
+	"smid": true, "share": true, "ocid": true, "taid": true,
+}
+
+// Link is where a post points, reduced to its canonical form
+type Link struct {
+	URL string // canonical URL; empty if the post's link couldn't be parsed
+	// Domain is the registrable domain of the link, like "bbc.co.uk", or
+	// "self.<subreddit>" for a self post linking to its own comments
+	Domain string
+}
+
+// ParseLink canonicalizes a post's link and finds the domain it's from
+func ParseLink(post reddit.Post) Link {
+	canonical := CanonicalURL(post.URL)
+	if canonical == "" {
+		return Link{}
+	}
+	u, err := url.Parse(canonical)
+	if err != nil {
+		return Link{}
+	}
+
+	host := u.Hostname()
+	if isRedditHost(host) && post.ID != "" && u.Path == "/comments/"+post.ID {
+		return Link{URL: canonical, Domain: "self." + strings.ToLower(post.Subreddit)}
+	}
+	return Link{URL: canonical, Domain: RegistrableDomain(host)}
+}
+
+// CanonicalURL reduces a link to what it points to, so the same article
+// shared with tracking parameters or from a mobile site has one URL: the host
+// is lowercased and loses "www." and mobile subdomains, tracking parameters and
+// the fragment are dropped, the query is sorted and AMP and trailing slashes
+// are trimmed. Links to a reddit post, including relative and redd.it ones,
+// become https://reddit.com/comments/{id}. It returns "" for anything that
+// isn't an http(s) link.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "/r/") || strings.HasPrefix(raw, "/comments/") {
+		raw = "https://www.reddit.com" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ""
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, prefix := range []string{"www.", "m.", "amp.", "mobile."} {
+		// Only where it's a subdomain: amp.dev and m.me are sites of their own
+		if rest, ok := strings.CutPrefix(host, prefix); ok {
+			if _, err := publicsuffix.EffectiveTLDPlusOne(rest); err == nil {
+				host = rest
+			}
+		}
+	}
+	if isRedditHost(host) {
+		if id := redditPostID(host, u.Path); id != "" {
+			return "https://reddit.com/comments/" + id
+		}
+		if strings.HasSuffix(host, ".reddit.com") {
+			host = "reddit.com" // old., new., np. and the like
+		}
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(strings.ToLower(u.Scheme) + "://" + host)
+	b.WriteString(strings.TrimSuffix(strings.TrimSuffix(u.EscapedPath(), "/amp"), "/"))
+	for i, key := range keys {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		values := query[key]
+		sort.Strings(values)
+		for j, value := range values {
+			if j > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key) + "=" + url.QueryEscape(value))
+		}
+	}
+	return b.String()
+}
+
+// RegistrableDomain returns the domain a host was registered under, like
+// "bbc.co.uk" for "news.bbc.co.uk", going by the public suffix list. IP
+// addresses and hosts without a known suffix are returned as they are.
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+func isRedditHost(host string) bool {
+	return host == "reddit.com" || strings.HasSuffix(host, ".reddit.com") ||
+		host == "redd.it" || strings.HasSuffix(host, ".redd.it")
+}
+
+// redditPostID returns the ID of the post a reddit link points to, from
+// paths like /r/{sub}/comments/{id}/{slug} or redd.it/{id}, or ""
+func redditPostID(host, path string) string {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if host == "redd.it" {
+		if len(segments) == 1 && isPostID(segments[0]) {
+			return segments[0]
+		}
+		return ""
+	}
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "comments" && isPostID(segments[i+1]) {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
+
+// isPostID reports whether s looks like a reddit base-36 post ID
+func isPostID(s string) bool {
+	if s == "" || len(s) > 12 {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Outlet describes the publication behind a domain
+type Outlet struct {
+	Domain   string `json:"domain"`
+	Name     string `json:"name,omitempty"`
+	Bias     string `json:"bias,omitempty"`     // like "left", "center" or "right"
+	Category string `json:"category,omitempty"` // like "wire", "newspaper" or "blog"
+}
+
+// Outlets maps registrable domains to their outlets
+type Outlets map[string]Outlet
+
+// LoadOutlets reads outlet metadata from a CSV file with a header row naming
+// its columns: domain, and any of name, bias and category. Domains are
+// reduced to their registrable form. An empty path loads nothing.
+func LoadOutlets(path string) (Outlets, error) {
+	outlets := make(Outlets)
+	if path == "" {
+		return outlets, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outlets file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outlets header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["domain"]; !ok {
+		return nil, errors.New("outlets file has no domain column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outlets: %w", err)
+		}
+		domain := field(record, "domain")
+		if domain == "" {
+			continue
+		}
+		domain = RegistrableDomain(domain)
+		outlets[domain] = Outlet{
+			Domain:   domain,
+			Name:     field(record, "name"),
+			Bias:     field(record, "bias"),
+			Category: field(record, "category"),
+		}
+	}
+	return outlets, nil
+}
+
+// Lookup returns the outlet of a registrable domain, if it's known
+func (o Outlets) Lookup(domain string) (Outlet, bool) {
+	outlet, ok := o[strings.ToLower(domain)]
+	return outlet, ok
+}
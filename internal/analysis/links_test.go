@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"goreddit/internal/reddit"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		"https://www.BBC.co.uk/news/world-123?utm_source=reddit&at_medium=x": "https://bbc.co.uk/news/world-123?at_medium=x",
+		"http://m.bbc.co.uk/news/world-123/#comments":                        "http://bbc.co.uk/news/world-123",
+		"https://apnews.com/article/abc?b=2&a=1&fbclid=xyz":                  "https://apnews.com/article/abc?a=1&b=2",
+		"https://www.reddit.com/r/news/comments/1abc2d/some_title/?share=x":  "https://reddit.com/comments/1abc2d",
+		"https://old.reddit.com/r/news/":                                     "https://reddit.com/r/news",
+		"https://redd.it/1abc2d":                                             "https://reddit.com/comments/1abc2d",
+		"/r/news/comments/1abc2d/some_title/":                                "https://reddit.com/comments/1abc2d",
+		"https://i.redd.it/abc.jpeg":                                         "https://i.redd.it/abc.jpeg",
+		"https://mobile.twitter.com/user/status/1":                           "https://twitter.com/user/status/1",
+		"https://amp.dev/documentation/":                                     "https://amp.dev/documentation",
+		"https://m.me/somepage":                                              "https://m.me/somepage",
+		"https://www.m.me/somepage":                                          "https://m.me/somepage",
+		"https://m.co.uk/news":                                               "https://m.co.uk/news",
+		"ftp://example.com/file":                                             "",
+		"":                                                                   "",
+	}
+	for raw, want := range tests {
+		if got := CanonicalURL(raw); got != want {
+			t.Errorf("CanonicalURL(%q): expected %q, got %q", raw, want, got)
+		}
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		post reddit.Post
+		want Link
+	}{
+		{reddit.Post{ID: "a1", URL: "https://edition.cnn.com/2024/05/01/world/story?utm_campaign=x"},
+			Link{"https://edition.cnn.com/2024/05/01/world/story", "cnn.com"}},
+		{reddit.Post{ID: "a1", URL: "https://www.news.bbc.co.uk/story"},
+			Link{"https://news.bbc.co.uk/story", "bbc.co.uk"}},
+		{reddit.Post{ID: "a1", Subreddit: "AskReddit", URL: "https://www.reddit.com/r/AskReddit/comments/a1/why/"},
+			Link{"https://reddit.com/comments/a1", "self.askreddit"}},
+		// A cross-post links to another post
+		{reddit.Post{ID: "a1", Subreddit: "news", URL: "https://www.reddit.com/r/worldnews/comments/b2/story/"},
+			Link{"https://reddit.com/comments/b2", "reddit.com"}},
+		{reddit.Post{ID: "a1", URL: "https://i.redd.it/abc.jpeg"}, Link{"https://i.redd.it/abc.jpeg", "redd.it"}},
+		{reddit.Post{ID: "a1", URL: "http://127.0.0.1:8080/x"}, Link{"http://127.0.0.1:8080/x", "127.0.0.1"}},
+		{reddit.Post{ID: "a1", URL: "not a url"}, Link{}},
+	}
+	for _, tc := range tests {
+		if got := ParseLink(tc.post); got != tc.want {
+			t.Errorf("ParseLink(%q): expected %+v, got %+v", tc.post.URL, tc.want, got)
+		}
+	}
+}
+
+func TestLoadOutlets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outlets.csv")
+	data := "# Outlets\ndomain,bias,name\nwww.bbc.co.uk,center,BBC\napnews.com,center\n,left,Nobody\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write outlets: %v", err)
+	}
+
+	outlets, err := LoadOutlets(path)
+	if err != nil {
+		t.Fatalf("Failed to load outlets: %v", err)
+	}
+	if len(outlets) != 2 {
+		t.Errorf("Expected 2 outlets, got %+v", outlets)
+	}
+	if got, ok := outlets.Lookup("bbc.co.uk"); !ok || got != (Outlet{Domain: "bbc.co.uk", Name: "BBC", Bias: "center"}) {
+		t.Errorf("Unexpected outlet for bbc.co.uk: %+v", got)
+	}
+	if got, ok := outlets.Lookup("apnews.com"); !ok || got.Name != "" || got.Bias != "center" {
+		t.Errorf("Unexpected outlet for apnews.com: %+v", got)
+	}
+
+	if outlets, err := LoadOutlets(""); err != nil || len(outlets) != 0 {
+		t.Errorf("Expected no outlets without a file, got %+v (%v)", outlets, err)
+	}
+	if err := os.WriteFile(path, []byte("name,bias\nBBC,center\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write outlets: %v", err)
+	}
+	if _, err := LoadOutlets(path); err == nil {
+		t.Error("Expected an error for a file without a domain column")
+	}
+	if _, err := LoadOutlets(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import "fmt"
+
+// BinaryMetrics counts the outcomes of a yes/no classifier against labels
+type BinaryMetrics struct {
+	TruePositives  int `json:"true_positives"`
+	FalsePositives int `json:"false_positives"`
+	TrueNegatives  int `json:"true_negatives"`
+	FalseNegatives int `json:"false_negatives"`
+}
+
+// Add counts one prediction against its label
+func (m *BinaryMetrics) Add(predicted, actual bool) {
+	switch {
+	case predicted && actual:
+		m.TruePositives++
+	case predicted:
+		m.FalsePositives++
+	case actual:
+		m.FalseNegatives++
+	default:
+		m.TrueNegatives++
+	}
+}
+
+// Merge adds the counts of other, e.g. from another cross-validation fold
+func (m *BinaryMetrics) Merge(other BinaryMetrics) {
+	m.TruePositives += other.TruePositives
+	m.FalsePositives += other.FalsePositives
+	m.TrueNegatives += other.TrueNegatives
+	m.FalseNegatives += other.FalseNegatives
+}
+
+// Total is the number of predictions counted
+func (m BinaryMetrics) Total() int {
+	return m.TruePositives + m.FalsePositives + m.TrueNegatives + m.FalseNegatives
+}
+
+// Accuracy is the share of predictions that were right
+func (m BinaryMetrics) Accuracy() float64 {
+	return ratio(m.TruePositives+m.TrueNegatives, m.Total())
+}
+
+// Precision is the share of positive predictions that were right
+func (m BinaryMetrics) Precision() float64 {
+	return ratio(m.TruePositives, m.TruePositives+m.FalsePositives)
+}
+
+// Recall is the share of positive labels that were predicted
+func (m BinaryMetrics) Recall() float64 {
+	return ratio(m.TruePositives, m.TruePositives+m.FalseNegatives)
+}
+
+// F1 is the harmonic mean of precision and recall
+func (m BinaryMetrics) F1() float64 {
+	p, r := m.Precision(), m.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+func (m BinaryMetrics) String() string {
+	return fmt.Sprintf("n=%d accuracy=%.3f precision=%.3f recall=%.3f f1=%.3f",
+		m.Total(), m.Accuracy(), m.Precision(), m.Recall(), m.F1())
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
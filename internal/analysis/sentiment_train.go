@@ -0,0 +1,395 @@
+package analysis
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cdipaolo/goml/base"
+	"github.com/cdipaolo/goml/text"
+)
+
+// defaultFolds is the number of cross-validation folds
+const defaultFolds = 5
+
+// SentimentExample is a piece of text with a hand-assigned label, for training
+// and evaluating sentiment models
+type SentimentExample struct {
+	Text  string
+	Label Label
+}
+
+// ReadSentimentExamples reads labeled posts in the given format, "csv" or
+// "ndjson". Either way a post has a text, or a title and an optional body, and
+// a label or sentiment: positive, neutral or negative (or pos, neu, neg), or a
+// number whose sign gives the label. Column and key names are case-insensitive,
+// so posts exported as JSON with Title and Body fields can be labeled in place.
+func ReadSentimentExamples(r io.Reader, format string) ([]SentimentExample, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return readSentimentCSV(r)
+	case "ndjson", "jsonl":
+		return readSentimentNDJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown example format %q, expected csv or ndjson", format)
+	}
+}
+
+func readSentimentCSV(r io.Reader) ([]SentimentExample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	textColumns, labelColumn := sentimentColumns(func(name string) bool {
+		_, ok := columns[name]
+		return ok
+	})
+	if textColumns == nil || labelColumn == "" {
+		return nil, errors.New("CSV needs a text (or title) and a label (or sentiment) column")
+	}
+
+	var examples []SentimentExample
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		field := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		var parts []string
+		for _, name := range textColumns {
+			if value := strings.TrimSpace(field(name)); value != "" {
+				parts = append(parts, value)
+			}
+		}
+		label, err := ParseLabel(field(labelColumn))
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: %w", line, err)
+		}
+		examples = append(examples, SentimentExample{Text: strings.Join(parts, " "), Label: label})
+	}
+	return examples, nil
+}
+
+func readSentimentNDJSON(r io.Reader) ([]SentimentExample, error) {
+	var examples []SentimentExample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // self-posts can be long
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			return nil, fmt.Errorf("NDJSON line %d: %w", line, err)
+		}
+		fields := make(map[string]interface{}, len(raw))
+		for key, value := range raw {
+			fields[strings.ToLower(key)] = value
+		}
+		textKeys, labelKey := sentimentColumns(func(name string) bool {
+			_, ok := fields[name]
+			return ok
+		})
+		if textKeys == nil || labelKey == "" {
+			return nil, fmt.Errorf("NDJSON line %d: needs a text (or title) and a label (or sentiment)", line)
+		}
+
+		var parts []string
+		for _, key := range textKeys {
+			if value, _ := fields[key].(string); strings.TrimSpace(value) != "" {
+				parts = append(parts, strings.TrimSpace(value))
+			}
+		}
+		var label Label
+		var err error
+		switch value := fields[labelKey].(type) {
+		case string:
+			label, err = ParseLabel(value)
+		case float64:
+			label = labelOfSign(value)
+		default:
+			err = fmt.Errorf("label must be a string or a number, got %v", value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("NDJSON line %d: %w", line, err)
+		}
+		examples = append(examples, SentimentExample{Text: strings.Join(parts, " "), Label: label})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return examples, nil
+}
+
+// sentimentColumns picks the lowercased columns that hold the text and the
+// label of an example, given which ones are present
+func sentimentColumns(has func(string) bool) (text []string, label string) {
+	switch {
+	case has("text"):
+		text = []string{"text"}
+	case has("title"):
+		text = []string{"title"}
+		if has("body") {
+			text = append(text, "body")
+		}
+	}
+	for _, name := range []string{"label", "sentiment"} {
+		if has(name) {
+			return text, name
+		}
+	}
+	return text, ""
+}
+
+// ParseLabel reads a label by name, abbreviation or the sign of a number
+func ParseLabel(s string) (Label, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "positive", "pos":
+		return Positive, nil
+	case "neutral", "neu":
+		return Neutral, nil
+	case "negative", "neg":
+		return Negative, nil
+	}
+	var score float64
+	if _, err := fmt.Sscan(s, &score); err != nil {
+		return "", fmt.Errorf("unknown label %q", s)
+	}
+	return labelOfSign(score), nil
+}
+
+func labelOfSign(score float64) Label {
+	switch {
+	case score > 0:
+		return Positive
+	case score < 0:
+		return Negative
+	default:
+		return Neutral
+	}
+}
+
+// BayesModel is a trained naive Bayes model with what's needed to tell it
+// apart from others. Its version is recorded on every post it scores.
+type BayesModel struct {
+	Version   string    `json:"version"`
+	TrainedAt time.Time `json:"trained_at"`
+	// Examples is the number of positive and negative examples trained on
+	Examples int              `json:"examples"`
+	Model    *text.NaiveBayes `json:"model"`
+}
+
+// TrainBayesModel fits a two-class model, negative and positive, to the
+// polar examples. Neutral examples are left out: a neutral post is one the
+// model isn't sure about, which the analyzer's neutral threshold decides.
+func TrainBayesModel(examples []SentimentExample, version string) (*BayesModel, error) {
+	if version == "" {
+		return nil, errors.New("model version is required")
+	}
+	var polar []SentimentExample
+	classes := [2]int{}
+	for _, example := range examples {
+		switch example.Label {
+		case Negative:
+			classes[0]++
+		case Positive:
+			classes[1]++
+		default:
+			continue
+		}
+		polar = append(polar, example)
+	}
+	if classes[0] == 0 || classes[1] == 0 {
+		return nil, fmt.Errorf("need positive and negative examples, got %d positive and %d negative", classes[1], classes[0])
+	}
+
+	stream := make(chan base.TextDatapoint, 100)
+	errs := make(chan error)
+	model := text.NewNaiveBayes(stream, 2, base.OnlyWords)
+	model.Output = io.Discard
+	go model.OnlineLearn(errs)
+	go func() {
+		for _, example := range polar {
+			// Class 0 is negative and class 1 is positive, as in the bundled model
+			class := uint8(0)
+			if example.Label == Positive {
+				class = 1
+			}
+			stream <- base.TextDatapoint{X: bayesText(example.Text), Y: class}
+		}
+		close(stream)
+	}()
+	var trainErr error
+	for err := range errs {
+		if trainErr == nil {
+			trainErr = err
+		}
+	}
+	if trainErr != nil {
+		return nil, fmt.Errorf("failed to train sentiment model: %w", trainErr)
+	}
+
+	return &BayesModel{
+		Version:   version,
+		TrainedAt: time.Now().UTC(),
+		Examples:  len(polar),
+		Model:     model,
+	}, nil
+}
+
+// Analyzer wraps the model in a Bayes analyzer
+func (m *BayesModel) Analyzer(neutralThreshold float64) (*Bayes, error) {
+	return newBayes(m.Model, m.Version, neutralThreshold)
+}
+
+// SaveBayesModel writes a model for LoadBayesModel
+func SaveBayesModel(path string, model *BayesModel) error {
+	// The tokenizer is an interface, which can't be unmarshaled; it's restored
+	// on load instead
+	tokenizer := model.Model.Tokenizer
+	model.Model.Tokenizer = nil
+	data, err := json.Marshal(model)
+	model.Model.Tokenizer = tokenizer
+	if err != nil {
+		return fmt.Errorf("failed to encode sentiment model: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write sentiment model: %w", err)
+	}
+	return nil
+}
+
+// LoadBayesModel reads a model written by SaveBayesModel
+func LoadBayesModel(path string) (*BayesModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sentiment model: %w", err)
+	}
+	var model BayesModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse sentiment model %s: %w", path, err)
+	}
+	if model.Version == "" || model.Model == nil || len(model.Model.Probabilities) != 2 {
+		return nil, fmt.Errorf("sentiment model %s is not a two-class model with a version", path)
+	}
+	model.Model.UpdateSanitize(base.OnlyWords)
+	model.Model.UpdateTokenizer(&text.SimpleTokenizer{SplitOn: " "})
+	return &model, nil
+}
+
+// SentimentReport scores an analyzer's labels against hand-assigned ones,
+// one class against the rest
+type SentimentReport struct {
+	Classes map[Label]*BinaryMetrics
+	Correct int
+	Total   int
+}
+
+func newSentimentReport() SentimentReport {
+	return SentimentReport{Classes: map[Label]*BinaryMetrics{
+		Positive: {},
+		Neutral:  {},
+		Negative: {},
+	}}
+}
+
+func (r *SentimentReport) add(predicted, actual Label) {
+	for label, metrics := range r.Classes {
+		metrics.Add(predicted == label, actual == label)
+	}
+	if predicted == actual {
+		r.Correct++
+	}
+	r.Total++
+}
+
+// Accuracy is the share of examples labeled right
+func (r SentimentReport) Accuracy() float64 {
+	return ratio(r.Correct, r.Total)
+}
+
+// MacroF1 is the mean F1 of the classes that had examples
+func (r SentimentReport) MacroF1() float64 {
+	sum, classes := 0.0, 0
+	for _, metrics := range r.Classes {
+		if metrics.TruePositives+metrics.FalseNegatives > 0 {
+			sum += metrics.F1()
+			classes++
+		}
+	}
+	if classes == 0 {
+		return 0
+	}
+	return sum / float64(classes)
+}
+
+// EvaluateSentiment labels each example with the analyzer
+func EvaluateSentiment(a Analyzer, examples []SentimentExample) SentimentReport {
+	report := newSentimentReport()
+	for _, example := range examples {
+		report.add(a.Analyze(example.Text).Label, example.Label)
+	}
+	return report
+}
+
+// CrossValidateBayes trains a model on all but one of folds shares of the
+// examples and evaluates it on the share left out, for each share in turn.
+// The examples are shuffled with a fixed seed, so reports are comparable
+// between runs on the same file.
+func CrossValidateBayes(examples []SentimentExample, folds int, neutralThreshold float64) (SentimentReport, error) {
+	if folds <= 0 {
+		folds = defaultFolds
+	}
+	if folds < 2 || folds > len(examples) {
+		return SentimentReport{}, fmt.Errorf("can't split %d examples into %d folds", len(examples), folds)
+	}
+	shuffled := append([]SentimentExample(nil), examples...)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	report := newSentimentReport()
+	for fold := 0; fold < folds; fold++ {
+		var train, test []SentimentExample
+		for i, example := range shuffled {
+			if i%folds == fold {
+				test = append(test, example)
+			} else {
+				train = append(train, example)
+			}
+		}
+		model, err := TrainBayesModel(train, "fold")
+		if err != nil {
+			return SentimentReport{}, fmt.Errorf("fold %d: %w", fold+1, err)
+		}
+		analyzer, err := model.Analyzer(neutralThreshold)
+		if err != nil {
+			return SentimentReport{}, fmt.Errorf("fold %d: %w", fold+1, err)
+		}
+		for _, example := range test {
+			report.add(analyzer.Analyze(example.Text).Label, example.Label)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readTestSentimentExamples(t *testing.T) []SentimentExample {
+	t.Helper()
+	file, err := os.Open("testdata/sentiment_posts.csv")
+	if err != nil {
+		t.Fatalf("Failed to open labeled posts: %v", err)
+	}
+	defer file.Close()
+	examples, err := ReadSentimentExamples(file, "csv")
+	if err != nil {
+		t.Fatalf("Failed to read labeled posts: %v", err)
+	}
+	return examples
+}
+
+func TestTrainBayesModel(t *testing.T) {
+	examples := readTestSentimentExamples(t)
+
+	model, err := TrainBayesModel(examples, "test")
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	analyzer, err := model.Analyzer(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to wrap model: %v", err)
+	}
+	if analyzer.Version() != "test" || ModelID(analyzer) != "bayes:test" {
+		t.Errorf("Expected version test, got %s", ModelID(analyzer))
+	}
+	for _, example := range examples {
+		if example.Label == Neutral {
+			continue
+		}
+		if got := analyzer.Analyze(example.Text).Label; got != example.Label {
+			t.Errorf("Expected %q to be %s after training on it, got %s", example.Text, example.Label, got)
+		}
+	}
+
+	path := t.TempDir() + "/sentiment.json"
+	if err := SaveBayesModel(path, model); err != nil {
+		t.Fatalf("Failed to save model: %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.Sentiment.Model = path
+	loaded, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to load saved model: %v", err)
+	}
+	if loaded.Version() != "test" {
+		t.Errorf("Expected the saved model's version, got %s", loaded.Version())
+	}
+	for _, example := range examples[:10] {
+		if want, got := analyzer.Analyze(example.Text), loaded.Analyze(example.Text); want != got {
+			t.Errorf("Expected the loaded model to score %q %+v, got %+v", example.Text, want, got)
+		}
+	}
+
+	cfg.Sentiment.Analyzer = LexiconName
+	if _, err := New(cfg); err == nil {
+		t.Error("Expected an error for a model with the lexicon analyzer")
+	}
+	if _, err := TrainBayesModel(examples[:1], "test"); err == nil {
+		t.Error("Expected an error training on one class")
+	}
+}
+
+func TestTrainBayesModelUnbalanced(t *testing.T) {
+	// Most of the text is positive, and mentions delays more often than the
+	// few negative posts do, if less often per word, so counting words
+	// without their class's total would call a delay good news
+	var examples []SentimentExample
+	for i := 0; i < 12; i++ {
+		examples = append(examples, SentimentExample{
+			Text:  "The show started late but the cast was wonderful and everyone loved the great music",
+			Label: Positive,
+		})
+	}
+	for _, text := range []string{"Train late again", "Flight late again", "Late and cancelled"} {
+		examples = append(examples, SentimentExample{Text: text, Label: Negative})
+	}
+	model, err := TrainBayesModel(examples, "test")
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	analyzer, err := model.Analyzer(defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Failed to wrap model: %v", err)
+	}
+
+	for _, tt := range []struct {
+		text  string
+		label Label
+	}{
+		{"Late again", Negative},
+		{"Wonderful music", Positive},
+	} {
+		if got := analyzer.Analyze(tt.text); got.Label != tt.label {
+			t.Errorf("Analyze(%q): expected %s, got %+v", tt.text, tt.label, got)
+		}
+	}
+}
+
+func TestCrossValidateBayes(t *testing.T) {
+	examples := readTestSentimentExamples(t)
+
+	report, err := CrossValidateBayes(examples, 5, defaultNeutralThreshold)
+	if err != nil {
+		t.Fatalf("Cross-validation failed: %v", err)
+	}
+	t.Logf("Cross-validated accuracy %.2f, macro F1 %.2f", report.Accuracy(), report.MacroF1())
+	if report.Total != len(examples) {
+		t.Errorf("Expected every example evaluated once, got %d of %d", report.Total, len(examples))
+	}
+	for _, label := range []Label{Positive, Negative} {
+		if f1 := report.Classes[label].F1(); f1 < 0.5 {
+			t.Errorf("Expected %s F1 of at least 0.5, got %s", label, report.Classes[label])
+		}
+	}
+
+	if _, err := CrossValidateBayes(examples[:3], 5, defaultNeutralThreshold); err == nil {
+		t.Error("Expected an error with fewer examples than folds")
+	}
+}
+
+func TestReadSentimentExamples(t *testing.T) {
+	csv := "id,Title,Body,label\n1,Great news,\"Rescue, at last\",positive\n2,Storm hits,,NEG\n3,Council meets,,0\n"
+	examples, err := ReadSentimentExamples(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	want := []SentimentExample{
+		{"Great news Rescue, at last", Positive},
+		{"Storm hits", Negative},
+		{"Council meets", Neutral},
+	}
+	if len(examples) != len(want) {
+		t.Fatalf("Expected %d examples, got %+v", len(want), examples)
+	}
+	for i := range want {
+		if examples[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], examples[i])
+		}
+	}
+
+	ndjson := `{"ID":"a","Title":"Great news","Body":"","Sentiment":0.6}` + "\n\n" +
+		`{"text":"Storm hits","label":"negative"}` + "\n"
+	examples, err = ReadSentimentExamples(strings.NewReader(ndjson), "ndjson")
+	if err != nil {
+		t.Fatalf("Failed to read NDJSON: %v", err)
+	}
+	if len(examples) != 2 || examples[0] != (SentimentExample{"Great news", Positive}) || examples[1] != (SentimentExample{"Storm hits", Negative}) {
+		t.Errorf("Unexpected examples: %+v", examples)
+	}
+
+	for _, c := range []struct{ data, format string }{
+		{"text\nhello\n", "csv"},
+		{"text,label\nhello,maybe\n", "csv"},
+		{`{"text":"hello"}`, "ndjson"},
+		{`{"text":"hello","label":true}`, "ndjson"},
+		{"text,label\nhello,positive\n", "xml"},
+	} {
+		if _, err := ReadSentimentExamples(strings.NewReader(c.data), c.format); err == nil {
+			t.Errorf("Expected an error reading %q as %s", c.data, c.format)
+		}
+	}
+}
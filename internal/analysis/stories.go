@@ -0,0 +1,310 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStoryWindow     = 48 * time.Hour
+	defaultStorySimilarity = 0.5
+
+	minHashes     = 64
+	minHashBands  = 32 // of minHashes/minHashBands rows each
+	minTitleWords = 3  // titles with fewer content words only cluster by URL
+)
+
+// StoryOptions tune a StoryClusterer
+type StoryOptions struct {
+	// Window is how far apart in time posts about one story can be
+	Window time.Duration
+	// Similarity is the estimated Jaccard similarity of two titles' content
+	// words above which their posts are the same story
+	Similarity float64
+}
+
+// StoryOptionsFromConfig reads the stories section, applying defaults
+func StoryOptionsFromConfig(cfg *config.Config) StoryOptions {
+	opts := StoryOptions{
+		Window:     cfg.Stories.Window,
+		Similarity: cfg.Stories.Similarity,
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultStoryWindow
+	}
+	if opts.Similarity <= 0 || opts.Similarity > 1 {
+		opts.Similarity = defaultStorySimilarity
+	}
+	return opts
+}
+
+// StoryClusterer groups posts about the same story, like one article shared
+// to several subreddits under slightly different titles. A post joins the
+// story of a recent post that links to the same canonical URL or whose title
+// is similar enough; otherwise it starts a story of its own, whose ID is the
+// post's. Titles are compared by MinHash signatures of their content words,
+// with locality-sensitive hashing to find candidates. It's safe for
+// concurrent use.
+type StoryClusterer struct {
+	opts      StoryOptions
+	stopwords map[string]bool
+	seeds     [minHashes]uint64
+
+	mu    sync.Mutex
+	byID  map[string]*storyPost
+	byURL map[string]*storyPost
+	// bands maps each band of a signature to the posts that share it
+	bands  map[bandKey][]*storyPost
+	recent []*storyPost // in the order they were added
+}
+
+// storyPost is a recently clustered post
+type storyPost struct {
+	id        string
+	storyID   string
+	at        time.Time
+	url       string
+	signature []uint64 // nil when the title has too few words to compare
+}
+
+type bandKey struct {
+	band  int
+	value uint64
+}
+
+// NewStoryClusterer creates a clusterer with no history
+func NewStoryClusterer(opts StoryOptions) *StoryClusterer {
+	c := &StoryClusterer{
+		opts:      opts,
+		stopwords: make(map[string]bool),
+		byID:      make(map[string]*storyPost),
+		byURL:     make(map[string]*storyPost),
+		bands:     make(map[bandKey][]*storyPost),
+	}
+	// Titles are compared across languages, so any language's stopwords go
+	for _, words := range builtinStopwords() {
+		for _, word := range words {
+			c.stopwords[strings.ToLower(word)] = true
+		}
+	}
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range c.seeds {
+		seed = splitmix64(seed)
+		c.seeds[i] = seed
+	}
+	return c
+}
+
+// Assign sets the post's StoryID. A post that already has one, like a stored
+// post read back at startup or one whose story storage settled, keeps it and
+// is remembered with it for later posts.
+func (c *StoryClusterer) Assign(post *reddit.Post) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	at := time.Unix(int64(post.CreatedAt), 0)
+	c.prune(at)
+
+	// Posts are fetched again as their scores change
+	if p, ok := c.byID[post.ID]; ok {
+		if post.StoryID == "" {
+			post.StoryID = p.storyID
+		} else {
+			p.storyID = post.StoryID
+		}
+		return
+	}
+
+	p := &storyPost{
+		id:        post.ID,
+		storyID:   post.StoryID,
+		at:        at,
+		url:       storyURL(post.URL),
+		signature: c.signature(post.Title),
+	}
+	if p.storyID == "" {
+		p.storyID = c.match(p)
+	}
+	if p.storyID == "" {
+		p.storyID = post.ID
+	}
+	post.StoryID = p.storyID
+	c.add(p)
+}
+
+// match returns the story of the most similar recent post, if any is
+// similar enough
+func (c *StoryClusterer) match(p *storyPost) string {
+	if p.url != "" {
+		if other, ok := c.byURL[p.url]; ok && c.near(p, other) {
+			return other.storyID
+		}
+	}
+	if p.signature == nil {
+		return ""
+	}
+
+	var best *storyPost
+	bestSimilarity := 0.0
+	seen := make(map[*storyPost]bool)
+	rows := minHashes / minHashBands
+	for band := 0; band < minHashBands; band++ {
+		for _, other := range c.bands[bandKey{band, bandHash(p.signature[band*rows : (band+1)*rows])}] {
+			if seen[other] || !c.near(p, other) {
+				continue
+			}
+			seen[other] = true
+			similarity := signatureSimilarity(p.signature, other.signature)
+			if similarity >= c.opts.Similarity && (best == nil || similarity > bestSimilarity ||
+				similarity == bestSimilarity && other.at.Before(best.at)) {
+				best, bestSimilarity = other, similarity
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.storyID
+}
+
+func (c *StoryClusterer) near(p, other *storyPost) bool {
+	d := p.at.Sub(other.at)
+	return d <= c.opts.Window && d >= -c.opts.Window
+}
+
+func (c *StoryClusterer) add(p *storyPost) {
+	c.byID[p.id] = p
+	c.recent = append(c.recent, p)
+	if p.url != "" {
+		if other, ok := c.byURL[p.url]; !ok || p.at.After(other.at) {
+			c.byURL[p.url] = p
+		}
+	}
+	if p.signature != nil {
+		rows := minHashes / minHashBands
+		for band := 0; band < minHashBands; band++ {
+			key := bandKey{band, bandHash(p.signature[band*rows : (band+1)*rows])}
+			c.bands[key] = append(c.bands[key], p)
+		}
+	}
+}
+
+// prune forgets posts added more than a window before now. Posts arrive
+// roughly in order, so it stops at the first recent one.
+func (c *StoryClusterer) prune(now time.Time) {
+	cutoff := now.Add(-c.opts.Window)
+	n := 0
+	for n < len(c.recent) && c.recent[n].at.Before(cutoff) {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+
+	for _, p := range c.recent[:n] {
+		delete(c.byID, p.id)
+		if c.byURL[p.url] == p {
+			delete(c.byURL, p.url)
+		}
+		if p.signature == nil {
+			continue
+		}
+		rows := minHashes / minHashBands
+		for band := 0; band < minHashBands; band++ {
+			key := bandKey{band, bandHash(p.signature[band*rows : (band+1)*rows])}
+			kept := c.bands[key][:0]
+			for _, other := range c.bands[key] {
+				if other != p {
+					kept = append(kept, other)
+				}
+			}
+			if len(kept) == 0 {
+				delete(c.bands, key)
+			} else {
+				c.bands[key] = kept
+			}
+		}
+	}
+	c.recent = append(c.recent[:0], c.recent[n:]...)
+}
+
+// signature returns the MinHash signature of a title's content words, or nil
+// if it has too few of them
+func (c *StoryClusterer) signature(title string) []uint64 {
+	words := make(map[string]bool)
+	for _, tok := range tokenizeTopics(title) {
+		if tok.boundary || c.stopwords[tok.lower] {
+			continue
+		}
+		words[lemma(tok.lower)] = true
+	}
+	if len(words) < minTitleWords {
+		return nil
+	}
+
+	signature := make([]uint64, minHashes)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		base := h.Sum64()
+		for i, seed := range c.seeds {
+			if v := splitmix64(base ^ seed); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+	return signature
+}
+
+// signatureSimilarity estimates the Jaccard similarity of two word sets from
+// their MinHash signatures
+func signatureSimilarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+func bandHash(rows []uint64) uint64 {
+	h := uint64(0)
+	for _, v := range rows {
+		h = splitmix64(h ^ v)
+	}
+	return h
+}
+
+// splitmix64 is a fast, well-mixed 64-bit hash step
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// storyURL is the canonical URL of a link without its scheme, so the same
+// article shared over http and https matches. Links to reddit itself, like
+// self posts and cross-posts, return "".
+func storyURL(raw string) string {
+	_, rest, ok := strings.Cut(CanonicalURL(raw), "://")
+	if !ok {
+		return ""
+	}
+	host := rest
+	if i := strings.IndexAny(host, "/?"); i >= 0 {
+		host = host[:i]
+	}
+	if isRedditHost(host) {
+		return ""
+	}
+	return rest
+}
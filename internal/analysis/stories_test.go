@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"testing"
+	"time"
+)
+
+func TestStoryURL(t *testing.T) {
+	tests := map[string]string{
+		"https://www.bbc.co.uk/news/world-123?utm_source=reddit&at_medium=x": "bbc.co.uk/news/world-123?at_medium=x",
+		"http://m.bbc.co.uk/news/world-123/#comments":                        "bbc.co.uk/news/world-123",
+		"https://apnews.com/article/abc?b=2&a=1&fbclid=xyz":                  "apnews.com/article/abc?a=1&b=2",
+		"https://www.theguardian.com/world/2024/may/01/story/amp":            "theguardian.com/world/2024/may/01/story",
+		"https://www.reddit.com/r/news/comments/abc/some_title/":             "",
+		"https://i.redd.it/abc.jpeg":                                         "",
+		"not a url":                                                          "",
+	}
+	for raw, want := range tests {
+		if got := storyURL(raw); got != want {
+			t.Errorf("storyURL(%q): expected %q, got %q", raw, want, got)
+		}
+	}
+}
+
+func TestStoryClusterer(t *testing.T) {
+	c := NewStoryClusterer(StoryOptionsFromConfig(&config.Config{}))
+	base := float64(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix())
+
+	posts := []reddit.Post{
+		{ID: "a", Subreddit: "worldnews", CreatedAt: base, URL: "https://apnews.com/article/quake?utm_source=reddit",
+			Title: "Powerful earthquake strikes northern Japan, tsunami warning issued"},
+		// Same link, different title
+		{ID: "b", Subreddit: "news", CreatedAt: base + 600, URL: "https://www.apnews.com/article/quake",
+			Title: "Huge quake hits Japan"},
+		// Different link, similar title
+		{ID: "c", Subreddit: "japan", CreatedAt: base + 1200, URL: "https://www.nhk.or.jp/news/123",
+			Title: "Powerful earthquake strikes northern Japan; tsunami warnings issued for coast"},
+		// Unrelated
+		{ID: "d", Subreddit: "politics", CreatedAt: base + 1800, URL: "https://www.reddit.com/r/politics/comments/d/",
+			Title: "Senate passes budget bill after marathon session"},
+		// Similar title, but too long after
+		{ID: "e", Subreddit: "worldnews", CreatedAt: base + 72*3600, URL: "https://example.com/old",
+			Title: "Powerful earthquake strikes northern Japan, tsunami warning issued"},
+	}
+	want := map[string]string{"a": "a", "b": "a", "c": "a", "d": "d", "e": "e"}
+
+	for i := range posts {
+		c.Assign(&posts[i])
+		if posts[i].StoryID != want[posts[i].ID] {
+			t.Errorf("Post %s: expected story %q, got %q", posts[i].ID, want[posts[i].ID], posts[i].StoryID)
+		}
+	}
+
+	// A post fetched again keeps its story, and stored posts keep theirs
+	again := reddit.Post{ID: "d", CreatedAt: base + 1800, Title: "Senate passes budget bill after marathon session"}
+	c.Assign(&again)
+	if again.StoryID != "d" {
+		t.Errorf("Expected a refetched post to keep its story, got %q", again.StoryID)
+	}
+	stored := reddit.Post{ID: "f", CreatedAt: base + 72*3600, StoryID: "x", Title: "Senate passes budget bill after marathon session"}
+	c.Assign(&stored)
+	if stored.StoryID != "x" {
+		t.Errorf("Expected a stored post to keep its story, got %q", stored.StoryID)
+	}
+
+	// A story settled in storage replaces the one assigned, for later posts too
+	settled := reddit.Post{ID: "e", CreatedAt: base + 72*3600, StoryID: "z", Title: "Powerful earthquake strikes northern Japan, tsunami warning issued"}
+	c.Assign(&settled)
+	later := reddit.Post{ID: "g", CreatedAt: base + 72*3600 + 60, Title: "Powerful earthquake strikes northern Japan, tsunami warning issued"}
+	c.Assign(&later)
+	if later.StoryID != "z" {
+		t.Errorf("Expected a later post to join the settled story, got %q", later.StoryID)
+	}
+}
+
+func TestStoryClustererAnySimilarity(t *testing.T) {
+	// With no similarity threshold, any candidate sharing a band is the story
+	c := NewStoryClusterer(StoryOptions{Window: time.Hour})
+	base := float64(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix())
+	posts := []reddit.Post{
+		{ID: "a", CreatedAt: base, Title: "Powerful earthquake strikes northern Japan, tsunami warning issued"},
+		{ID: "b", CreatedAt: base + 60, Title: "Powerful earthquake strikes northern Japan; tsunami warnings issued for coast"},
+	}
+	for i := range posts {
+		c.Assign(&posts[i])
+	}
+	if posts[1].StoryID != "a" {
+		t.Errorf("Expected post b to join story a, got %q", posts[1].StoryID)
+	}
+}
@@ -0,0 +1,207 @@
+package analysis
+
+import (
+	"fmt"
+	"goreddit/internal/config"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	prose "github.com/jdkato/prose/v2"
+)
+
+const (
+	defaultSummaryMinLength = 1000
+	defaultSummarySentences = 3
+
+	textRankDamping    = 0.85
+	textRankIterations = 50
+	textRankTolerance  = 1e-4
+)
+
+// SummaryOptions tune a Summarizer
+type SummaryOptions struct {
+	// MinLength is the length in characters below which text isn't summarized
+	MinLength int
+	// Sentences is how many sentences a summary keeps
+	Sentences int
+}
+
+// SummaryOptionsFromConfig reads the summary section, applying defaults
+func SummaryOptionsFromConfig(cfg *config.Config) (SummaryOptions, error) {
+	opts := SummaryOptions{
+		MinLength: cfg.Summary.MinLength,
+		Sentences: cfg.Summary.Sentences,
+	}
+	if opts.MinLength < 0 || opts.Sentences < 0 {
+		return opts, fmt.Errorf("summary min_length and sentences can't be negative")
+	}
+	if opts.MinLength == 0 {
+		opts.MinLength = defaultSummaryMinLength
+	}
+	if opts.Sentences == 0 {
+		opts.Sentences = defaultSummarySentences
+	}
+	return opts, nil
+}
+
+// Summarizer picks the most central sentences of long text, TextRank style:
+// sentences are linked by the content words they share, and the ones ranked
+// highest by PageRank over those links are kept in their original order. It's
+// safe for concurrent use.
+type Summarizer struct {
+	opts      SummaryOptions
+	stopwords map[string]map[string]bool
+}
+
+// NewSummarizer creates a summarizer using the embedded stopword lists
+func NewSummarizer(opts SummaryOptions) *Summarizer {
+	s := &Summarizer{opts: opts, stopwords: make(map[string]map[string]bool)}
+	for lang, words := range builtinStopwords() {
+		set := make(map[string]bool, len(words))
+		for _, word := range words {
+			set[word] = true
+		}
+		s.stopwords[lang] = set
+	}
+	return s
+}
+
+// Version identifies the options summaries are picked with
+func (s *Summarizer) Version() string {
+	return fmt.Sprintf("%dx%d", s.opts.MinLength, s.opts.Sentences)
+}
+
+// Supports reports whether there's a stopword list for lang
+func (s *Summarizer) Supports(lang string) bool {
+	_, ok := s.stopwords[lang]
+	return ok
+}
+
+// Summarize returns the key sentences of text, or "" if it's shorter than
+// the minimum length or has no more sentences than a summary would keep
+func (s *Summarizer) Summarize(text, lang string) string {
+	if utf8.RuneCountInString(text) < s.opts.MinLength {
+		return ""
+	}
+	doc, err := prose.NewDocument(text,
+		prose.WithTokenization(false), prose.WithTagging(false), prose.WithExtraction(false))
+	if err != nil {
+		return ""
+	}
+	var sentences []string
+	for _, sentence := range doc.Sentences() {
+		if sentence := strings.TrimSpace(sentence.Text); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	if len(sentences) <= s.opts.Sentences {
+		return ""
+	}
+
+	stopwords := s.stopwords[lang]
+	if stopwords == nil {
+		stopwords = s.stopwords[DefaultLanguage]
+	}
+	words := make([]map[string]bool, len(sentences))
+	for i, sentence := range sentences {
+		words[i] = contentWords(sentence, stopwords)
+	}
+
+	scores := textRank(sentenceGraph(words))
+	ranked := make([]int, len(sentences))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return scores[ranked[a]] > scores[ranked[b]] })
+	kept := ranked[:s.opts.Sentences]
+	sort.Ints(kept)
+
+	summary := make([]string, len(kept))
+	for i, index := range kept {
+		summary[i] = sentences[index]
+	}
+	return strings.Join(summary, " ")
+}
+
+// contentWords returns the distinct lowercased words of a sentence that
+// aren't stopwords, with English plurals reduced to their singular
+func contentWords(sentence string, stopwords map[string]bool) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(sentence), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	}) {
+		word = strings.Trim(word, "'")
+		if utf8.RuneCountInString(word) < 2 || stopwords[word] {
+			continue
+		}
+		set[lemma(word)] = true
+	}
+	return set
+}
+
+// sentenceGraph weighs the link between each pair of sentences by the words
+// they share, normalized by their lengths so long sentences don't dominate
+func sentenceGraph(words []map[string]bool) [][]float64 {
+	n := len(words)
+	weights := make([][]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if len(words[i]) < 2 || len(words[j]) < 2 {
+				continue
+			}
+			shared := 0
+			for word := range words[i] {
+				if words[j][word] {
+					shared++
+				}
+			}
+			if shared == 0 {
+				continue
+			}
+			w := float64(shared) / (math.Log(float64(len(words[i]))) + math.Log(float64(len(words[j]))))
+			weights[i][j], weights[j][i] = w, w
+		}
+	}
+	return weights
+}
+
+// textRank runs weighted PageRank over the sentence graph
+func textRank(weights [][]float64) []float64 {
+	n := len(weights)
+	outWeight := make([]float64, n)
+	for i := range weights {
+		for _, w := range weights[i] {
+			outWeight[i] += w
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	next := make([]float64, n)
+	for iteration := 0; iteration < textRankIterations; iteration++ {
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 {
+					sum += weights[j][i] / outWeight[j] * scores[j]
+				}
+			}
+			next[i] = 1 - textRankDamping + textRankDamping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores, next = next, scores
+		if delta < textRankTolerance {
+			break
+		}
+	}
+	return scores
+}
@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"strings"
+	"testing"
+)
+
+const discussionPost = `The debate over sanctions on Russia keeps coming back to energy. ` +
+	`European governments cut their imports of Russian gas sharply after 2022, but Russian oil still reaches world markets through India and China. ` +
+	`Critics argue that the oil price cap has done little, since Russia sells much of its oil above the cap using a shadow fleet of tankers. ` +
+	`Supporters of the cap reply that Russian oil revenue would be far higher without it, and that enforcement against the shadow fleet is improving. ` +
+	`I grew up near the Baltic coast, so I have watched these tankers for years. ` +
+	`The weather there is usually grey in the winter. ` +
+	`Whatever one thinks of the cap, the sanctions on Russian oil and gas have reshaped energy markets and pushed Europe towards other suppliers. ` +
+	`What do you think the next round of sanctions should target?`
+
+func TestSummarizer(t *testing.T) {
+	s := NewSummarizer(SummaryOptions{MinLength: 500, Sentences: 3})
+
+	summary := s.Summarize(discussionPost, "en")
+	if summary == "" || len(summary) >= len(discussionPost) {
+		t.Fatalf("Expected a shorter summary, got %q", summary)
+	}
+	for _, offTopic := range []string{"weather", "grew up"} {
+		if strings.Contains(summary, offTopic) {
+			t.Errorf("Expected the off-topic sentence about %q to be dropped, got %q", offTopic, summary)
+		}
+	}
+	// Sentences stay in their original order
+	kept := strings.SplitAfter(summary, ". ")
+	if len(kept) != 3 || strings.Index(discussionPost, kept[0]) > strings.Index(discussionPost, kept[1]) {
+		t.Errorf("Expected 3 sentences in order, got %q", kept)
+	}
+
+	if got := s.Summarize(discussionPost[:400], "en"); got != "" {
+		t.Errorf("Expected no summary below the minimum length, got %q", got)
+	}
+	if got := NewSummarizer(SummaryOptions{MinLength: 10, Sentences: 3}).Summarize("One sentence. And another one.", "en"); got != "" {
+		t.Errorf("Expected no summary of text with few sentences, got %q", got)
+	}
+}
+
+func TestSummaryOptionsFromConfig(t *testing.T) {
+	opts, err := SummaryOptionsFromConfig(&config.Config{})
+	if err != nil || opts.MinLength != defaultSummaryMinLength || opts.Sentences != defaultSummarySentences {
+		t.Errorf("Expected defaults, got %+v (%v)", opts, err)
+	}
+	cfg := &config.Config{}
+	cfg.Summary.Sentences = -1
+	if _, err := SummaryOptionsFromConfig(cfg); err == nil {
+		t.Error("Expected an error for a negative sentence count")
+	}
+}
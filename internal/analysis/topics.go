@@ -0,0 +1,404 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"goreddit/internal/config"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultLanguage is used for text whose language isn't given
+const DefaultLanguage = "en"
+
+const (
+	minTopicLength = 3 // shorter words are dropped unless they're acronyms
+	maxPhraseWords = 3
+)
+
+//go:embed lexicon/stopwords_*.txt
+var stopwordFiles embed.FS
+
+//go:embed lexicon/phrases.txt
+var builtinPhrases []byte
+
+// builtinStopwords returns the embedded stopword lists by language code,
+// read from the lexicon/stopwords_<code>.txt files
+func builtinStopwords() map[string][]string {
+	lists := make(map[string][]string)
+	paths, _ := fs.Glob(stopwordFiles, "lexicon/stopwords_*.txt")
+	for _, path := range paths {
+		data, err := stopwordFiles.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		lang := strings.TrimSuffix(strings.TrimPrefix(path, "lexicon/stopwords_"), ".txt")
+		lists[lang] = readWordList(data)
+	}
+	return lists
+}
+
+// tokenPattern matches dotted acronyms like "U.S.", words with inner
+// apostrophes or hyphens, and the punctuation that ends a phrase
+var tokenPattern = regexp.MustCompile(`(?:\p{Lu}\.){2,}|[\p{L}\p{N}]+(?:['’-][\p{L}\p{N}]+)*|[.!?;:,()\[\]"“”|–—]`)
+
+// irregularPlurals maps plurals that the suffix rules in lemma get wrong
+var irregularPlurals = map[string]string{
+	"children": "child", "men": "man", "women": "woman", "feet": "foot",
+	"teeth": "tooth", "mice": "mouse", "geese": "goose", "lives": "life",
+	"wives": "wife", "knives": "knife", "leaves": "leaf", "wolves": "wolf",
+	"thieves": "thief", "crises": "crisis", "analyses": "analysis", "theses": "thesis",
+	"data": "data", "media": "media", "news": "news", "series": "series",
+	"species": "species",
+}
+
+// TopicExtractor turns text into topics: capitalized names and known phrases
+// like "White House" stay whole, acronyms like "EU" are kept, possessives are
+// dropped, plurals are reduced to their singular and stopwords of the text's
+// language are skipped. Case variants of a topic count once.
+type TopicExtractor struct {
+	stopwords map[string]map[string]bool
+	// phrases maps the lemma of the first word of each known phrase to the
+	// phrases starting with it
+	phrases map[string][]topicPhrase
+	version string
+}
+
+// topicPhrase is a known phrase, matched on the lemmas of its words so that
+// "interest rate" matches "interest rates"
+type topicPhrase struct {
+	lemmas []string
+	topic  string // as listed, title-cased
+}
+
+// NewTopicExtractor builds an extractor from the embedded lists and the
+// topics section of the config, which can add stopwords per language and
+// phrases, inline or from files like those cmd/collocations writes
+func NewTopicExtractor(cfg *config.Config) (*TopicExtractor, error) {
+	e := &TopicExtractor{
+		stopwords: make(map[string]map[string]bool),
+		phrases:   make(map[string][]topicPhrase),
+	}
+
+	for lang, words := range builtinStopwords() {
+		e.addStopwords(lang, words)
+	}
+	for lang, path := range cfg.Topics.StopwordFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s stopwords: %w", lang, err)
+		}
+		e.addStopwords(lang, readWordList(data))
+	}
+	for lang, words := range cfg.Topics.Stopwords {
+		e.addStopwords(lang, words)
+	}
+
+	phrases := readWordList(builtinPhrases)
+	for _, path := range cfg.Topics.PhraseFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read topic phrases: %w", err)
+		}
+		phrases = append(phrases, readWordList(data)...)
+	}
+	for _, phrase := range append(phrases, cfg.Topics.Phrases...) {
+		words := strings.Fields(strings.ToLower(phrase))
+		if len(words) < 2 || len(words) > maxPhraseWords {
+			return nil, fmt.Errorf("topic phrase %q must have 2 to %d words", phrase, maxPhraseWords)
+		}
+		p := topicPhrase{lemmas: make([]string, len(words)), topic: titleCase(phrase)}
+		for i, word := range words {
+			p.lemmas[i] = lemma(word)
+		}
+		e.phrases[p.lemmas[0]] = append(e.phrases[p.lemmas[0]], p)
+	}
+	e.version = e.fingerprint()
+	return e, nil
+}
+
+// fingerprint hashes the stopwords and phrases, which change with the config
+// and the embedded lists
+func (e *TopicExtractor) fingerprint() string {
+	var entries []string
+	for lang, words := range e.stopwords {
+		for word := range words {
+			entries = append(entries, "stopword:"+lang+":"+word)
+		}
+	}
+	for _, phrases := range e.phrases {
+		for _, p := range phrases {
+			entries = append(entries, "phrase:"+p.topic)
+		}
+	}
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Version identifies the stopwords and phrases topics are extracted with
+func (e *TopicExtractor) Version() string {
+	return e.version
+}
+
+func (e *TopicExtractor) addStopwords(lang string, words []string) {
+	lang = strings.ToLower(lang)
+	if e.stopwords[lang] == nil {
+		e.stopwords[lang] = make(map[string]bool)
+	}
+	for _, word := range words {
+		e.stopwords[lang][strings.ToLower(word)] = true
+	}
+}
+
+// readWordList reads one entry per line, skipping blanks and # comments
+func readWordList(data []byte) []string {
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words
+}
+
+// topicToken is a word, or the punctuation between phrases when boundary is set
+type topicToken struct {
+	text     string
+	lower    string
+	lemma    string
+	boundary bool
+}
+
+// Supports reports whether there's a stopword list for lang
+func (e *TopicExtractor) Supports(lang string) bool {
+	_, ok := e.stopwords[strings.ToLower(lang)]
+	return ok
+}
+
+// Extract returns the distinct topics of text in order of first mention.
+// lang selects the stopword list; languages without one fall back to English.
+func (e *TopicExtractor) Extract(text, lang string) []string {
+	stopwords, ok := e.stopwords[strings.ToLower(lang)]
+	if !ok {
+		stopwords = e.stopwords[DefaultLanguage]
+	}
+
+	tokens := tokenizeTopics(text)
+	// Headlines are often in title or all caps, where capitalization says
+	// nothing about names. Acronyms still stand out in title case, but not
+	// in all caps.
+	capsMeaningful := !mostlyCapitalized(tokens)
+	acronymsMeaningful := !mostlyUppercase(tokens)
+
+	var topics []string
+	seen := make(map[string]bool)
+	add := func(topic string) {
+		if key := strings.ToLower(topic); !seen[key] {
+			seen[key] = true
+			topics = append(topics, topic)
+		}
+	}
+
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		if tok.boundary {
+			i++
+			continue
+		}
+
+		if p := e.matchPhrase(tokens[i:]); p != nil {
+			add(p.topic)
+			i += len(p.lemmas)
+			continue
+		}
+
+		if capsMeaningful {
+			if n := properNounRun(tokens[i:], stopwords); n > 1 {
+				add(phraseTopic(tokens[i : i+n]))
+				i += n
+				continue
+			}
+		}
+		if acronymsMeaningful && isAcronym(tok.text) {
+			add(tok.text)
+			i++
+			continue
+		}
+
+		// Capitalized words in running text are names, which aren't plurals,
+		// unless they're only capitalized for starting a sentence
+		sentenceStart := i == 0 || tokens[i-1].boundary
+		lemmatize := !capsMeaningful || sentenceStart || !unicode.IsUpper([]rune(tok.text)[0])
+		if topic, ok := wordTopic(tok, stopwords, lemmatize); ok {
+			add(topic)
+		}
+		i++
+	}
+	return topics
+}
+
+// tokenizeTopics splits text into words and phrase boundaries, dropping
+// possessives and the periods of acronyms
+func tokenizeTopics(text string) []topicToken {
+	matches := tokenPattern.FindAllString(text, -1)
+	tokens := make([]topicToken, 0, len(matches))
+	for _, match := range matches {
+		if !unicode.IsLetter([]rune(match)[0]) && !unicode.IsDigit([]rune(match)[0]) {
+			tokens = append(tokens, topicToken{boundary: true})
+			continue
+		}
+		word := strings.ReplaceAll(match, "’", "'")
+		word = strings.TrimSuffix(strings.TrimSuffix(word, "'s"), "'S")
+		if strings.Contains(word, ".") {
+			word = strings.ReplaceAll(word, ".", "")
+		}
+		lower := strings.ToLower(word)
+		tokens = append(tokens, topicToken{text: word, lower: lower, lemma: lemma(lower)})
+	}
+	return tokens
+}
+
+// mostlyCapitalized reports whether most longer words start with a capital,
+// as in title case or all caps
+func mostlyCapitalized(tokens []topicToken) bool {
+	words, capitalized := 0, 0
+	for _, tok := range tokens {
+		if tok.boundary || len(tok.text) < 4 {
+			continue
+		}
+		words++
+		if unicode.IsUpper([]rune(tok.text)[0]) {
+			capitalized++
+		}
+	}
+	return words >= 3 && capitalized*10 >= words*7
+}
+
+// mostlyUppercase reports whether most longer words are in capitals, as in
+// an all caps headline
+func mostlyUppercase(tokens []topicToken) bool {
+	words, uppercase := 0, 0
+	for _, tok := range tokens {
+		if tok.boundary || len(tok.text) < 4 {
+			continue
+		}
+		words++
+		if tok.text == strings.ToUpper(tok.text) {
+			uppercase++
+		}
+	}
+	return words >= 3 && uppercase*10 >= words*7
+}
+
+// matchPhrase returns the longest known phrase starting the tokens, or nil
+func (e *TopicExtractor) matchPhrase(tokens []topicToken) *topicPhrase {
+	var best *topicPhrase
+	for i, phrase := range e.phrases[tokens[0].lemma] {
+		if best != nil && len(phrase.lemmas) <= len(best.lemmas) || len(phrase.lemmas) > len(tokens) {
+			continue
+		}
+		matched := true
+		for j, word := range phrase.lemmas {
+			if tokens[j].boundary || tokens[j].lemma != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			best = &e.phrases[tokens[0].lemma][i]
+		}
+	}
+	return best
+}
+
+// properNounRun returns how many capitalized words start the tokens, up to
+// maxPhraseWords. Stopwords end a run so that "The" isn't part of a name.
+func properNounRun(tokens []topicToken, stopwords map[string]bool) int {
+	n := 0
+	for n < len(tokens) && n < maxPhraseWords {
+		tok := tokens[n]
+		if tok.boundary || stopwords[tok.lower] || !unicode.IsUpper([]rune(tok.text)[0]) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// phraseTopic title-cases each word of a phrase, keeping acronyms as written
+func phraseTopic(tokens []topicToken) string {
+	words := make([]string, len(tokens))
+	for i, tok := range tokens {
+		if isAcronym(tok.text) {
+			words[i] = tok.text
+		} else {
+			words[i] = titleCase(tok.lower)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// wordTopic returns the topic for a single word, if it makes one
+func wordTopic(tok topicToken, stopwords map[string]bool, lemmatize bool) (string, bool) {
+	if stopwords[tok.lower] || len([]rune(tok.lower)) < minTopicLength {
+		return "", false
+	}
+	hasLetter := false
+	for _, r := range tok.lower {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+			break
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+
+	word := tok.lower
+	if lemmatize {
+		word = lemma(word)
+	}
+	if stopwords[word] {
+		return "", false
+	}
+	return titleCase(word), true
+}
+
+// lemma reduces an English plural to its singular. Third person verbs look
+// like plurals and lose their s too, so "signals" becomes "signal"; other
+// inflections are left alone, since suffix rules mangle them too often.
+func lemma(word string) string {
+	if singular, ok := irregularPlurals[word]; ok {
+		return singular
+	}
+	n := len(word)
+	switch {
+	case n <= 3:
+		return word
+	case strings.HasSuffix(word, "ies") && n > 4:
+		return word[:n-3] + "y"
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "zes"):
+		return word[:n-2]
+	// Singular words ending in s, and plurals too rare to risk mangling names
+	// like "Texas"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"),
+		strings.HasSuffix(word, "os"), strings.HasSuffix(word, "as"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:n-1]
+	default:
+		return word
+	}
+}
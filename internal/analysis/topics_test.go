@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"goreddit/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestTopicExtractor(t *testing.T, cfg *config.Config) *TopicExtractor {
+	t.Helper()
+	extractor, err := NewTopicExtractor(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create extractor: %v", err)
+	}
+	return extractor
+}
+
+func TestTopicExtractor(t *testing.T) {
+	extractor := newTestTopicExtractor(t, &config.Config{})
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			"acronyms and possessives",
+			"Russia's allies meet as the US, EU and U.N. respond",
+			[]string{"Russia", "Ally", "Meet", "US", "EU", "UN", "Respond"},
+		},
+		{
+			"phrases and names",
+			"Joe Biden says the White House will not raise interest rates",
+			[]string{"Joe Biden", "White House", "Raise", "Interest Rates"},
+		},
+		{
+			"plurals",
+			"Officials say children and families in Texas face new sanctions",
+			[]string{"Official", "Child", "Family", "Texas", "Face", "Sanction"},
+		},
+		{
+			"title case",
+			"Senate Passes Budget Bills As Protests Grow In Paris",
+			[]string{"Senate", "Pass", "Budget", "Bill", "Protest", "Grow", "Paris"},
+		},
+		{
+			"acronyms in title case",
+			"US And EU Agree New Sanctions On Russia's Oil Exports",
+			[]string{"US", "EU", "Agree", "Sanction", "Russia", "Oil", "Export"},
+		},
+		{
+			"acronym starting a title case headline",
+			"UN Security Council Meets After White House Statement",
+			[]string{"UN", "Security", "Council", "Meet", "White House", "Statement"},
+		},
+		{
+			"all caps",
+			"US AND EU AGREE NEW SANCTIONS ON RUSSIA",
+			[]string{"Agree", "Sanction", "Russia"},
+		},
+		{
+			"phrases matched on lemmas",
+			"Fed signals more interest rate hikes as student loan payments resume",
+			[]string{"Fed", "Signal", "Interest Rates", "Hike", "Student Loans", "Payment", "Resume"},
+		},
+		{
+			"case variants",
+			"Ukraine talks: UKRAINE and ukraine",
+			[]string{"Ukraine", "Talk"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractor.Extract(tt.text, DefaultLanguage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTopicExtractorConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords_de.txt")
+	if err := os.WriteFile(path, []byte("# German\nund\ndie\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write stopwords: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Topics.Stopwords = map[string][]string{"en": {"budget"}}
+	cfg.Topics.StopwordFiles = map[string]string{"de": path}
+	cfg.Topics.Phrases = []string{"Supply Chain"}
+	extractor := newTestTopicExtractor(t, cfg)
+
+	got := extractor.Extract("The budget strains the supply chain", "en")
+	if want := []string{"Strain", "Supply Chain"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	got = extractor.Extract("Die Regierung und die Opposition", "de")
+	if want := []string{"Regierung", "Opposition"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	// Languages without a list fall back to English
+	got = extractor.Extract("the budget", "xx")
+	if len(got) != 0 {
+		t.Errorf("Expected English stopwords for an unknown language, got %q", got)
+	}
+	if !extractor.Supports("fr") || extractor.Supports("xx") {
+		t.Error("Expected builtin languages to be supported and unknown ones not")
+	}
+
+	phrases := filepath.Join(t.TempDir(), "collocations.txt")
+	if err := os.WriteFile(phrases, []byte("# Learned\nrent control\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write phrases: %v", err)
+	}
+	cfg = &config.Config{}
+	cfg.Topics.PhraseFiles = []string{phrases}
+	got = newTestTopicExtractor(t, cfg).Extract("City extends rent controls", "en")
+	if want := []string{"City", "Extend", "Rent Control"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	cfg = &config.Config{}
+	cfg.Topics.Phrases = []string{"inflation"}
+	if _, err := NewTopicExtractor(cfg); err == nil {
+		t.Error("Expected an error for a one-word phrase")
+	}
+	cfg = &config.Config{}
+	cfg.Topics.StopwordFiles = map[string]string{"fr": filepath.Join(t.TempDir(), "missing.txt")}
+	if _, err := NewTopicExtractor(cfg); err == nil {
+		t.Error("Expected an error for a missing stopword file")
+	}
+}
+
+func TestCollocations(t *testing.T) {
+	extractor := newTestTopicExtractor(t, &config.Config{})
+
+	var texts []string
+	for i := 0; i < 6; i++ {
+		texts = append(texts,
+			"Senate debates new rent controls",
+			"Tenants wait on rent control vote",
+			"Wildfire smoke blankets Europe, farmers say",
+			"Farmers fear drought as prices climb",
+			// A known phrase isn't found again
+			"Markets brace as interest rates climb",
+		)
+	}
+	texts = append(texts, "Rare pair mentioned once")
+
+	got := map[string]bool{}
+	for _, c := range extractor.Collocations(texts, DefaultLanguage, CollocationOptions{}) {
+		got[c.Phrase] = true
+		if c.Count < defaultCollocationMinCount || c.PMI < defaultCollocationMinPMI {
+			t.Errorf("Expected only frequent, associated pairs, got %+v", c)
+		}
+	}
+	for _, want := range []string{"rent control", "wildfire smoke"} {
+		if !got[want] {
+			t.Errorf("Expected %q among %v", want, got)
+		}
+	}
+	for _, unwanted := range []string{"interest rate", "rare pair", "europe farmer"} {
+		if got[unwanted] {
+			t.Errorf("Expected no %q, got %v", unwanted, got)
+		}
+	}
+}
+
+func TestLemma(t *testing.T) {
+	tests := map[string]string{
+		"sanctions": "sanction", "policies": "policy", "taxes": "tax", "crises": "crisis",
+		"children": "child", "texas": "texas", "virus": "virus", "press": "press", "news": "news",
+	}
+	for word, want := range tests {
+		if got := lemma(word); got != want {
+			t.Errorf("lemma(%q): expected %q, got %q", word, want, got)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/config"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed lexicon/toxicity.txt
+var toxicityLexicon []byte
+
+// toxicityModelData is the default model, trained with cmd/toxicity on
+// testdata/toxicity.csv
+//
+//go:embed lexicon/toxicity_model.json
+var toxicityModelData []byte
+
+const (
+	defaultToxicityEpochs       = 500
+	defaultToxicityLearningRate = 0.5
+	defaultToxicityL2           = 1e-3
+	defaultToxicityMinCount     = 2
+
+	// Feature name prefixes
+	wordFeature    = "word:"
+	lexiconFeature = "lexicon:"
+	styleFeature   = "style:"
+
+	// targetWindow is how many words before a lexicon word are checked for
+	// "you", which turns a swear into an insult at the reader
+	targetWindow = 2
+)
+
+// secondPerson are the words that aim a lexicon word at the reader
+var secondPerson = map[string]bool{
+	"you": true, "your": true, "you're": true, "youre": true, "ur": true, "u": true, "yourself": true,
+}
+
+// ToxicityModel is a logistic regression over the features of a text: the
+// words it uses, how often it uses each category of the toxicity lexicon,
+// whether it aims them at the reader, and shouting.
+type ToxicityModel struct {
+	// Version identifies the model, e.g. in evaluation reports
+	Version string             `json:"version"`
+	Bias    float64            `json:"bias"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// DefaultToxicityModel returns the embedded model
+func DefaultToxicityModel() (ToxicityModel, error) {
+	return parseToxicityModel(toxicityModelData)
+}
+
+// LoadToxicityModel reads a model written by SaveToxicityModel
+func LoadToxicityModel(path string) (ToxicityModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ToxicityModel{}, fmt.Errorf("failed to read toxicity model: %w", err)
+	}
+	return parseToxicityModel(data)
+}
+
+// ToxicityModelFromConfig loads the model file named by toxicity.model, or
+// the embedded model if there's none
+func ToxicityModelFromConfig(cfg *config.Config) (ToxicityModel, error) {
+	if cfg.Toxicity.Model == "" {
+		return DefaultToxicityModel()
+	}
+	return LoadToxicityModel(cfg.Toxicity.Model)
+}
+
+func parseToxicityModel(data []byte) (ToxicityModel, error) {
+	var model ToxicityModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return ToxicityModel{}, fmt.Errorf("invalid toxicity model: %w", err)
+	}
+	if len(model.Weights) == 0 {
+		return ToxicityModel{}, errors.New("invalid toxicity model: no weights")
+	}
+	return model, nil
+}
+
+// SaveToxicityModel writes a model as indented JSON, with its weights in
+// name order so that retrained models diff cleanly
+func SaveToxicityModel(path string, model ToxicityModel) error {
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal toxicity model: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write toxicity model: %w", err)
+	}
+	return nil
+}
+
+// toxicityFeaturizer turns text into the named features a ToxicityModel weighs
+type toxicityFeaturizer struct {
+	categories map[string]string
+	stopwords  map[string]bool
+}
+
+func newToxicityFeaturizer() (*toxicityFeaturizer, error) {
+	categories, err := parseToxicityLexicon(toxicityLexicon)
+	if err != nil {
+		return nil, err
+	}
+	stopwords := make(map[string]bool)
+	for _, word := range builtinStopwords()["en"] {
+		stopwords[word] = true
+	}
+	return &toxicityFeaturizer{categories: categories, stopwords: stopwords}, nil
+}
+
+// parseToxicityLexicon reads tab-separated token/category lines, skipping comments
+func parseToxicityLexicon(data []byte) (map[string]string, error) {
+	categories := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		token, category, ok := strings.Cut(text, "\t")
+		category = strings.TrimSpace(category)
+		if !ok || category == "" {
+			return nil, fmt.Errorf("toxicity lexicon line %d: expected token and category separated by a tab", line)
+		}
+		categories[strings.ToLower(token)] = category
+	}
+	return categories, scanner.Err()
+}
+
+// features returns the features of text by name. Lexicon counts are damped
+// with log1p so that one insult repeated doesn't outweigh everything else.
+func (f *toxicityFeaturizer) features(text string) map[string]float64 {
+	features := make(map[string]float64)
+	words := tokenize(text)
+	lower := make([]string, len(words))
+	for i, word := range words {
+		lower[i] = strings.ToLower(word)
+	}
+
+	counts := make(map[string]float64)
+	caps, long := 0, 0
+	for i, word := range lower {
+		if len(word) >= 3 {
+			long++
+			if isUpper(words[i]) {
+				caps++
+			}
+		}
+		if category, ok := f.categories[word]; ok {
+			counts[lexiconFeature+category]++
+			for back := 1; back <= targetWindow && i-back >= 0; back++ {
+				if secondPerson[lower[i-back]] {
+					counts[lexiconFeature+category+"_at_reader"]++
+					break
+				}
+			}
+		}
+		if !f.stopwords[word] {
+			features[wordFeature+lemma(word)] = 1
+		}
+	}
+	for name, count := range counts {
+		features[name] = math.Log1p(count)
+	}
+
+	if long >= 3 && caps > 0 {
+		features[styleFeature+"caps"] = float64(caps) / float64(long)
+	}
+	if exclaims := strings.Count(text, "!"); exclaims > 0 {
+		features[styleFeature+"exclaims"] = math.Min(float64(exclaims), maxExclaims) / maxExclaims
+	}
+	return features
+}
+
+// ToxicityScorer scores how likely text is to be toxic: insulting,
+// dehumanizing, threatening or abusive. It runs offline and is safe for
+// concurrent use.
+type ToxicityScorer struct {
+	featurizer *toxicityFeaturizer
+	model      ToxicityModel
+}
+
+// NewToxicityScorer creates a scorer using model
+func NewToxicityScorer(model ToxicityModel) (*ToxicityScorer, error) {
+	featurizer, err := newToxicityFeaturizer()
+	if err != nil {
+		return nil, err
+	}
+	return &ToxicityScorer{featurizer: featurizer, model: model}, nil
+}
+
+// Supports reports whether lang is English, the language of the lexicon and
+// the training data
+func (s *ToxicityScorer) Supports(lang string) bool {
+	return lang == "en"
+}
+
+// Version returns the version of the scorer's model
+func (s *ToxicityScorer) Version() string {
+	return s.model.Version
+}
+
+// Score returns the probability that text is toxic, from 0 to 1
+func (s *ToxicityScorer) Score(text string) float64 {
+	z := s.model.Bias
+	for name, value := range s.featurizer.features(text) {
+		z += s.model.Weights[name] * value
+	}
+	return sigmoid(z)
+}
+
+// Evaluate scores labeled examples, counting a score of threshold or more
+// as a toxic prediction
+func (s *ToxicityScorer) Evaluate(examples []ToxicityExample, threshold float64) BinaryMetrics {
+	var metrics BinaryMetrics
+	for _, example := range examples {
+		metrics.Add(s.Score(example.Text) >= threshold, example.Toxic)
+	}
+	return metrics
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+// ToxicityExample is a text labeled toxic or not, for training and evaluation
+type ToxicityExample struct {
+	Text  string
+	Toxic bool
+}
+
+// ReadToxicityCSV reads labeled examples from CSV with a header row. The
+// text is in a "text" or "comment_text" column and the label in a "label",
+// "toxic" or "toxicity" column, as 0/1, true/false or a fraction of raters
+// where 0.5 and up is toxic, as in the Jigsaw datasets.
+func ReadToxicityCSV(r io.Reader) ([]ToxicityExample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	textColumn, labelColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "text", "comment_text":
+			textColumn = i
+		case "label", "toxic", "toxicity":
+			labelColumn = i
+		}
+	}
+	if textColumn < 0 || labelColumn < 0 {
+		return nil, errors.New("CSV needs a text and a label column")
+	}
+
+	var examples []ToxicityExample
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if textColumn >= len(record) || labelColumn >= len(record) {
+			return nil, fmt.Errorf("CSV line %d: missing columns", line)
+		}
+		toxic, err := parseToxicLabel(record[labelColumn])
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: %w", line, err)
+		}
+		examples = append(examples, ToxicityExample{Text: record[textColumn], Toxic: toxic})
+	}
+	return examples, nil
+}
+
+func parseToxicLabel(label string) (bool, error) {
+	if toxic, err := strconv.ParseBool(strings.TrimSpace(label)); err == nil {
+		return toxic, nil
+	}
+	share, err := strconv.ParseFloat(strings.TrimSpace(label), 64)
+	if err != nil || share < 0 || share > 1 {
+		return false, fmt.Errorf("invalid label %q: expected 0/1, true/false or a fraction", label)
+	}
+	return share >= 0.5, nil
+}
+
+// ToxicityTrainOptions tune TrainToxicityModel; zero values take defaults
+type ToxicityTrainOptions struct {
+	Version      string
+	Epochs       int
+	LearningRate float64
+	// L2 is the regularization strength, which keeps rare words from
+	// getting large weights
+	L2 float64
+	// MinCount is how many examples a word must appear in to get a weight
+	MinCount int
+}
+
+// TrainToxicityModel fits a model to labeled examples by batch gradient
+// descent on the L2-regularized log loss
+func TrainToxicityModel(examples []ToxicityExample, opts ToxicityTrainOptions) (ToxicityModel, error) {
+	if len(examples) == 0 {
+		return ToxicityModel{}, errors.New("no examples to train on")
+	}
+	if opts.Epochs <= 0 {
+		opts.Epochs = defaultToxicityEpochs
+	}
+	if opts.LearningRate <= 0 {
+		opts.LearningRate = defaultToxicityLearningRate
+	}
+	if opts.L2 <= 0 {
+		opts.L2 = defaultToxicityL2
+	}
+	if opts.MinCount <= 0 {
+		opts.MinCount = defaultToxicityMinCount
+	}
+
+	featurizer, err := newToxicityFeaturizer()
+	if err != nil {
+		return ToxicityModel{}, err
+	}
+
+	// Number the features, leaving out rare words
+	vectors := make([]map[string]float64, len(examples))
+	documents := make(map[string]int)
+	for i, example := range examples {
+		vectors[i] = featurizer.features(example.Text)
+		for name := range vectors[i] {
+			documents[name]++
+		}
+	}
+	var names []string
+	for name, count := range documents {
+		if !strings.HasPrefix(name, wordFeature) || count >= opts.MinCount {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	type entry struct {
+		feature int
+		value   float64
+	}
+	rows := make([][]entry, len(examples))
+	for i, vector := range vectors {
+		for name, value := range vector {
+			if feature, ok := index[name]; ok {
+				rows[i] = append(rows[i], entry{feature, value})
+			}
+		}
+	}
+
+	weights := make([]float64, len(names))
+	gradient := make([]float64, len(names))
+	bias, n := 0.0, float64(len(examples))
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		for i := range gradient {
+			gradient[i] = 0
+		}
+		biasGradient := 0.0
+		for i, row := range rows {
+			z := bias
+			for _, e := range row {
+				z += weights[e.feature] * e.value
+			}
+			residual := sigmoid(z)
+			if examples[i].Toxic {
+				residual--
+			}
+			biasGradient += residual
+			for _, e := range row {
+				gradient[e.feature] += residual * e.value
+			}
+		}
+		bias -= opts.LearningRate * biasGradient / n
+		for i := range weights {
+			weights[i] -= opts.LearningRate * (gradient[i]/n + opts.L2*weights[i])
+		}
+	}
+
+	model := ToxicityModel{Version: opts.Version, Bias: round4(bias), Weights: make(map[string]float64)}
+	for i, name := range names {
+		if w := round4(weights[i]); w != 0 {
+			model.Weights[name] = w
+		}
+	}
+	return model, nil
+}
+
+func round4(x float64) float64 {
+	return math.Round(x*1e4) / 1e4
+}
@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"goreddit/internal/reddit"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestToxicityScorer(t *testing.T) *ToxicityScorer {
+	t.Helper()
+	model, err := DefaultToxicityModel()
+	if err != nil {
+		t.Fatalf("Failed to load toxicity model: %v", err)
+	}
+	scorer, err := NewToxicityScorer(model)
+	if err != nil {
+		t.Fatalf("Failed to create toxicity scorer: %v", err)
+	}
+	return scorer
+}
+
+func readTestToxicityExamples(t *testing.T) []ToxicityExample {
+	t.Helper()
+	file, err := os.Open("testdata/toxicity.csv")
+	if err != nil {
+		t.Fatalf("Failed to open labeled examples: %v", err)
+	}
+	defer file.Close()
+	examples, err := ReadToxicityCSV(file)
+	if err != nil {
+		t.Fatalf("Failed to read labeled examples: %v", err)
+	}
+	return examples
+}
+
+func TestToxicityScorer(t *testing.T) {
+	scorer := newTestToxicityScorer(t)
+
+	// News about violence isn't toxic; abuse aimed at people is
+	tests := map[string]bool{
+		"Police shot and killed a suspect after a standoff":       false,
+		"The army vowed to destroy the remaining rebel positions": false,
+		"I disagree with the senator, but she makes a fair point": false,
+		// Addressing the reader isn't toxic on its own
+		"Your guide to the 2024 budget proposal":             false,
+		"Do you believe the polls this year?":                false,
+		"What do you think about the new housing bill?":      false,
+		"Should you refinance before rates rise again?":      false,
+		"Your weekend forecast: sun, then storms":            false,
+		"You are a pathetic idiot and nobody wants you here": true,
+		"Shut up, you brainwashed moron":                     true,
+		"These people are vermin":                            true,
+		"What a fucking clown":                               true,
+	}
+	for text, toxic := range tests {
+		score := scorer.Score(text)
+		if score < 0 || score > 1 || (score >= reddit.ToxicThreshold) != toxic {
+			t.Errorf("Expected %q to be toxic %v, got %v", text, toxic, score)
+		}
+	}
+
+	metrics := scorer.Evaluate(readTestToxicityExamples(t), reddit.ToxicThreshold)
+	if metrics.Total() < 150 || metrics.F1() < 0.9 {
+		t.Errorf("Expected F1 of at least 0.9 on the labeled examples, got %s", metrics)
+	}
+}
+
+func TestTrainToxicityModel(t *testing.T) {
+	examples := readTestToxicityExamples(t)
+
+	// Train on every other example and evaluate on the rest
+	var train, test []ToxicityExample
+	for i, example := range examples {
+		if i%2 == 0 {
+			train = append(train, example)
+		} else {
+			test = append(test, example)
+		}
+	}
+	model, err := TrainToxicityModel(train, ToxicityTrainOptions{Version: "test"})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if model.Version != "test" || model.Weights[lexiconFeature+"insult"] <= 0 {
+		t.Errorf("Expected a positive weight for insults, got %+v", model)
+	}
+	scorer, err := NewToxicityScorer(model)
+	if err != nil {
+		t.Fatalf("Failed to create toxicity scorer: %v", err)
+	}
+	if metrics := scorer.Evaluate(test, reddit.ToxicThreshold); metrics.F1() < 0.8 {
+		t.Errorf("Expected F1 of at least 0.8 on held-out examples, got %s", metrics)
+	}
+
+	path := t.TempDir() + "/model.json"
+	if err := SaveToxicityModel(path, model); err != nil {
+		t.Fatalf("Failed to save model: %v", err)
+	}
+	loaded, err := LoadToxicityModel(path)
+	if err != nil || loaded.Version != model.Version || len(loaded.Weights) != len(model.Weights) {
+		t.Errorf("Expected the saved model back, got %+v (%v)", loaded, err)
+	}
+
+	if _, err := TrainToxicityModel(nil, ToxicityTrainOptions{}); err == nil {
+		t.Error("Expected an error training on no examples")
+	}
+}
+
+func TestReadToxicityCSV(t *testing.T) {
+	examples, err := ReadToxicityCSV(strings.NewReader("id,comment_text,toxic\n1,\"you, idiot\",0.8\n2,hello,0\n3,bye,false\n"))
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(examples) != 3 || examples[0].Text != "you, idiot" || !examples[0].Toxic || examples[1].Toxic || examples[2].Toxic {
+		t.Errorf("Unexpected examples: %+v", examples)
+	}
+
+	for _, data := range []string{"text\nhello\n", "text,label\nhello,maybe\n", "text,label\nhello,2\n"} {
+		if _, err := ReadToxicityCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected an error reading %q", data)
+		}
+	}
+}
+
+func TestBinaryMetrics(t *testing.T) {
+	var m BinaryMetrics
+	for _, outcome := range [][2]bool{{true, true}, {true, true}, {true, false}, {false, true}, {false, false}} {
+		m.Add(outcome[0], outcome[1])
+	}
+	if m.Total() != 5 || m.Accuracy() != 0.6 || m.Precision() != 2.0/3 || m.Recall() != 2.0/3 || m.F1() != 2.0/3 {
+		t.Errorf("Unexpected metrics: %s", m)
+	}
+	m.Merge(BinaryMetrics{TrueNegatives: 5})
+	if m.Total() != 10 || m.Accuracy() != 0.8 {
+		t.Errorf("Unexpected merged metrics: %s", m)
+	}
+	if (BinaryMetrics{}).F1() != 0 {
+		t.Error("Expected F1 of no predictions to be 0")
+	}
+}
@@ -0,0 +1,309 @@
+package analysis
+
+import (
+	"fmt"
+	"goreddit/internal/config"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTrendBucket    = 5 * time.Minute
+	defaultTrendWindow    = 15 * time.Minute
+	defaultTrendBaseline  = 6 * time.Hour
+	defaultTrendThreshold = 3.0
+	defaultTrendMinPosts  = 3
+)
+
+// Trend is a topic mentioned unusually often in the current window
+type Trend struct {
+	Topic string `json:"topic"`
+	// Score is how many standard deviations the window's count is above the
+	// topic's baseline
+	Score float64 `json:"score"`
+	// Posts is how many posts mentioned the topic in the current window
+	Posts int `json:"posts"`
+	// Expected is the baseline number of posts per window
+	Expected float64   `json:"expected"`
+	Since    time.Time `json:"since"`
+}
+
+// TrendOptions tune a TrendDetector
+type TrendOptions struct {
+	// Bucket is the granularity counts are kept at
+	Bucket time.Duration
+	// Window is the recent span compared against the baseline
+	Window time.Duration
+	// Baseline is the span before the window that sets a topic's normal rate
+	Baseline time.Duration
+	// Threshold is the score a topic needs to start trending. It stops
+	// trending when its score falls under half of it.
+	Threshold float64
+	// MinPosts is the fewest posts in the window a trending topic can have
+	MinPosts int
+}
+
+// TrendOptionsFromConfig reads the trends section, applying defaults
+func TrendOptionsFromConfig(cfg *config.Config) (TrendOptions, error) {
+	t := cfg.Trends
+	opts := TrendOptions{
+		Bucket:    t.Bucket,
+		Window:    t.Window,
+		Baseline:  t.Baseline,
+		Threshold: t.Threshold,
+		MinPosts:  t.MinPosts,
+	}
+	if opts.Bucket <= 0 {
+		opts.Bucket = defaultTrendBucket
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultTrendWindow
+	}
+	if opts.Baseline <= 0 {
+		opts.Baseline = defaultTrendBaseline
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultTrendThreshold
+	}
+	if opts.MinPosts <= 0 {
+		opts.MinPosts = defaultTrendMinPosts
+	}
+
+	if opts.Bucket < time.Second || opts.Bucket%time.Second != 0 {
+		return opts, fmt.Errorf("trends.bucket must be a whole number of seconds")
+	}
+	if opts.Window%opts.Bucket != 0 || opts.Baseline%opts.Bucket != 0 {
+		return opts, fmt.Errorf("trends.window and trends.baseline must be multiples of trends.bucket (%v)", opts.Bucket)
+	}
+	return opts, nil
+}
+
+// TrendDetector finds bursting topics. It counts posts per topic in time
+// buckets and scores the count over the latest window against the mean and
+// variance of the same topic's buckets in the baseline before it, so that
+// topics that are always busy only trend when they're busier than usual.
+// It's safe for concurrent use.
+type TrendDetector struct {
+	opts     TrendOptions
+	window   int64 // buckets in the window
+	baseline int64 // buckets in the baseline
+
+	mu sync.Mutex
+	// buckets is a ring of topic counts, indexed by bucket number modulo its length
+	buckets []map[string]int
+	// names keeps the first spelling of each topic, keyed in lower case
+	names map[string]string
+	// posts maps the IDs of the posts counted to their bucket, so a post
+	// seeded from storage and delivered again is counted once
+	posts    map[string]int64
+	first    int64 // oldest bucket observed, -1 before any
+	newest   int64
+	trending map[string]Trend
+}
+
+// NewTrendDetector creates a detector with no history
+func NewTrendDetector(opts TrendOptions) *TrendDetector {
+	window := int64(opts.Window / opts.Bucket)
+	baseline := int64(opts.Baseline / opts.Bucket)
+	buckets := make([]map[string]int, window+baseline)
+	for i := range buckets {
+		buckets[i] = make(map[string]int)
+	}
+	return &TrendDetector{
+		opts:     opts,
+		window:   window,
+		baseline: baseline,
+		buckets:  buckets,
+		names:    make(map[string]string),
+		posts:    make(map[string]int64),
+		first:    -1,
+		trending: make(map[string]Trend),
+	}
+}
+
+// Observe counts the topics of post id at the time it was created and
+// reports whether the set of trending topics changed. Posts older than the
+// window and baseline are ignored, as are posts already counted.
+func (d *TrendDetector) Observe(id string, topics []string, at time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := d.bucketOf(at)
+	changed := d.advance(n, at)
+	if n <= d.newest-int64(len(d.buckets)) {
+		return changed
+	}
+	if _, ok := d.posts[id]; ok {
+		return changed
+	}
+	d.posts[id] = n
+	if n < d.first {
+		d.first = n
+	}
+
+	bucket := d.buckets[d.slot(n)]
+	seen := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		key := strings.ToLower(topic)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := d.names[key]; !ok {
+			d.names[key] = topic
+		}
+		bucket[key]++
+		if d.update(key, at) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Refresh moves the window up to now, so that topics stop trending when
+// posts stop coming, and reports whether the set of trending topics changed
+func (d *TrendDetector) Refresh(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.advance(d.bucketOf(now), now)
+}
+
+// Trending returns the trending topics, highest score first
+func (d *TrendDetector) Trending() []Trend {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	trends := make([]Trend, 0, len(d.trending))
+	for _, trend := range d.trending {
+		trends = append(trends, trend)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Topic < trends[j].Topic
+	})
+	return trends
+}
+
+func (d *TrendDetector) bucketOf(t time.Time) int64 {
+	return t.Unix() / int64(d.opts.Bucket/time.Second)
+}
+
+func (d *TrendDetector) slot(n int64) int {
+	return int(n % int64(len(d.buckets)))
+}
+
+// advance makes bucket n the newest, clearing the buckets it reuses and
+// rescoring the trending topics. It does nothing for older buckets.
+func (d *TrendDetector) advance(n int64, now time.Time) bool {
+	if d.first < 0 {
+		d.first, d.newest = n, n
+		return false
+	}
+	if n <= d.newest {
+		return false
+	}
+
+	cleared := n - d.newest
+	if cleared > int64(len(d.buckets)) {
+		cleared = int64(len(d.buckets))
+	}
+	for i := int64(0); i < cleared; i++ {
+		bucket := d.buckets[d.slot(n-i)]
+		for key := range bucket {
+			delete(bucket, key)
+		}
+	}
+	d.newest = n
+
+	changed := false
+	for key := range d.trending {
+		if d.update(key, now) {
+			changed = true
+		}
+	}
+
+	// Forget spellings of topics that no bucket counts anymore, and the
+	// posts of the buckets cleared
+	if len(d.names) > 0 && cleared > 0 {
+		for key := range d.names {
+			if !d.counted(key) {
+				delete(d.names, key)
+			}
+		}
+	}
+	for id, bucket := range d.posts {
+		if bucket <= n-int64(len(d.buckets)) {
+			delete(d.posts, id)
+		}
+	}
+	return changed
+}
+
+func (d *TrendDetector) counted(key string) bool {
+	for _, bucket := range d.buckets {
+		if bucket[key] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// update rescores a topic, starting or ending its trend, and reports whether
+// it started or ended
+func (d *TrendDetector) update(key string, now time.Time) bool {
+	score, posts, expected, ok := d.score(key)
+	trend, trending := d.trending[key]
+
+	switch {
+	case !trending && ok && posts >= d.opts.MinPosts && score >= d.opts.Threshold:
+		d.trending[key] = Trend{Topic: d.names[key], Score: score, Posts: posts, Expected: expected, Since: now}
+		return true
+	case trending && (!ok || posts < d.opts.MinPosts || score < d.opts.Threshold/2):
+		delete(d.trending, key)
+		return true
+	case trending:
+		trend.Score, trend.Posts, trend.Expected = score, posts, expected
+		d.trending[key] = trend
+	}
+	return false
+}
+
+// score compares a topic's posts in the window with its baseline. Buckets
+// from before the detector's first observation don't count toward the
+// baseline, and ok is false until it has seen half of one.
+func (d *TrendDetector) score(key string) (score float64, posts int, expected float64, ok bool) {
+	windowStart := d.newest - d.window + 1
+	for n := windowStart; n <= d.newest; n++ {
+		posts += d.buckets[d.slot(n)][key]
+	}
+
+	baselineStart := windowStart - d.baseline
+	if baselineStart < d.first {
+		baselineStart = d.first
+	}
+	buckets := windowStart - baselineStart
+	if buckets <= 0 || buckets*2 < d.baseline {
+		return 0, posts, 0, false
+	}
+
+	var sum, sumSquares float64
+	for n := baselineStart; n < windowStart; n++ {
+		count := float64(d.buckets[d.slot(n)][key])
+		sum += count
+		sumSquares += count * count
+	}
+	mean := sum / float64(buckets)
+	variance := sumSquares/float64(buckets) - mean*mean
+
+	// Counts are at least as noisy as a Poisson process, and a topic never
+	// seen before is treated as if it had one mention in the baseline
+	rate := math.Max(math.Max(variance, mean), 1/float64(d.baseline))
+	expected = mean * float64(d.window)
+	score = (float64(posts) - expected) / math.Sqrt(rate*float64(d.window))
+	return score, posts, expected, true
+}
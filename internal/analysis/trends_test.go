@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"fmt"
+	"goreddit/internal/config"
+	"testing"
+	"time"
+)
+
+func newTestTrendDetector(t *testing.T) *TrendDetector {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Trends.Bucket = time.Minute
+	cfg.Trends.Window = 5 * time.Minute
+	cfg.Trends.Baseline = time.Hour
+	opts, err := TrendOptionsFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("Invalid options: %v", err)
+	}
+	return NewTrendDetector(opts)
+}
+
+func TestTrendDetector(t *testing.T) {
+	d := newTestTrendDetector(t)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// An hour in which "Ukraine" is always busy and "Tax" comes up now and then
+	for m := 0; m < 60; m++ {
+		at := start.Add(time.Duration(m) * time.Minute)
+		for i := 0; i < 4+m%3; i++ {
+			d.Observe(fmt.Sprintf("u%d-%d", m, i), []string{"Ukraine"}, at)
+		}
+		if m%20 == 0 {
+			d.Observe(fmt.Sprintf("t%d", m), []string{"Tax"}, at)
+		}
+	}
+	if trending := d.Trending(); len(trending) != 0 {
+		t.Fatalf("Expected nothing trending at baseline rates, got %+v", trending)
+	}
+
+	// Then a burst of "Ceasefire" posts, with "Ukraine" at its usual rate
+	burst := start.Add(61 * time.Minute)
+	changed := false
+	for i := 0; i < 8; i++ {
+		if d.Observe(fmt.Sprintf("c%d", i), []string{"Ceasefire", "ukraine", "Ceasefire"}, burst) {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Error("Expected Observe to report the new trend")
+	}
+	trending := d.Trending()
+	if len(trending) != 1 || trending[0].Topic != "Ceasefire" || trending[0].Posts != 8 || trending[0].Score < 3 {
+		t.Fatalf("Expected only Ceasefire to trend, got %+v", trending)
+	}
+	if !trending[0].Since.Equal(burst) {
+		t.Errorf("Expected the trend to start at %v, got %v", burst, trending[0].Since)
+	}
+
+	// Once the burst leaves the window the topic stops trending
+	if !d.Refresh(burst.Add(10 * time.Minute)) {
+		t.Error("Expected Refresh to report the ended trend")
+	}
+	if trending := d.Trending(); len(trending) != 0 {
+		t.Errorf("Expected nothing trending after the burst, got %+v", trending)
+	}
+}
+
+func TestTrendDetectorNeedsHistory(t *testing.T) {
+	d := newTestTrendDetector(t)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Without a baseline every topic would look like a burst
+	for i := 0; i < 10; i++ {
+		d.Observe(fmt.Sprintf("e%d", i), []string{"Election"}, now)
+	}
+	if trending := d.Trending(); len(trending) != 0 {
+		t.Errorf("Expected nothing trending without a baseline, got %+v", trending)
+	}
+
+	// Posts older than the baseline are ignored
+	d.Refresh(now.Add(2 * time.Hour))
+	if d.Observe("old", []string{"Election"}, now) {
+		t.Error("Expected an old post not to change anything")
+	}
+}
+
+func TestTrendDetectorCountsPostsOnce(t *testing.T) {
+	d := newTestTrendDetector(t)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for m := 0; m < 60; m++ {
+		d.Observe(fmt.Sprintf("u%d", m), []string{"Ukraine"}, start.Add(time.Duration(m)*time.Minute))
+	}
+
+	// The same posts delivered again aren't a burst
+	for m := 0; m < 60; m++ {
+		d.Observe(fmt.Sprintf("u%d", m), []string{"Ukraine"}, start.Add(time.Duration(m)*time.Minute))
+	}
+	if trending := d.Trending(); len(trending) != 0 {
+		t.Errorf("Expected redelivered posts not to trend, got %+v", trending)
+	}
+
+	// Posts are forgotten with their bucket
+	d.Refresh(start.Add(3 * time.Hour))
+	if len(d.posts) != 0 {
+		t.Errorf("Expected expired posts to be forgotten, %d left", len(d.posts))
+	}
+}
+
+func TestTrendOptionsFromConfig(t *testing.T) {
+	opts, err := TrendOptionsFromConfig(&config.Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error for defaults: %v", err)
+	}
+	if opts.Bucket != defaultTrendBucket || opts.Window != defaultTrendWindow || opts.MinPosts != defaultTrendMinPosts {
+		t.Errorf("Unexpected defaults: %+v", opts)
+	}
+
+	cfg := &config.Config{}
+	cfg.Trends.Window = 7 * time.Minute
+	if _, err := TrendOptionsFromConfig(cfg); err == nil {
+		t.Error("Expected an error for a window that isn't a multiple of the bucket")
+	}
+}
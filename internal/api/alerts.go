@@ -0,0 +1,206 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/alerts"
+	"goreddit/internal/storage"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRuleSize bounds the body of a rule sent to the API
+const maxRuleSize = 64 << 10
+
+// alertRulesResponse is the body returned by /api/alerts/rules
+type alertRulesResponse struct {
+	Count int           `json:"count"`
+	Rules []alerts.Rule `json:"rules"`
+}
+
+// handleAlertRules lists the alert rules, from the config and the API, or
+// creates one from the rule in a POST body
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, ok := s.loadAlertRules(w, r)
+		if !ok {
+			return
+		}
+		if !s.authorizedForRules(r) {
+			for i := range rules {
+				rules[i] = rules[i].Redacted()
+			}
+		}
+		writeJSON(w, http.StatusOK, alertRulesResponse{Count: len(rules), Rules: rules})
+	case http.MethodPost:
+		if !s.authorizeRuleChange(w, r) {
+			return
+		}
+		rule, ok := s.readRule(w, r)
+		if !ok {
+			return
+		}
+		rules, ok := s.loadAlertRules(w, r)
+		if !ok {
+			return
+		}
+		if existing, found := findRule(rules, rule.ID); found {
+			writeError(w, http.StatusConflict, fmt.Sprintf("rule %s already exists in the %s", rule.ID, existing.Source))
+			return
+		}
+		s.saveAlertRule(w, r, rule, http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAlertRule reads, creates or replaces (PUT) or deletes the alert rule
+// at /api/alerts/rules/{id}. Rules defined in the config are read-only.
+func (s *Server) handleAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/alerts/rules/"), "/")
+	if id == "" {
+		s.handleAlertRules(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && !s.authorizeRuleChange(w, r) {
+		return
+	}
+
+	rules, ok := s.loadAlertRules(w, r)
+	if !ok {
+		return
+	}
+	existing, found := findRule(rules, id)
+	if found && existing.Source == alerts.SourceConfig && r.Method != http.MethodGet {
+		writeError(w, http.StatusConflict, fmt.Sprintf("rule %s is defined in the config and can't be changed here", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("no rule %s", id))
+			return
+		}
+		if !s.authorizedForRules(r) {
+			existing = existing.Redacted()
+		}
+		writeJSON(w, http.StatusOK, existing)
+	case http.MethodPut:
+		rule, ok := s.readRule(w, r)
+		if !ok {
+			return
+		}
+		if rule.ID != id {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("rule id %q doesn't match the URL", rule.ID))
+			return
+		}
+		status := http.StatusOK
+		if !found {
+			status = http.StatusCreated
+		}
+		s.saveAlertRule(w, r, rule, status)
+	case http.MethodDelete:
+		if err := s.store.DeleteAlertRule(r.Context(), id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// loadAlertRules reads the config and stored rules, writing an error if it can't
+func (s *Server) loadAlertRules(w http.ResponseWriter, r *http.Request) ([]alerts.Rule, bool) {
+	configRules, err := alerts.RulesFromConfig(s.cfg)
+	if err != nil {
+		log.Printf("API: Invalid alert rules in config: %v", err)
+		writeError(w, http.StatusInternalServerError, "invalid alert rules in config")
+		return nil, false
+	}
+	rules, err := alerts.LoadRules(r.Context(), configRules, s.store)
+	if err != nil {
+		writeStoreError(w, err)
+		return nil, false
+	}
+	return rules, true
+}
+
+// saveAlertRule stores a rule and writes it back with the given status.
+// Consumers pick it up when they next reload their rules.
+func (s *Server) saveAlertRule(w http.ResponseWriter, r *http.Request, rule alerts.Rule, status int) {
+	definition, err := json.Marshal(rule)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if err := s.store.SaveAlertRule(r.Context(), storage.AlertRule{
+		ID:         rule.ID,
+		Definition: definition,
+		UpdatedAt:  time.Now(),
+	}); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	rule.Source = alerts.SourceAPI
+	writeJSON(w, status, rule)
+}
+
+// authorizedForRules reports whether a request carries the alerts.api_token
+// as a bearer token
+func (s *Server) authorizedForRules(r *http.Request) bool {
+	token := s.cfg.Alerts.APIToken
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// authorizeRuleChange checks that a request may change rules, writing an
+// error if not. Without an alerts.api_token rules can't be changed at all,
+// since their webhooks are requests the consumers make on anyone's behalf.
+func (s *Server) authorizeRuleChange(w http.ResponseWriter, r *http.Request) bool {
+	if s.cfg.Alerts.APIToken == "" {
+		writeError(w, http.StatusForbidden, "changing alert rules is disabled; set alerts.api_token to enable it")
+		return false
+	}
+	if !s.authorizedForRules(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="alert rules"`)
+		writeError(w, http.StatusUnauthorized, "a valid bearer token is required")
+		return false
+	}
+	return true
+}
+
+// readRule parses and validates the rule in a request body, writing an error
+// if it's invalid or, unless alerts.allow_private_webhooks is set, posts to
+// an internal address
+func (s *Server) readRule(w http.ResponseWriter, r *http.Request) (alerts.Rule, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRuleSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read rule")
+		return alerts.Rule{}, false
+	}
+	rule, err := alerts.ParseRule(data)
+	if err == nil && !s.cfg.Alerts.AllowPrivateWebhooks {
+		err = rule.CheckPublicWebhooks()
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return alerts.Rule{}, false
+	}
+	return rule, true
+}
+
+func findRule(rules []alerts.Rule, id string) (alerts.Rule, bool) {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return alerts.Rule{}, false
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"goreddit/internal/alerts"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleAlertRules(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.Alerts.APIToken = "secret"
+	s.cfg.Alerts.Rules = []map[string]interface{}{{
+		"id":       "quakes",
+		"when":     map[string]interface{}{"keyword": "earthquake"},
+		"webhooks": []interface{}{"https://hooks.example.com/a"},
+	}}
+
+	doAs := func(token, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if path == "/api/alerts/rules" {
+			s.handleAlertRules(rec, req)
+		} else {
+			s.handleAlertRule(rec, req)
+		}
+		return rec
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return doAs("secret", method, path, body)
+	}
+
+	rule := `{"id": "japan", "when": {"entity": "Japan"}, "webhooks": ["https://hooks.example.com/b"]}`
+	if rec := do(http.MethodPost, "/api/alerts/rules", rule); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/alerts/rules", rule); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an existing rule, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/alerts/rules", `{"id": "x", "when": {}, "webhooks": ["https://a.example"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid rule, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/api/alerts/rules", "")
+	var list alertRulesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 2 || list.Rules[0].ID != "japan" || list.Rules[0].Source != alerts.SourceAPI || list.Rules[1].Source != alerts.SourceConfig {
+		t.Errorf("Unexpected rules: %+v", list.Rules)
+	}
+
+	updated := `{"id": "japan", "name": "Japan", "when": {"entity": "Japan"}, "webhooks": ["https://hooks.example.com/b"], "max_per_hour": 5}`
+	if rec := do(http.MethodPut, "/api/alerts/rules/japan", updated); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a replaced rule, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(http.MethodGet, "/api/alerts/rules/japan", "")
+	var got alerts.Rule
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.Name != "Japan" || got.MaxPerHour != 5 {
+		t.Errorf("Unexpected rule: %+v (%v)", got, err)
+	}
+	if rec := do(http.MethodPut, "/api/alerts/rules/other", updated); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a mismatched id, got %d", rec.Code)
+	}
+
+	// Config rules are read-only
+	if rec := do(http.MethodDelete, "/api/alerts/rules/quakes", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a config rule, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/alerts/rules/japan", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/alerts/rules/japan", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted rule, got %d", rec.Code)
+	}
+}
+
+func TestHandleAlertRulesAuthorization(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.Alerts.Rules = []map[string]interface{}{{
+		"id":       "quakes",
+		"when":     map[string]interface{}{"keyword": "earthquake"},
+		"webhooks": []interface{}{"https://hooks.example.com/services/T0/B0/secret?token=x"},
+	}}
+
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if path == "/api/alerts/rules" {
+			s.handleAlertRules(rec, req)
+		} else {
+			s.handleAlertRule(rec, req)
+		}
+		return rec
+	}
+
+	// Without a token configured, rules can't be changed
+	rule := `{"id": "japan", "when": {"entity": "Japan"}, "webhooks": ["https://hooks.example.com/b"]}`
+	if rec := do("", http.MethodPost, "/api/alerts/rules", rule); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without a configured token, got %d", rec.Code)
+	}
+	if rec := do("guess", http.MethodDelete, "/api/alerts/rules/japan", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without a configured token, got %d", rec.Code)
+	}
+
+	s.cfg.Alerts.APIToken = "secret"
+	for _, token := range []string{"", "guess"} {
+		rec := do(token, http.MethodPut, "/api/alerts/rules/japan", rule)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected 401 for token %q, got %d", token, rec.Code)
+		}
+	}
+
+	// Webhooks on internal addresses are refused
+	for _, hook := range []string{"http://127.0.0.1:8080/", "http://localhost/x", "http://169.254.169.254/latest/meta-data", "http://[::1]/", "http://10.0.0.5/"} {
+		internal := `{"id": "japan", "when": {"entity": "Japan"}, "webhooks": ["` + hook + `"]}`
+		if rec := do("secret", http.MethodPost, "/api/alerts/rules", internal); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for webhook %s, got %d", hook, rec.Code)
+		}
+	}
+	s.cfg.Alerts.AllowPrivateWebhooks = true
+	internal := `{"id": "local", "when": {"entity": "Japan"}, "webhooks": ["http://127.0.0.1:8080/"]}`
+	if rec := do("secret", http.MethodPost, "/api/alerts/rules", internal); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201 with private webhooks allowed, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Webhook URLs are only shown in full with the token
+	for token, want := range map[string]string{
+		"":       "https://hooks.example.com/redacted",
+		"secret": "https://hooks.example.com/services/T0/B0/secret?token=x",
+	} {
+		var list alertRulesResponse
+		if err := json.NewDecoder(do(token, http.MethodGet, "/api/alerts/rules", "").Body).Decode(&list); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var got alerts.Rule
+		if err := json.NewDecoder(do(token, http.MethodGet, "/api/alerts/rules/quakes", "").Body).Decode(&got); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, rule := range append(list.Rules, got) {
+			if rule.ID == "quakes" && (len(rule.Webhooks) != 1 || rule.Webhooks[0] != want) {
+				t.Errorf("Token %q: expected webhook %s, got %v", token, want, rule.Webhooks)
+			}
+		}
+	}
+}
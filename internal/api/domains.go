@@ -0,0 +1,75 @@
+package api
+
+import (
+	"goreddit/internal/storage"
+	"net/http"
+	"time"
+)
+
+// domainStats is a domain's stats joined with its outlet metadata, if known
+type domainStats struct {
+	storage.DomainStats
+	Name     string `json:"name,omitempty"`
+	Bias     string `json:"bias,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// domainsResponse is the body returned by /api/domains
+type domainsResponse struct {
+	Count   int           `json:"count"`
+	Domains []domainStats `json:"domains"`
+}
+
+// handleDomains lists the domains a subreddit's posts link to most, with
+// their average score and sentiment and the outlet behind them, e.g.
+// /api/domains?subreddit=worldnews&since=24h
+func (s *Server) handleDomains(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	now := time.Now()
+	query := storage.TopDomainsQuery{Subreddit: params.Get("subreddit")}
+
+	var err error
+	if query.Since, err = timeParam(params, "since", now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Since.IsZero() {
+		query.Since = now.Add(-24 * time.Hour)
+	}
+	if query.Until, err = timeParam(params, "until", now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Limit, err = intParam(params, "limit"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := s.store.TopDomains(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	domains := make([]domainStats, len(stats))
+	for i, ds := range stats {
+		domains[i] = s.joinOutlet(ds)
+	}
+	writeJSON(w, http.StatusOK, domainsResponse{
+		Count:   len(domains),
+		Domains: domains,
+	})
+}
+
+// joinOutlet adds the outlet metadata of a domain's stats
+func (s *Server) joinOutlet(ds storage.DomainStats) domainStats {
+	joined := domainStats{DomainStats: ds}
+	if outlet, ok := s.outlets.Lookup(ds.Domain); ok {
+		joined.Name, joined.Bias, joined.Category = outlet.Name, outlet.Bias, outlet.Category
+	}
+	return joined
+}
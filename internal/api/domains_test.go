@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleDomains(t *testing.T) {
+	s := newTestServer(t)
+	s.outlets = analysis.Outlets{"apnews.com": {Domain: "apnews.com", Name: "Associated Press", Bias: "center", Category: "wire"}}
+	now := float64(time.Now().Unix())
+
+	for _, post := range []reddit.Post{
+		{ID: "a", Title: "A", Subreddit: "worldnews", Domain: "apnews.com", Score: 10, CreatedAt: now - 600},
+		{ID: "b", Title: "B", Subreddit: "worldnews", Domain: "apnews.com", Score: 20, CreatedAt: now - 300},
+		{ID: "c", Title: "C", Subreddit: "worldnews", Domain: "example.com", Score: 5, CreatedAt: now - 60},
+	} {
+		if err := s.store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleDomains(rec, httptest.NewRequest(http.MethodGet, "/api/domains?subreddit=worldnews", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp domainsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 {
+		t.Fatalf("Expected 2 domains, got %+v", resp.Domains)
+	}
+	if top := resp.Domains[0]; top.Domain != "apnews.com" || top.Posts != 2 || top.AvgScore != 15 || top.Bias != "center" || top.Name != "Associated Press" {
+		t.Errorf("Unexpected top domain: %+v", top)
+	}
+	if other := resp.Domains[1]; other.Domain != "example.com" || other.Bias != "" {
+		t.Errorf("Expected an unknown outlet to have no metadata, got %+v", other)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleDomains(rec, httptest.NewRequest(http.MethodGet, "/api/domains?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad limit, got %d", rec.Code)
+	}
+}
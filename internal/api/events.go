@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"log"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// envelopeVersion is the version of the envelope and its payloads. It goes
+// up when either changes in a way clients would notice.
+const envelopeVersion = 1
+
+// Live feed message formats
+const (
+	formatEnvelope = "envelope" // every message in an envelope
+	formatRaw      = "raw"      // posts as bare JSON, as before envelopes
+)
+
+const (
+	// statsInterval is how often clients are sent topic_stats and
+	// server_status events
+	statsInterval = time.Minute
+	// topicStatsWindow and topicStatsLimit bound the topics in topic_stats
+	topicStatsWindow = 24 * time.Hour
+	topicStatsLimit  = 20
+)
+
+// Kinds of live feed events
+const (
+	eventPost         = "post"
+	eventPostUpdated  = "post_updated"
+	eventPostRemoved  = "post_removed"
+	eventTopicStats   = "topic_stats"
+	eventServerStatus = "server_status"
+	eventTrending     = "trending"
+)
+
+// envelope wraps every message sent to live feed clients, so they can tell
+// events apart before reading their payload
+type envelope struct {
+	Type    string    `json:"type"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Payload any       `json:"payload"`
+}
+
+// postUpdate is the payload of a post_updated event: the post as it is now,
+// and the names of the fields that changed
+type postUpdate struct {
+	Post    reddit.Post `json:"post"`
+	Changed []string    `json:"changed"`
+}
+
+// postRemoval is the payload of a post_removed event
+type postRemoval struct {
+	ID        string `json:"id"`
+	Subreddit string `json:"subreddit"`
+}
+
+// topicStats is the payload of a topic_stats event: the most frequent topics
+// over a recent window
+type topicStats struct {
+	Since  time.Time           `json:"since"`
+	Until  time.Time           `json:"until"`
+	Topics []reddit.TopicCount `json:"topics"`
+}
+
+// serverStatus is the payload of a server_status event
+type serverStatus struct {
+	StartedAt  time.Time  `json:"started_at"`
+	Clients    int        `json:"clients"`
+	Posts      int        `json:"posts"` // received since the server started
+	LastPostAt *time.Time `json:"last_post_at,omitempty"`
+}
+
+// trendingPayload is the payload of a trending event
+type trendingPayload struct {
+	Trending []analysis.Trend `json:"trending"`
+}
+
+// feedEvent is a message ready to send to clients of either format
+type feedEvent struct {
+	kind     string
+	id       string // of the post, for post events
+	envelope []byte
+	raw      []byte // nil if raw clients don't get the event
+}
+
+// newFeedEvent encodes an event of kind with payload for envelope clients,
+// and raw, unless it's nil, for raw clients
+func newFeedEvent(kind string, payload, raw any, now time.Time) (feedEvent, error) {
+	ev := feedEvent{kind: kind}
+	var err error
+	if ev.envelope, err = json.Marshal(envelope{Type: kind, Version: envelopeVersion, Time: now.UTC(), Payload: payload}); err != nil {
+		return ev, fmt.Errorf("failed to marshal %s event: %w", kind, err)
+	}
+	if raw != nil {
+		if ev.raw, err = json.Marshal(raw); err != nil {
+			return ev, fmt.Errorf("failed to marshal raw %s event: %w", kind, err)
+		}
+	}
+	return ev, nil
+}
+
+// newPostEvent encodes a post, which raw clients get as bare JSON
+func newPostEvent(post reddit.Post, now time.Time) (feedEvent, error) {
+	ev, err := newFeedEvent(eventPost, post, post, now)
+	ev.id = post.ID
+	return ev, err
+}
+
+// parseFeedFormat reads the message format a client asked for in its
+// connection URL, e.g. /ws?format=raw
+func parseFeedFormat(params url.Values, fallback string) (string, error) {
+	switch format := params.Get("format"); format {
+	case "":
+		return fallback, nil
+	case formatEnvelope, formatRaw:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid format %q: use %s or %s", format, formatEnvelope, formatRaw)
+	}
+}
+
+// changedFields lists the fields of a post that differ between two versions
+func changedFields(old, post *reddit.Post) []string {
+	var changed []string
+	a, b := reflect.ValueOf(*old), reflect.ValueOf(*post)
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, a.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
+// isRemoved reports whether a post has been removed or deleted on Reddit,
+// which blanks out its body
+func isRemoved(post *reddit.Post) bool {
+	return post.Body == "[removed]" || post.Body == "[deleted]"
+}
+
+// broadcastStats sends every client the top topics and the server's status
+// every so often
+func (s *Server) broadcastStats(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		s.broadcastTopicStats(context.Background(), time.Now())
+		ev, err := newFeedEvent(eventServerStatus, s.hub.status(), nil, time.Now())
+		if err != nil {
+			log.Printf("API: Error encoding server status: %v", err)
+		} else {
+			s.hub.broadcast(ev)
+		}
+		<-ticker.C
+	}
+}
+
+// broadcastTopicStats reads the top topics of the last topicStatsWindow from
+// the rollups and sends them to every client. The hub keeps them for new
+// clients.
+func (s *Server) broadcastTopicStats(ctx context.Context, now time.Time) {
+	stats := topicStats{Since: now.Add(-topicStatsWindow).UTC(), Until: now.UTC()}
+	topics, err := s.store.TopTopics(ctx, storage.TopTopicsQuery{Since: stats.Since, Until: stats.Until, Limit: topicStatsLimit})
+	if err != nil {
+		log.Printf("API: Failed to read topic stats: %v", err)
+		return
+	}
+	stats.Topics = topics
+	if stats.Topics == nil {
+		stats.Topics = []reddit.TopicCount{}
+	}
+	ev, err := newFeedEvent(eventTopicStats, stats, nil, now)
+	if err != nil {
+		log.Printf("API: Error encoding topic stats: %v", err)
+		return
+	}
+	s.hub.publishTopicStats(ev)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readEnvelope reads the next message from conn, checking its envelope and
+// decoding its payload into payload
+func readEnvelope(t *testing.T, conn *websocket.Conn, kind string, payload any) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg struct {
+		envelope
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	if msg.Type != kind || msg.Version != envelopeVersion || time.Since(msg.Time) > time.Minute {
+		t.Fatalf("Expected a %s envelope, got %+v", kind, msg)
+	}
+	if err := json.Unmarshal(msg.Payload, payload); err != nil {
+		t.Fatalf("Failed to decode %s payload: %v", kind, err)
+	}
+}
+
+func TestFeedEvents(t *testing.T) {
+	s := newTestServer(t)
+	s.trends = analysis.NewTrendDetector(analysis.TrendOptions{Bucket: time.Minute, Window: 5 * time.Minute, Baseline: time.Hour})
+	post := reddit.Post{ID: "a", Subreddit: "news", Title: "Ceasefire agreed", Score: 1, CreatedAt: float64(time.Now().Unix()), Topics: []string{"Ceasefire"}}
+	if err := s.store.SavePost(context.Background(), post); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+	s.hub.publish(post)
+
+	conn := dialFeed(t, s, "/ws")
+	var replayed reddit.Post
+	readEnvelope(t, conn, eventPost, &replayed)
+	if replayed.ID != "a" {
+		t.Errorf("Expected post a replayed, got %+v", replayed)
+	}
+	var status serverStatus
+	readEnvelope(t, conn, eventServerStatus, &status)
+	if status.Clients != 1 || status.Posts != 1 || status.LastPostAt == nil {
+		t.Errorf("Unexpected server status: %+v", status)
+	}
+
+	s.broadcastTopicStats(context.Background(), time.Now())
+	var stats topicStats
+	readEnvelope(t, conn, eventTopicStats, &stats)
+	if len(stats.Topics) != 1 || stats.Topics[0] != (reddit.TopicCount{Topic: "Ceasefire", Count: 1}) {
+		t.Errorf("Unexpected topic stats: %+v", stats)
+	}
+
+	// A post seen again is an update if it changed, and nothing if it didn't
+	updated := post
+	updated.Score = 50
+	s.hub.publish(updated)
+	s.hub.publish(updated)
+	var update postUpdate
+	readEnvelope(t, conn, eventPostUpdated, &update)
+	if update.Post.Score != 50 || fmt.Sprint(update.Changed) != "[Score]" {
+		t.Errorf("Unexpected update: %+v", update)
+	}
+
+	removed := updated
+	removed.Body = "[removed]"
+	s.hub.publish(removed)
+	var removal postRemoval
+	readEnvelope(t, conn, eventPostRemoved, &removal)
+	if removal != (postRemoval{ID: "a", Subreddit: "news"}) {
+		t.Errorf("Unexpected removal: %+v", removal)
+	}
+	if len(s.hub.recent) != 0 {
+		t.Errorf("Expected the removed post dropped from the replay buffer, got %d posts", len(s.hub.recent))
+	}
+
+	// Acknowledgements and errors are enveloped too
+	if err := conn.WriteJSON(feedRequest{Type: "subscribe", Filter: feedFilter{Subreddits: []string{"news"}}}); err != nil {
+		t.Fatal(err)
+	}
+	var ack feedResponse
+	readEnvelope(t, conn, "subscribed", &ack)
+	if ack.Filter == nil || ack.Filter.Subreddits[0] != "news" || ack.Type != "" {
+		t.Errorf("Unexpected acknowledgement: %+v", ack)
+	}
+	if err := conn.WriteJSON(feedRequest{Type: "publish"}); err != nil {
+		t.Fatal(err)
+	}
+	readEnvelope(t, conn, "error", &ack)
+	if ack.Error == "" {
+		t.Error("Expected an error message")
+	}
+}
+
+func TestHubRawClients(t *testing.T) {
+	h := newHub(hubOptions{})
+	raw := h.register(feedFilter{}, formatRaw, nil)
+	post := reddit.Post{ID: "a", Score: 1}
+	h.publish(post)
+	post.Score = 2
+	h.publish(post)
+	ev, err := newFeedEvent(eventServerStatus, h.status(), nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.broadcast(ev)
+
+	if len(raw.send) != 1 {
+		t.Fatalf("Expected raw clients to get only the post, got %d messages", len(raw.send))
+	}
+	var got reddit.Post
+	if err := json.Unmarshal((<-raw.send).data, &got); err != nil || got.ID != "a" || got.Score != 1 {
+		t.Errorf("Expected the bare post, got %+v (%v)", got, err)
+	}
+}
+
+func TestWebSocketRejectsBadFormat(t *testing.T) {
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.handleWebSocket(rec, httptest.NewRequest(http.MethodGet, "/ws?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad format, got %d", rec.Code)
+	}
+}
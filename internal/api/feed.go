@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/reddit"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxFeedRequestSize is the largest message a WebSocket client may send
+const maxFeedRequestSize = 16 << 10
+
+// feedFilter limits the posts a WebSocket client is sent. Each list matches a
+// post if any of its entries does, and the zero value lets every post through.
+type feedFilter struct {
+	Subreddits   []string `json:"subreddits,omitempty"`
+	Topics       []string `json:"topics,omitempty"`
+	Keywords     []string `json:"keywords,omitempty"` // matched in the title or body
+	Languages    []string `json:"languages,omitempty"`
+	MinScore     *int32   `json:"min_score,omitempty"`
+	MinSentiment *float64 `json:"min_sentiment,omitempty"`
+	MaxSentiment *float64 `json:"max_sentiment,omitempty"`
+}
+
+// feedRequest is a message from a WebSocket client. "subscribe" replaces the
+// connection's filter, and with replay set also sends the recent posts
+// matching it; "unsubscribe" stops posts until the next subscribe.
+type feedRequest struct {
+	Type   string     `json:"type"`
+	Filter feedFilter `json:"filter"`
+	Replay bool       `json:"replay,omitempty"`
+}
+
+// feedResponse acknowledges a feedRequest, or says why it was rejected. In an
+// envelope, it's the payload and leaves out the type.
+type feedResponse struct {
+	Type   string      `json:"type,omitempty"` // "subscribed", "unsubscribed" or "error"
+	Filter *feedFilter `json:"filter,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// parseFeedFilter reads a client's filter from its connection URL, e.g.
+// /ws?subreddit=worldnews,news&keyword=ceasefire&min_score=10&language=en
+func parseFeedFilter(params url.Values) (feedFilter, error) {
+	f := feedFilter{
+		Subreddits: listParam(params, "subreddit"),
+		Topics:     listParam(params, "topic"),
+		Keywords:   listParam(params, "keyword"),
+		Languages:  listParam(params, "language"),
+	}
+	if v := params.Get("min_score"); v != "" {
+		score, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return f, fmt.Errorf("invalid min_score %q", v)
+		}
+		minScore := int32(score)
+		f.MinScore = &minScore
+	}
+	var err error
+	if f.MinSentiment, err = floatParam(params, "min_sentiment"); err != nil {
+		return f, err
+	}
+	if f.MaxSentiment, err = floatParam(params, "max_sentiment"); err != nil {
+		return f, err
+	}
+	return f.normalize()
+}
+
+// parseFeedRequest decodes and checks a message from a client
+func parseFeedRequest(data []byte) (feedRequest, error) {
+	var req feedRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, fmt.Errorf("invalid message: %v", err)
+	}
+	switch req.Type {
+	case "subscribe":
+		var err error
+		req.Filter, err = req.Filter.normalize()
+		return req, err
+	case "unsubscribe":
+		return req, nil
+	default:
+		return req, fmt.Errorf("unknown message type %q: use subscribe or unsubscribe", req.Type)
+	}
+}
+
+// normalize lowercases the filter's keywords and languages, so they can be
+// matched directly, drops empty entries and checks its sentiment range
+func (f feedFilter) normalize() (feedFilter, error) {
+	for _, bound := range []*float64{f.MinSentiment, f.MaxSentiment} {
+		if bound != nil && (*bound < -1 || *bound > 1) {
+			return f, fmt.Errorf("invalid sentiment bound %g: use -1 to 1", *bound)
+		}
+	}
+	if f.MinSentiment != nil && f.MaxSentiment != nil && *f.MinSentiment > *f.MaxSentiment {
+		return f, fmt.Errorf("min_sentiment %g is above max_sentiment %g", *f.MinSentiment, *f.MaxSentiment)
+	}
+	f.Keywords = lowerAll(f.Keywords)
+	f.Languages = lowerAll(f.Languages)
+	return f, nil
+}
+
+// matches reports whether post passes the filter
+func (f feedFilter) matches(post *reddit.Post) bool {
+	if f.MinScore != nil && post.Score < *f.MinScore {
+		return false
+	}
+	if f.MinSentiment != nil && post.Sentiment < *f.MinSentiment {
+		return false
+	}
+	if f.MaxSentiment != nil && post.Sentiment > *f.MaxSentiment {
+		return false
+	}
+	if len(f.Languages) > 0 && !containsFold(f.Languages, post.Language) {
+		return false
+	}
+	if len(f.Subreddits) > 0 && !containsFold(f.Subreddits, post.Subreddit) {
+		return false
+	}
+	if len(f.Topics) > 0 && !anyContainsFold(f.Topics, post.Topics) {
+		return false
+	}
+	if len(f.Keywords) > 0 {
+		text := strings.ToLower(post.Title + "\n" + post.Body)
+		for _, keyword := range f.Keywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyContainsFold(list, values []string) bool {
+	for _, value := range values {
+		if containsFold(list, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(list []string) []string {
+	var lowered []string
+	for _, item := range list {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			lowered = append(lowered, item)
+		}
+	}
+	return lowered
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialFeed connects a WebSocket client to s at path, e.g. /ws?subreddit=news
+func dialFeed(t *testing.T, s *Server, path string) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readFeed reads the next message from conn as a generic map
+func readFeed(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocketSubscriptions(t *testing.T) {
+	s := newTestServer(t)
+	s.trends = analysis.NewTrendDetector(analysis.TrendOptions{Bucket: time.Minute, Window: 5 * time.Minute, Baseline: time.Hour})
+	s.hub.publish(reddit.Post{ID: "old-news", Subreddit: "news", Title: "Flooding in the north"})
+	s.hub.publish(reddit.Post{ID: "old-politics", Subreddit: "politics", Title: "Budget vote"})
+
+	// Only the recent posts matching the URL filter are replayed. Raw
+	// clients get them as bare JSON, and acks and trending topics as is.
+	conn := dialFeed(t, s, "/ws?subreddit=news&format=raw")
+	if msg := readFeed(t, conn); msg["ID"] != "old-news" {
+		t.Fatalf("Expected the news post replayed, got %v", msg)
+	}
+
+	if err := conn.WriteJSON(feedRequest{
+		Type:   "subscribe",
+		Filter: feedFilter{Subreddits: []string{"politics"}, Keywords: []string{"Budget"}},
+		Replay: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readFeed(t, conn); msg["type"] != "subscribed" {
+		t.Fatalf("Expected the subscription acknowledged, got %v", msg)
+	}
+	if msg := readFeed(t, conn); msg["ID"] != "old-politics" {
+		t.Fatalf("Expected the politics post replayed, got %v", msg)
+	}
+
+	// Live posts are filtered by the new subscription
+	s.hub.publish(reddit.Post{ID: "news", Subreddit: "news", Title: "Budget news"})
+	s.hub.publish(reddit.Post{ID: "politics", Subreddit: "politics", Title: "Budget passes"})
+	if msg := readFeed(t, conn); msg["ID"] != "politics" {
+		t.Fatalf("Expected only the matching post, got %v", msg)
+	}
+
+	// Bad requests are answered with an error and change nothing
+	for _, req := range []string{`{"type":"publish"}`, `{"type":"subscribe","filter":{"min_sentiment":2}}`, `not json`} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		if msg := readFeed(t, conn); msg["type"] != "error" || msg["error"] == "" {
+			t.Errorf("Expected an error for %s, got %v", req, msg)
+		}
+	}
+
+	if err := conn.WriteJSON(feedRequest{Type: "unsubscribe"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readFeed(t, conn); msg["type"] != "unsubscribed" {
+		t.Fatalf("Expected the unsubscription acknowledged, got %v", msg)
+	}
+	s.hub.publish(reddit.Post{ID: "politics-2", Subreddit: "politics", Title: "Budget signed"})
+	s.broadcastTrending()
+	if msg := readFeed(t, conn); msg["type"] != "trending" {
+		t.Errorf("Expected no posts after unsubscribing, got %v", msg)
+	}
+}
+
+func TestWebSocketRejectsBadFilter(t *testing.T) {
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.handleWebSocket(rec, httptest.NewRequest(http.MethodGet, "/ws?min_score=high", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad filter, got %d", rec.Code)
+	}
+}
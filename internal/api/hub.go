@@ -0,0 +1,394 @@
+package api
+
+import (
+	"fmt"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"log"
+	"sync"
+	"time"
+)
+
+// What a hub does when a client's queue is full
+const (
+	slowClientsDropOldest = "drop_oldest" // make room by dropping the oldest queued message
+	slowClientsDisconnect = "disconnect"  // drop the client
+)
+
+const (
+	defaultQueueSize    = 256
+	defaultPingInterval = 30 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+
+	// recentPostsSize is how many posts are kept to replay to new clients
+	recentPostsSize = 100
+)
+
+// hubOptions configure how a hub treats its clients
+type hubOptions struct {
+	QueueSize    int
+	SlowClients  string
+	PingInterval time.Duration
+	WriteTimeout time.Duration
+	Format       string // of clients that don't ask for one
+}
+
+// hubOptionsFromConfig reads the api.websocket settings, filling in defaults
+func hubOptionsFromConfig(cfg *config.Config) (hubOptions, error) {
+	ws := cfg.API.WebSocket
+	opts := hubOptions{
+		QueueSize:    ws.QueueSize,
+		SlowClients:  ws.SlowClients,
+		PingInterval: ws.PingInterval,
+		WriteTimeout: ws.WriteTimeout,
+		Format:       ws.Format,
+	}.withDefaults()
+	switch opts.SlowClients {
+	case slowClientsDropOldest, slowClientsDisconnect:
+	default:
+		return opts, fmt.Errorf("unknown api.websocket.slow_clients %q: use %s or %s", opts.SlowClients, slowClientsDropOldest, slowClientsDisconnect)
+	}
+	switch opts.Format {
+	case formatEnvelope, formatRaw:
+	default:
+		return opts, fmt.Errorf("unknown api.websocket.format %q: use %s or %s", opts.Format, formatEnvelope, formatRaw)
+	}
+	return opts, nil
+}
+
+func (o hubOptions) withDefaults() hubOptions {
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultQueueSize
+	}
+	if o.SlowClients == "" {
+		o.SlowClients = slowClientsDropOldest
+	}
+	if o.PingInterval <= 0 {
+		o.PingInterval = defaultPingInterval
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = defaultWriteTimeout
+	}
+	if o.Format == "" {
+		o.Format = formatEnvelope
+	}
+	return o
+}
+
+// hub fans messages out to the live feed clients. Sending never blocks: each
+// client has a bounded queue that a goroutine of its own drains onto the
+// connection, and a client whose queue is full loses its oldest message or is
+// disconnected, as configured, so a slow client can't hold up the others or
+// the consumer feeding the hub.
+type hub struct {
+	opts      hubOptions
+	startedAt time.Time
+	mutex     sync.Mutex
+	clients   map[*client]bool
+	recent    []reddit.Post // the last recentPostsSize posts, oldest first
+	posts     int           // published since the hub started
+	lastPost  time.Time
+	stats     *feedEvent // the latest topic_stats, for new clients
+}
+
+// client is a hub's view of a live feed connection
+type client struct {
+	send chan message  // messages to write, in order
+	done chan struct{} // closed once the client is removed from the hub
+	raw  bool          // sent posts as bare JSON, and no other events
+
+	// Guarded by the hub's mutex
+	filter  *feedFilter // nil while unsubscribed
+	dropped int         // messages lost to a full queue
+	slow    bool        // removed for falling behind
+}
+
+// message is an event queued for a client, in the client's format
+type message struct {
+	kind string // of event
+	id   string // of the post, for post events
+	data []byte
+}
+
+// feedResume is where a reconnecting client left off
+type feedResume struct {
+	lastID string        // the last post it was sent
+	since  float64       // when that post was created
+	stored []reddit.Post // the posts after it, read from storage if it's no longer among the recent ones
+}
+
+func newHub(opts hubOptions) *hub {
+	return &hub{
+		opts:      opts.withDefaults(),
+		startedAt: time.Now(),
+		clients:   make(map[*client]bool),
+		recent:    make([]reddit.Post, 0, recentPostsSize),
+	}
+}
+
+// register adds a client subscribed with filter, wanting messages in format,
+// queueing the recent posts matching it first so that none are missed or sent
+// twice. A client resuming gets the posts after the last one it was sent
+// instead.
+func (h *hub) register(filter feedFilter, format string, resume *feedResume) *client {
+	c := &client{
+		send:   make(chan message, h.opts.QueueSize),
+		done:   make(chan struct{}),
+		raw:    format == formatRaw,
+		filter: &filter,
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[c] = true
+	if resume != nil {
+		h.resumeLocked(c, resume)
+	} else {
+		h.replayLocked(c)
+	}
+	if h.stats != nil {
+		h.enqueueLocked(c, *h.stats)
+	}
+	log.Printf("API: Total connected clients: %d", len(h.clients))
+	return c
+}
+
+// unregister removes a client, if the hub hasn't already
+func (h *hub) unregister(c *client) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.removeLocked(c)
+}
+
+func (h *hub) removeLocked(c *client) {
+	if !h.clients[c] {
+		return
+	}
+	delete(h.clients, c)
+	close(c.done)
+	if c.dropped > 0 {
+		log.Printf("API: Client dropped %d messages it was too slow for", c.dropped)
+	}
+	log.Printf("API: Client disconnected, remaining clients: %d", len(h.clients))
+}
+
+// subscribe replaces a client's filter, which nil unsubscribes, and queues
+// ack. With replay set, the recent posts matching the filter follow the ack.
+func (h *hub) subscribe(c *client, filter *feedFilter, ack feedEvent, replay bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.clients[c] {
+		return
+	}
+	c.filter = filter
+	h.enqueueLocked(c, ack)
+	if replay {
+		h.replayLocked(c)
+	}
+}
+
+// reply queues an event for one client
+func (h *hub) reply(c *client, ev feedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.clients[c] {
+		h.enqueueLocked(c, ev)
+	}
+}
+
+// publish queues a post for the subscribed clients whose filter it matches.
+// A post seen before is sent as post_updated if it changed, or post_removed
+// if it's been removed on Reddit, to the clients that were sent it.
+func (h *hub) publish(post reddit.Post) {
+	now := time.Now()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.posts++
+	h.lastPost = now
+
+	i := h.recentIndexLocked(post.ID)
+	if i < 0 {
+		if len(h.recent) == recentPostsSize {
+			h.recent = append(h.recent[:0], h.recent[1:]...) // Remove oldest post
+		}
+		h.recent = append(h.recent, post)
+		ev, err := newPostEvent(post, now)
+		if err != nil {
+			log.Printf("API: Error encoding post: %v", err)
+			return
+		}
+		h.sendLocked(ev, func(f *feedFilter) bool { return f.matches(&post) })
+		return
+	}
+
+	old := h.recent[i]
+	var ev feedEvent
+	var err error
+	if isRemoved(&post) {
+		h.recent = append(h.recent[:i], h.recent[i+1:]...)
+		ev, err = newFeedEvent(eventPostRemoved, postRemoval{ID: post.ID, Subreddit: post.Subreddit}, nil, now)
+	} else {
+		changed := changedFields(&old, &post)
+		if len(changed) == 0 {
+			return // Delivered again
+		}
+		h.recent[i] = post
+		ev, err = newFeedEvent(eventPostUpdated, postUpdate{Post: post, Changed: changed}, nil, now)
+	}
+	if err != nil {
+		log.Printf("API: Error encoding post event: %v", err)
+		return
+	}
+	h.sendLocked(ev, func(f *feedFilter) bool { return f.matches(&old) || f.matches(&post) })
+}
+
+func (h *hub) recentIndexLocked(id string) int {
+	for i := len(h.recent) - 1; i >= 0; i-- {
+		if h.recent[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sendLocked queues an event for the subscribed clients whose filter passes
+// match. The caller must hold h.mutex.
+func (h *hub) sendLocked(ev feedEvent, match func(*feedFilter) bool) {
+	for c := range h.clients {
+		if c.filter != nil && match(c.filter) {
+			h.enqueueLocked(c, ev)
+		}
+	}
+}
+
+// broadcast queues an event for every client, subscribed or not
+func (h *hub) broadcast(ev feedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for c := range h.clients {
+		h.enqueueLocked(c, ev)
+	}
+}
+
+// publishTopicStats broadcasts a topic_stats event and keeps it for clients
+// that connect before the next one
+func (h *hub) publishTopicStats(ev feedEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.stats = &ev
+	for c := range h.clients {
+		h.enqueueLocked(c, ev)
+	}
+}
+
+// status describes the hub for a server_status event
+func (h *hub) status() serverStatus {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	status := serverStatus{StartedAt: h.startedAt.UTC(), Clients: len(h.clients), Posts: h.posts}
+	if !h.lastPost.IsZero() {
+		last := h.lastPost.UTC()
+		status.LastPostAt = &last
+	}
+	return status
+}
+
+// recentPost reports whether a post is among the recent ones
+func (h *hub) recentPost(id string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.recentIndexLocked(id) >= 0
+}
+
+// replayLocked queues the recent posts matching a client's filter, as many
+// of the latest as fit in its queue. The caller must hold h.mutex.
+func (h *hub) replayLocked(c *client) {
+	h.queuePostsLocked(c, h.recent)
+}
+
+// resumeLocked queues the posts a client missed since resume.lastID: those
+// after it among the recent ones, or if it's no longer there, the stored
+// posts after it followed by the recent ones after it by (created_at, id) and
+// not among them. The caller must hold h.mutex.
+func (h *hub) resumeLocked(c *client, resume *feedResume) {
+	if i := h.recentIndexLocked(resume.lastID); i >= 0 {
+		h.queuePostsLocked(c, h.recent[i+1:])
+		return
+	}
+	missed := append([]reddit.Post(nil), resume.stored...)
+	seen := map[string]bool{resume.lastID: true}
+	for _, post := range missed {
+		seen[post.ID] = true
+	}
+	for _, post := range h.recent {
+		after := post.CreatedAt > resume.since || (post.CreatedAt == resume.since && post.ID > resume.lastID)
+		if !seen[post.ID] && after {
+			missed = append(missed, post)
+		}
+	}
+	h.queuePostsLocked(c, missed)
+}
+
+// queuePostsLocked queues the posts matching a client's filter, as many of
+// the latest as fit in its queue. The caller must hold h.mutex.
+func (h *hub) queuePostsLocked(c *client, posts []reddit.Post) {
+	if c.filter == nil {
+		return
+	}
+	var matching []reddit.Post
+	for _, post := range posts {
+		if c.filter.matches(&post) {
+			matching = append(matching, post)
+		}
+	}
+	if room := cap(c.send) - len(c.send); len(matching) > room {
+		matching = matching[len(matching)-room:]
+	}
+	now := time.Now()
+	for _, post := range matching {
+		ev, err := newPostEvent(post, now)
+		if err != nil {
+			log.Printf("API: Error encoding post for client: %v", err)
+			continue
+		}
+		h.enqueueLocked(c, ev)
+	}
+}
+
+// enqueueLocked queues an event for a client in its format without
+// blocking, applying the slow-client policy if its queue is full. Raw clients
+// only get the events that have a raw form. The caller must hold h.mutex.
+func (h *hub) enqueueLocked(c *client, ev feedEvent) {
+	msg := message{kind: ev.kind, id: ev.id, data: ev.envelope}
+	if c.raw {
+		if msg.data = ev.raw; msg.data == nil {
+			return
+		}
+	}
+	for {
+		select {
+		case c.send <- msg:
+			return
+		default:
+		}
+
+		if h.opts.SlowClients == slowClientsDisconnect {
+			log.Printf("API: Disconnecting client with %d messages queued", len(c.send))
+			c.slow = true
+			h.removeLocked(c)
+			return
+		}
+		// The writer may take the oldest message first, so try again either way
+		select {
+		case <-c.send:
+			c.dropped++
+		default:
+		}
+	}
+}
+
+// len returns the number of connected clients
+func (h *hub) len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.clients)
+}
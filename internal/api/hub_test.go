@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"testing"
+	"time"
+)
+
+// queuedIDs drains a client's queue, returning the IDs of the posts in it
+func queuedIDs(t *testing.T, c *client) []string {
+	t.Helper()
+	var ids []string
+	for len(c.send) > 0 {
+		var msg struct {
+			Type    string
+			Payload reddit.Post
+		}
+		if err := json.Unmarshal((<-c.send).data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != eventPost {
+			t.Fatalf("Expected only posts queued, got a %s event", msg.Type)
+		}
+		ids = append(ids, msg.Payload.ID)
+	}
+	return ids
+}
+
+func TestHubSlowClients(t *testing.T) {
+	h := newHub(hubOptions{QueueSize: 2})
+	slow := h.register(feedFilter{}, formatEnvelope, nil)
+	politics := h.register(feedFilter{Subreddits: []string{"politics"}}, formatEnvelope, nil)
+	for i := 1; i <= 3; i++ {
+		h.publish(reddit.Post{ID: fmt.Sprint(i), Subreddit: "news"})
+	}
+	if ids := queuedIDs(t, slow); fmt.Sprint(ids) != "[2 3]" || slow.dropped != 1 {
+		t.Errorf("Expected the oldest post dropped, got %v (%d dropped)", ids, slow.dropped)
+	}
+	if len(politics.send) != 0 || politics.dropped != 0 {
+		t.Errorf("Expected no posts for a client filtering them out")
+	}
+
+	// Replays fit in the queue, keeping the latest posts
+	if ids := queuedIDs(t, h.register(feedFilter{}, formatEnvelope, nil)); fmt.Sprint(ids) != "[2 3]" {
+		t.Errorf("Expected the latest posts replayed, got %v", ids)
+	}
+
+	h = newHub(hubOptions{QueueSize: 1, SlowClients: slowClientsDisconnect})
+	slow = h.register(feedFilter{}, formatEnvelope, nil)
+	h.publish(reddit.Post{ID: "1"})
+	h.publish(reddit.Post{ID: "2"})
+	select {
+	case <-slow.done:
+	default:
+		t.Fatal("Expected the slow client disconnected")
+	}
+	if !slow.slow || h.len() != 0 {
+		t.Errorf("Expected the client removed as slow, got slow %v and %d clients", slow.slow, h.len())
+	}
+	h.publish(reddit.Post{ID: "3"}) // Doesn't reach the removed client
+	if ids := queuedIDs(t, slow); fmt.Sprint(ids) != "[1]" {
+		t.Errorf("Expected only the first post queued, got %v", ids)
+	}
+}
+
+func TestHubOptionsFromConfig(t *testing.T) {
+	cfg := &config.Config{}
+	opts, err := hubOptionsFromConfig(cfg)
+	if err != nil || opts.QueueSize != defaultQueueSize || opts.SlowClients != slowClientsDropOldest {
+		t.Errorf("Expected defaults, got %+v (%v)", opts, err)
+	}
+	cfg.API.WebSocket.SlowClients = "block"
+	if _, err := hubOptionsFromConfig(cfg); err == nil {
+		t.Error("Expected an error for an unknown slow-client policy")
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	s := newTestServer(t)
+	s.trends = analysis.NewTrendDetector(analysis.TrendOptions{Bucket: time.Minute, Window: 5 * time.Minute, Baseline: time.Hour})
+	s.hub = newHub(hubOptions{PingInterval: 50 * time.Millisecond, WriteTimeout: 50 * time.Millisecond})
+
+	// A client reading its connection answers pings; one that doesn't read
+	// never does, and is dropped
+	answering := dialFeed(t, s, "/ws")
+	go func() {
+		for {
+			if _, _, err := answering.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	dialFeed(t, s, "/ws")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.hub.len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if n := s.hub.len(); n != 1 {
+		t.Errorf("Expected only the answering client left, got %d clients", n)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/storage"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchResponse is the body returned by /api/search
+type searchResponse struct {
+	Query   string                 `json:"query"`
+	Count   int                    `json:"count"`
+	Results []storage.SearchResult `json:"results"`
+}
+
+// handleSearch serves ranked full-text search over stored posts, e.g.
+// /api/search?q=ceasefire&since=48h&subreddit=worldnews,news
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	query := storage.SearchQuery{Text: params.Get("q")}
+	if strings.TrimSpace(query.Text) == "" {
+		writeError(w, http.StatusBadRequest, "missing search query parameter q")
+		return
+	}
+
+	var err error
+	if query.Filter, err = parsePostFilter(params, time.Now()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Limit, err = intParam(params, "limit"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Offset, err = intParam(params, "offset"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := s.store.SearchPosts(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+
+	writeJSON(w, http.StatusOK, searchResponse{
+		Query:   query.Text,
+		Count:   len(results),
+		Results: results,
+	})
+}
+
+// parsePostFilter reads the shared post filter parameters: subreddit (comma
+// separated or repeated), since/until (RFC 3339 or a duration back from now,
+// like "48h"), min_score, min_sentiment, max_sentiment, min_toxicity, topic,
+// entity, story, language and domain (both comma separated or repeated) and
+// text
+func parsePostFilter(params url.Values, now time.Time) (storage.PostFilter, error) {
+	filter := storage.PostFilter{
+		Subreddits: listParam(params, "subreddit"),
+		Languages:  listParam(params, "language"),
+		Domains:    listParam(params, "domain"),
+	}
+
+	var err error
+	if filter.Since, err = timeParam(params, "since", now); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = timeParam(params, "until", now); err != nil {
+		return filter, err
+	}
+
+	if v := params.Get("min_score"); v != "" {
+		score, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_score %q", v)
+		}
+		minScore := int32(score)
+		filter.MinScore = &minScore
+	}
+	if filter.MinSentiment, err = floatParam(params, "min_sentiment"); err != nil {
+		return filter, err
+	}
+	if filter.MaxSentiment, err = floatParam(params, "max_sentiment"); err != nil {
+		return filter, err
+	}
+	if filter.MinToxicity, err = floatParam(params, "min_toxicity"); err != nil {
+		return filter, err
+	}
+
+	filter.Topic = params.Get("topic")
+	filter.Entity = params.Get("entity")
+	filter.StoryID = params.Get("story")
+	filter.Text = params.Get("text")
+	return filter, nil
+}
+
+// listParam collects the values of a parameter given comma separated,
+// repeated or both
+func listParam(params url.Values, name string) []string {
+	var list []string
+	for _, value := range params[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// timeParam parses an RFC 3339 timestamp or a duration before now
+func timeParam(params url.Values, name string, now time.Time) (time.Time, error) {
+	v := params.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: use RFC 3339 or a duration like 48h", name, v)
+	}
+	return t, nil
+}
+
+func floatParam(params url.Values, name string) (*float64, error) {
+	v := params.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return &f, nil
+}
+
+func intParam(params url.Values, name string) (int, error) {
+	v := params.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("API: Error writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// writeStoreError maps storage errors to HTTP statuses
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidFilter):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("API: Storage error: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = storage.DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	s := NewServer(cfg)
+	s.store = store
+	return s
+}
+
+func TestHandleSearch(t *testing.T) {
+	s := newTestServer(t)
+	now := float64(time.Now().Unix())
+
+	for _, post := range []reddit.Post{
+		{ID: "a", Title: "Ceasefire agreed", Subreddit: "worldnews", Score: 10, CreatedAt: now - 3600},
+		{ID: "b", Title: "Old ceasefire news", Subreddit: "worldnews", Score: 10, CreatedAt: now - 72*3600},
+		{ID: "c", Title: "Election results", Subreddit: "politics", Score: 10, CreatedAt: now - 60},
+	} {
+		if err := s.store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleSearch(rec, httptest.NewRequest(http.MethodGet, "/api/search?q=ceasefire&since=48h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp searchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 1 || resp.Results[0].Post.ID != "a" {
+		t.Errorf("Expected only the recent ceasefire post, got %+v", resp.Results)
+	}
+
+	for _, query := range []string{"", "q=-russia", "q=x&since=yesterday", "q=x&limit=-1"} {
+		rec := httptest.NewRecorder()
+		s.handleSearch(rec, httptest.NewRequest(http.MethodGet, "/api/search?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestParsePostFilter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	params := url.Values{
+		"subreddit": {"news, worldnews", "politics"},
+		"since":     {"2h"},
+		"until":     {"2024-05-01T11:30:00Z"},
+		"min_score": {"25"},
+	}
+
+	filter, err := parsePostFilter(params, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(filter.Subreddits) != 3 || filter.Subreddits[1] != "worldnews" {
+		t.Errorf("Unexpected subreddits: %v", filter.Subreddits)
+	}
+	if !filter.Since.Equal(now.Add(-2*time.Hour)) || !filter.Until.Equal(now.Add(-30*time.Minute)) {
+		t.Errorf("Unexpected window: %v - %v", filter.Since, filter.Until)
+	}
+	if filter.MinScore == nil || *filter.MinScore != 25 {
+		t.Errorf("Unexpected min score: %v", filter.MinScore)
+	}
+}
+
+func TestFeedFilter(t *testing.T) {
+	filter, err := parseFeedFilter(url.Values{"language": {"EN,de"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for lang, want := range map[string]bool{"en": true, "de": true, "fr": false, "": false} {
+		if got := filter.matches(&reddit.Post{Language: lang}); got != want {
+			t.Errorf("Language %q: expected match %v, got %v", lang, want, got)
+		}
+	}
+	if empty, _ := parseFeedFilter(url.Values{}); !empty.matches(&reddit.Post{Language: "fr"}) {
+		t.Error("Expected an empty filter to match every post")
+	}
+
+	filter, err = parseFeedFilter(url.Values{
+		"subreddit":     {"WorldNews,news"},
+		"topic":         {"ceasefire"},
+		"keyword":       {"Gaza", "truce"},
+		"min_score":     {"10"},
+		"min_sentiment": {"-0.5"},
+		"max_sentiment": {"0.5"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	match := reddit.Post{Subreddit: "worldnews", Title: "Truce agreed", Topics: []string{"Ceasefire"}, Score: 10}
+	if !filter.matches(&match) {
+		t.Errorf("Expected %+v to match", match)
+	}
+	for name, change := range map[string]func(*reddit.Post){
+		"subreddit": func(p *reddit.Post) { p.Subreddit = "politics" },
+		"topic":     func(p *reddit.Post) { p.Topics = []string{"Election"} },
+		"keyword":   func(p *reddit.Post) { p.Title = "Talks continue" },
+		"score":     func(p *reddit.Post) { p.Score = 9 },
+		"sentiment": func(p *reddit.Post) { p.Sentiment = 0.6 },
+	} {
+		post := match
+		change(&post)
+		if filter.matches(&post) {
+			t.Errorf("Expected a post with another %s not to match", name)
+		}
+	}
+
+	for _, params := range []url.Values{
+		{"min_score": {"high"}},
+		{"min_sentiment": {"-2"}},
+		{"min_sentiment": {"0.5"}, "max_sentiment": {"-0.5"}},
+	} {
+		if _, err := parseFeedFilter(params); err == nil {
+			t.Errorf("Expected an error for %v", params)
+		}
+	}
+}
@@ -2,15 +2,14 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"goreddit/internal/analysis"
 	"goreddit/internal/config"
 	"goreddit/internal/kafka"
 	"goreddit/internal/reddit"
 	"goreddit/internal/storage"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,11 +17,12 @@ import (
 
 type Server struct {
 	cfg      *config.Config
+	store    storage.Store
+	trends   *analysis.TrendDetector
+	outlets  analysis.Outlets
 	upgrader websocket.Upgrader
-	clients  map[*websocket.Conn]bool
-	mutex    sync.Mutex
-	// Add a buffer of recent posts
-	recentPosts []reddit.Post
+	// Live feed clients, and a buffer of recent posts for new ones
+	hub *hub
 }
 
 func NewServer(cfg *config.Config) *Server {
@@ -33,21 +33,41 @@ func NewServer(cfg *config.Config) *Server {
 				return true // Allow all origins in development
 			},
 		},
-		clients:     make(map[*websocket.Conn]bool),
-		recentPosts: make([]reddit.Post, 0, 100), // Keep last 100 posts
+		hub: newHub(hubOptions{}),
 	}
 }
 
 func (s *Server) Start() error {
 	log.Printf("Starting API server, clearing recent posts buffer...")
-	s.recentPosts = make([]reddit.Post, 0, 100) // Reset recent posts buffer
+	hubOpts, err := hubOptionsFromConfig(s.cfg)
+	if err != nil {
+		return fmt.Errorf("invalid websocket config: %w", err)
+	}
+	s.hub = newHub(hubOpts)
 
 	// Create store for persistence
-	store, err := storage.NewPostgresStore(s.cfg)
+	store, err := storage.New(s.cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create store: %w", err)
 	}
 	defer store.Close()
+	s.store = store
+
+	// Detect trending topics, starting from the baseline of stored posts
+	trendOpts, err := analysis.TrendOptionsFromConfig(s.cfg)
+	if err != nil {
+		return fmt.Errorf("invalid trends config: %w", err)
+	}
+	s.trends = analysis.NewTrendDetector(trendOpts)
+	if err := s.seedTrends(context.Background(), trendOpts, time.Now()); err != nil {
+		log.Printf("API: Failed to seed trends from storage: %v", err)
+	}
+	go s.refreshTrends(trendOpts.Bucket)
+
+	// Outlet metadata for domain stats
+	if s.outlets, err = analysis.LoadOutlets(s.cfg.Outlets.File); err != nil {
+		return err
+	}
 
 	// Create Kafka consumer with store and unique group ID
 	apiConfig := *s.cfg                                    // Make a copy of the config
@@ -61,12 +81,27 @@ func (s *Server) Start() error {
 
 	// Handle WebSocket connections
 	http.HandleFunc("/ws", s.handleWebSocket)
+	// Or as Server-Sent Events
+	http.HandleFunc("/events", s.handleEvents)
+
+	// REST endpoints backed by storage
+	http.HandleFunc("/api/search", s.handleSearch)
+	http.HandleFunc("/api/trending", s.handleTrending)
+	http.HandleFunc("/api/domains", s.handleDomains)
+	http.HandleFunc("/api/alerts/rules", s.handleAlertRules)
+	http.HandleFunc("/api/alerts/rules/", s.handleAlertRule)
+	http.HandleFunc("/api/stories", s.handleStories)
+	http.HandleFunc("/api/stories/", s.handleStory)
+
+	// Versioned REST API
+	http.HandleFunc(v1Prefix+"/", s.handleV1)
 
 	// Serve static files for Vue.js frontend
 	http.Handle("/", http.FileServer(http.Dir("./frontend/dist")))
 
 	// Start consuming posts in background
 	go s.consumePosts(consumer)
+	go s.broadcastStats(statsInterval)
 
 	log.Printf("Starting WebSocket server on port %d", s.cfg.API.Port)
 	return http.ListenAndServe(fmt.Sprintf(":%d", s.cfg.API.Port), nil)
@@ -75,6 +110,18 @@ func (s *Server) Start() error {
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Printf("New WebSocket connection attempt from %s", r.RemoteAddr)
 
+	// Clients start subscribed with the filter in their URL
+	filter, err := parseFeedFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := parseFeedFormat(r.URL.Query(), s.hub.opts.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -83,53 +130,124 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("WebSocket client connected from %s", conn.RemoteAddr())
 
-	// Add client to the pool
-	s.mutex.Lock()
-	s.clients[conn] = true
-	clientCount := len(s.clients)
-
-	// Send recent posts to the new client
-	if len(s.recentPosts) > 0 {
-		log.Printf("Sending %d recent posts to new client", len(s.recentPosts))
-		for _, post := range s.recentPosts {
-			data, err := json.Marshal(post)
-			if err != nil {
-				log.Printf("Error marshaling post for new client: %v", err)
-				continue
-			}
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Printf("Error sending recent post to new client: %v", err)
-				break
-			}
-			// Small delay to prevent overwhelming the client
-			time.Sleep(50 * time.Millisecond)
+	// Add client to the hub, which queues the recent posts for it
+	c := s.hub.register(filter, format, nil)
+	s.welcome(c)
+
+	go s.writeWebSocket(conn, c)
+	s.readWebSocket(conn, c)
+}
+
+// welcome queues the topics trending right now and the server's status for
+// a new client
+func (s *Server) welcome(c *client) {
+	if trending := s.trends.Trending(); len(trending) > 0 {
+		if ev, err := newTrendingEvent(trending, time.Now()); err != nil {
+			log.Printf("Error encoding trending topics for new client: %v", err)
+		} else {
+			s.hub.reply(c, ev)
 		}
 	}
-	s.mutex.Unlock()
+	if ev, err := newFeedEvent(eventServerStatus, s.hub.status(), nil, time.Now()); err != nil {
+		log.Printf("Error encoding server status for new client: %v", err)
+	} else {
+		s.hub.reply(c, ev)
+	}
+}
 
-	log.Printf("Total connected clients: %d", clientCount)
+// readWebSocket handles a client's messages until the connection fails or
+// the client stops answering pings, then removes the client
+func (s *Server) readWebSocket(conn *websocket.Conn, c *client) {
+	defer s.hub.unregister(c)
 
-	// Remove client when connection closes
-	defer func() {
-		s.mutex.Lock()
-		delete(s.clients, conn)
-		conn.Close()
-		s.mutex.Unlock()
-		log.Printf("Client disconnected, remaining clients: %d", len(s.clients))
-	}()
+	pongWait := s.hub.opts.PingInterval + s.hub.opts.WriteTimeout
+	conn.SetReadLimit(maxFeedRequestSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
 
-	// Keep connection alive and handle client messages
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
-			break
+			return
 		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		s.handleFeedRequest(c, data)
 	}
 }
 
+// writeWebSocket sends a client its queued messages and pings it, until a
+// write fails or the client is removed from the hub. It owns writes to conn
+// and closes it when done.
+func (s *Server) writeWebSocket(conn *websocket.Conn, c *client) {
+	opts := s.hub.opts
+	ticker := time.NewTicker(opts.PingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			conn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg.data); err != nil {
+				log.Printf("API: Error sending to client %s: %v", conn.RemoteAddr(), err)
+				s.hub.unregister(c)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				s.hub.unregister(c)
+				return
+			}
+		case <-c.done:
+			code, reason := websocket.CloseNormalClosure, ""
+			if c.slow {
+				code, reason = websocket.CloseTryAgainLater, "too slow"
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(opts.WriteTimeout))
+			return
+		}
+	}
+}
+
+// handleFeedRequest applies a subscribe or unsubscribe message from a client
+// and acknowledges it. Invalid messages are answered with an error and leave
+// the subscription as it was.
+func (s *Server) handleFeedRequest(c *client, data []byte) {
+	req, err := parseFeedRequest(data)
+	resp := feedResponse{Type: "error"}
+	var filter *feedFilter
+	switch {
+	case err != nil:
+		resp.Error = err.Error()
+	case req.Type == "subscribe":
+		filter = &req.Filter
+		resp = feedResponse{Type: "subscribed", Filter: filter}
+	default:
+		resp = feedResponse{Type: "unsubscribed"}
+	}
+
+	payload := resp
+	payload.Type = ""
+	ack, err := newFeedEvent(resp.Type, payload, resp, time.Now())
+	if err != nil {
+		log.Printf("API: Error encoding feed response: %v", err)
+		return
+	}
+	if resp.Type == "error" {
+		s.hub.reply(c, ack)
+		return
+	}
+	s.hub.subscribe(c, filter, ack, req.Replay)
+}
+
 func (s *Server) consumePosts(consumer *kafka.Consumer) {
 	log.Printf("API: consumePosts function started")
 
@@ -164,51 +282,16 @@ func (s *Server) consumePosts(consumer *kafka.Consumer) {
 
 		log.Printf("API: ✨ NEW MESSAGE ✨ - Received post from consumer - ID: %s, Title: %s, Subreddit: %s", post.ID, post.Title, post.Subreddit)
 
-		func() {
-			s.mutex.Lock()
-			defer s.mutex.Unlock()
-
-			// Add to recent posts buffer
-			s.recentPosts = append(s.recentPosts, post)
-			if len(s.recentPosts) > 100 {
-				s.recentPosts = s.recentPosts[1:] // Remove oldest post
-			}
-			log.Printf("API: Added post to recent posts buffer (size: %d) - ID: %s", len(s.recentPosts), post.ID)
-
-			// Skip broadcast if no clients
-			if len(s.clients) == 0 {
-				log.Printf("API: No clients connected, post cached for future clients - ID: %s", post.ID)
-				return
-			}
-
-			data, err := json.Marshal(post)
-			if err != nil {
-				log.Printf("API: Error marshaling post: %v", err)
-				return
-			}
-
-			log.Printf("API: Broadcasting post to %d clients - ID: %s", len(s.clients), post.ID)
-
-			// Create a list of clients to remove
-			var clientsToRemove []*websocket.Conn
-
-			// Send to all clients
-			for client := range s.clients {
-				if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-					log.Printf("API: Error sending to client %s: %v", client.RemoteAddr(), err)
-					clientsToRemove = append(clientsToRemove, client)
-					continue
-				}
-				log.Printf("API: Successfully sent post to client %s - ID: %s", client.RemoteAddr(), post.ID)
-			}
-
-			// Remove failed clients
-			for _, client := range clientsToRemove {
-				delete(s.clients, client)
-				client.Close()
-				log.Printf("API: Removed failed client %s", client.RemoteAddr())
-			}
-		}()
+		s.receive(post)
 	}
 	log.Printf("API: Posts channel closed, consumer loop exiting")
 }
+
+// receive sends a post from the consumer to the live feed and counts its
+// topics toward the trends, unless they've been counted already
+func (s *Server) receive(post reddit.Post) {
+	s.hub.publish(post)
+	if s.trends.Observe(post.ID, post.Topics, time.Unix(int64(post.CreatedAt), 0)) {
+		s.broadcastTrending()
+	}
+}
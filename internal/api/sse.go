@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goreddit/internal/storage"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxResumePages bounds how many pages of stored posts are read to resume a
+// client whose last post is no longer among the recent ones
+const maxResumePages = 10
+
+// handleEvents streams the live feed as Server-Sent Events, for clients that
+// can't use WebSockets, e.g. /events?subreddit=worldnews&keyword=ceasefire.
+// It takes the filter and format parameters of /ws and sends the same
+// events, named by their type. Post events carry the post's ID, so a client
+// reconnecting with it in Last-Event-ID (or the last_event_id parameter) is
+// sent the posts it missed instead of the usual replay. A comment line goes
+// out every ping interval to keep idle connections open.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	filter, err := parseFeedFilter(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := parseFeedFormat(params, s.hub.opts.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var resume *feedResume
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = params.Get("last_event_id")
+	}
+	if lastID != "" {
+		if resume, err = s.resumeFrom(r.Context(), lastID, filter); err != nil {
+			log.Printf("API: Failed to resume events after %s, replaying recent posts: %v", lastID, err)
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("API: Can't stream events to %s: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("Event stream client connected from %s", r.RemoteAddr)
+
+	c := s.hub.register(filter, format, resume)
+	defer s.hub.unregister(c)
+	s.welcome(c)
+
+	opts := s.hub.opts
+	heartbeat := time.NewTicker(opts.PingInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case msg := <-c.send:
+			rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			err = writeEvent(w, msg)
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case <-c.done:
+			return // Dropped as a slow client
+		case <-r.Context().Done():
+			return
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			log.Printf("API: Error sending events to %s: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+// writeEvent writes a queued message as a Server-Sent Event. Its data is a
+// single line, as JSON encoding escapes newlines.
+func writeEvent(w http.ResponseWriter, msg message) error {
+	if msg.id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", msg.id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.kind, msg.data)
+	return err
+}
+
+// resumeFrom finds where a client reconnecting after lastID left off. If
+// lastID is no longer among the recent posts, the posts after it by
+// (created_at, id) are read from storage, up to a queue's worth. It returns nil for posts it doesn't
+// know, so the client gets the usual replay.
+func (s *Server) resumeFrom(ctx context.Context, lastID string, filter feedFilter) (*feedResume, error) {
+	if s.hub.recentPost(lastID) {
+		return &feedResume{lastID: lastID}, nil
+	}
+	last, err := s.store.GetPost(ctx, lastID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post %s: %w", lastID, err)
+	}
+
+	resume := &feedResume{lastID: lastID, since: last.CreatedAt}
+	query := storage.PostFilter{
+		MinScore:     filter.MinScore,
+		MinSentiment: filter.MinSentiment,
+		MaxSentiment: filter.MaxSentiment,
+		SortBy:       storage.SortByCreatedAt,
+		Ascending:    true,
+		Limit:        s.hub.opts.QueueSize,
+		Cursor:       storage.CursorAfter(storage.SortByCreatedAt, last),
+	}
+	for page := 0; page < maxResumePages && len(resume.stored) < s.hub.opts.QueueSize; page++ {
+		result, err := s.store.QueryPosts(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query posts after %s: %w", lastID, err)
+		}
+		for _, post := range result.Posts {
+			if filter.matches(&post) {
+				resume.stored = append(resume.stored, post)
+			}
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		query.Cursor = result.NextCursor
+	}
+	if len(resume.stored) > s.hub.opts.QueueSize {
+		resume.stored = resume.stored[:s.hub.opts.QueueSize]
+	}
+	return resume, nil
+}
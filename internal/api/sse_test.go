@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is an event or comment read from a stream
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+// newTestEventServer returns a server with an empty trend detector and the
+// URL of its /events endpoint
+func newTestEventServer(t *testing.T, opts hubOptions) (*Server, string) {
+	t.Helper()
+	s := newTestServer(t)
+	s.trends = analysis.NewTrendDetector(analysis.TrendOptions{Bucket: time.Minute, Window: 5 * time.Minute, Baseline: time.Hour})
+	s.hub = newHub(opts)
+	srv := httptest.NewServer(http.HandlerFunc(s.handleEvents))
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+// streamEvents opens an event stream, sending lastID as Last-Event-ID if set,
+// and returns its events as they arrive
+func streamEvents(t *testing.T, url, lastID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		resp.Body.Close()
+	})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer close(events)
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				events <- ev
+				ev = sseEvent{}
+				continue
+			}
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				ev.id = value
+			case "event":
+				ev.event = value
+			case "data":
+				ev.data = value
+			default:
+				ev.comment = strings.TrimPrefix(line, ": ")
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event of a stream, skipping heartbeats
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("Stream closed")
+			}
+			if ev.comment == "" {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for an event")
+		}
+	}
+}
+
+// nextPostIDs reads events up to the next server_status, returning the IDs
+// of the posts among them
+func nextPostIDs(t *testing.T, events <-chan sseEvent) []string {
+	t.Helper()
+	var ids []string
+	for {
+		ev := nextEvent(t, events)
+		switch ev.event {
+		case eventServerStatus:
+			return ids
+		case eventPost:
+			var msg struct{ Payload reddit.Post }
+			if err := json.Unmarshal([]byte(ev.data), &msg); err != nil {
+				t.Fatalf("Failed to decode post event: %v", err)
+			}
+			if msg.Payload.ID != ev.id {
+				t.Errorf("Expected event ID %s to be the post's, got %s", ev.id, msg.Payload.ID)
+			}
+			ids = append(ids, ev.id)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s, url := newTestEventServer(t, hubOptions{PingInterval: 50 * time.Millisecond})
+	s.hub.publish(reddit.Post{ID: "a", Subreddit: "news", Title: "Flooding in the north"})
+	s.hub.publish(reddit.Post{ID: "b", Subreddit: "politics", Title: "Budget vote"})
+
+	// The stream filters like /ws, replaying the matching recent posts
+	events := streamEvents(t, url+"?subreddit=news", "")
+	if ids := nextPostIDs(t, events); len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("Expected post a replayed, got %v", ids)
+	}
+	s.hub.publish(reddit.Post{ID: "c", Subreddit: "politics", Title: "Budget passes"})
+	s.hub.publish(reddit.Post{ID: "d", Subreddit: "news", Title: "Flood waters recede"})
+	if ev := nextEvent(t, events); ev.event != eventPost || ev.id != "d" {
+		t.Errorf("Expected only the matching post, got %+v", ev)
+	}
+
+	// Idle streams get heartbeats
+	timeout := time.After(2 * time.Second)
+	for heartbeat := false; !heartbeat; {
+		select {
+		case ev := <-events:
+			heartbeat = ev.comment == "heartbeat"
+		case <-timeout:
+			t.Fatal("Expected a heartbeat")
+		}
+	}
+
+	// Raw clients get bare posts
+	raw := streamEvents(t, url+"?format=raw&subreddit=politics", "")
+	ev := nextEvent(t, raw)
+	var post reddit.Post
+	if err := json.Unmarshal([]byte(ev.data), &post); err != nil || ev.event != eventPost || post.ID != "b" {
+		t.Errorf("Expected the bare post b, got %+v (%v)", ev, err)
+	}
+
+	for _, c := range []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/events?min_score=high", http.StatusBadRequest},
+		{http.MethodGet, "/events?format=xml", http.StatusBadRequest},
+		{http.MethodPost, "/events", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		s.handleEvents(rec, httptest.NewRequest(c.method, c.target, nil))
+		if rec.Code != c.status {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.target, c.status, rec.Code)
+		}
+	}
+}
+
+func TestEventsWriteTimeout(t *testing.T) {
+	// The write timeout covers each write, not the wait between them
+	s, url := newTestEventServer(t, hubOptions{PingInterval: 300 * time.Millisecond, WriteTimeout: 100 * time.Millisecond})
+	events := streamEvents(t, url, "")
+	timeout := time.After(5 * time.Second)
+	for heartbeats := 0; heartbeats < 2; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("Stream closed after %d heartbeats", heartbeats)
+			}
+			if ev.comment == "heartbeat" {
+				heartbeats++
+			}
+		case <-timeout:
+			t.Fatalf("Expected 2 heartbeats, got %d", heartbeats)
+		}
+	}
+	s.hub.publish(reddit.Post{ID: "a", Subreddit: "news", Title: "Flooding in the north"})
+	if ev := nextEvent(t, events); ev.event != eventPost || ev.id != "a" {
+		t.Errorf("Expected post a after idling, got %+v", ev)
+	}
+}
+
+func TestEventsResume(t *testing.T) {
+	s, url := newTestEventServer(t, hubOptions{})
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Unix()
+
+	// Posts x to z are stored but no longer recent; c is both
+	for i, id := range []string{"x", "y", "z", "c"} {
+		post := reddit.Post{ID: id, Subreddit: "news", Title: "Post " + id, CreatedAt: float64(base + int64(i)*60)}
+		if err := s.store.SavePost(ctx, post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+		if id == "c" {
+			s.hub.publish(post)
+		}
+	}
+	s.hub.publish(reddit.Post{ID: "d", Subreddit: "news", Title: "Post d", CreatedAt: float64(base + 300)})
+	s.hub.publish(reddit.Post{ID: "e", Subreddit: "politics", Title: "Post e", CreatedAt: float64(base + 360)})
+
+	for _, c := range []struct {
+		lastID, query, want string
+	}{
+		{"c", "", "[d e]"},                    // From the recent posts
+		{"x", "", "[y z c d e]"},              // From storage, then the recent posts
+		{"x", "?subreddit=news", "[y z c d]"}, // Filtered
+		{"unknown", "", "[c d e]"},            // The usual replay
+		{"e", "?last_event_id=ignored", "[]"}, // The header wins
+	} {
+		events := streamEvents(t, url+c.query, c.lastID)
+		if ids := nextPostIDs(t, events); fmtIDs(ids) != c.want {
+			t.Errorf("Resuming after %s%s: expected %s, got %v", c.lastID, c.query, c.want, ids)
+		}
+	}
+
+	events := streamEvents(t, url+"?last_event_id=d", "")
+	if ids := nextPostIDs(t, events); fmtIDs(ids) != "[e]" {
+		t.Errorf("Expected last_event_id to resume too, got %v", ids)
+	}
+}
+
+func TestEventsResumeWithinASecond(t *testing.T) {
+	s, url := newTestEventServer(t, hubOptions{})
+	ctx := context.Background()
+	second := float64(time.Now().Add(-time.Hour).Unix())
+
+	// Posts created in the same second resume by ID, without repeats
+	for _, id := range []string{"m", "n", "o", "p"} {
+		post := reddit.Post{ID: id, Subreddit: "news", Title: "Post " + id, CreatedAt: second}
+		if err := s.store.SavePost(ctx, post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+	s.hub.publish(reddit.Post{ID: "a", Subreddit: "news", Title: "Post a", CreatedAt: second})
+	s.hub.publish(reddit.Post{ID: "q", Subreddit: "news", Title: "Post q", CreatedAt: second + 1})
+
+	events := streamEvents(t, url, "n")
+	if ids := nextPostIDs(t, events); fmtIDs(ids) != "[o p q]" {
+		t.Errorf("Expected the posts after n, got %v", ids)
+	}
+}
+
+func fmtIDs(ids []string) string {
+	return "[" + strings.Join(ids, " ") + "]"
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// storiesResponse is the body returned by /api/stories
+type storiesResponse struct {
+	Count   int             `json:"count"`
+	Stories []storage.Story `json:"stories"`
+}
+
+// storyResponse is the body returned by /api/stories/{id}
+type storyResponse struct {
+	storage.Story
+	PostList []reddit.Post `json:"post_list"`
+}
+
+// handleStories lists stories covered by more than one post, newest first,
+// e.g. /api/stories?since=24h&subreddit=worldnews&min_posts=3
+func (s *Server) handleStories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	now := time.Now()
+	query := storage.StoryQuery{Subreddit: params.Get("subreddit")}
+
+	var err error
+	if query.Since, err = timeParam(params, "since", now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Until, err = timeParam(params, "until", now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.MinPosts, err = intParam(params, "min_posts"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Limit, err = intParam(params, "limit"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stories, err := s.store.ListStories(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, storiesResponse{
+		Count:   len(stories),
+		Stories: stories,
+	})
+}
+
+// handleStory returns one story with its per-subreddit sentiment and posts,
+// e.g. /api/stories/abc123
+func (s *Server) handleStory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/stories/"), "/")
+	if id == "" {
+		s.handleStories(w, r)
+		return
+	}
+
+	story, err := s.store.GetStory(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	page, err := s.store.QueryPosts(r.Context(), storage.PostFilter{
+		StoryID:   id,
+		Ascending: true,
+		Limit:     500,
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, storyResponse{Story: story, PostList: page.Posts})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleStories(t *testing.T) {
+	s := newTestServer(t)
+	now := float64(time.Now().Unix())
+
+	for _, post := range []reddit.Post{
+		{ID: "a", Title: "Quake hits Japan", Subreddit: "worldnews", CreatedAt: now - 600, Sentiment: -1, StoryID: "a"},
+		{ID: "b", Title: "Japan quake", Subreddit: "news", CreatedAt: now - 300, Sentiment: 0, StoryID: "a"},
+		{ID: "c", Title: "Budget passes", Subreddit: "politics", CreatedAt: now - 60, StoryID: "c"},
+	} {
+		if err := s.store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleStories(rec, httptest.NewRequest(http.MethodGet, "/api/stories?since=1h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var list storiesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 1 || list.Stories[0].ID != "a" || len(list.Stories[0].Subreddits) != 2 {
+		t.Errorf("Expected only the cross-posted story, got %+v", list.Stories)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleStory(rec, httptest.NewRequest(http.MethodGet, "/api/stories/a", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var story storyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&story); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if story.ID != "a" || story.Posts != 2 || len(story.PostList) != 2 || story.SentimentSpread != 1 {
+		t.Errorf("Unexpected story: %+v", story)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleStory(rec, httptest.NewRequest(http.MethodGet, "/api/stories/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing story, got %d", rec.Code)
+	}
+	for _, query := range []string{"min_posts=-1", "since=2h&until=3h"} {
+		rec := httptest.NewRecorder()
+		s.handleStories(rec, httptest.NewRequest(http.MethodGet, "/api/stories?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"goreddit/internal/analysis"
+	"goreddit/internal/storage"
+	"log"
+	"net/http"
+	"time"
+)
+
+// trendingMessage is sent to WebSocket clients whenever the set of trending
+// topics changes. It carries the whole set, so clients can replace theirs.
+type trendingMessage struct {
+	Type     string           `json:"type"` // always "trending"
+	Trending []analysis.Trend `json:"trending"`
+}
+
+// trendingResponse is the body returned by /api/trending
+type trendingResponse struct {
+	Count    int              `json:"count"`
+	Trending []analysis.Trend `json:"trending"`
+}
+
+// handleTrending lists the topics trending right now, highest score first,
+// e.g. /api/trending?limit=10
+func (s *Server) handleTrending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit, err := intParam(r.URL.Query(), "limit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	trending := s.trends.Trending()
+	if limit > 0 && len(trending) > limit {
+		trending = trending[:limit]
+	}
+	writeJSON(w, http.StatusOK, trendingResponse{
+		Count:    len(trending),
+		Trending: trending,
+	})
+}
+
+// seedTrends feeds the detector the stored posts of its window and baseline,
+// so that a restarted server doesn't take every topic for a burst
+func (s *Server) seedTrends(ctx context.Context, opts analysis.TrendOptions, now time.Time) error {
+	filter := storage.PostFilter{
+		Since:     now.Add(-opts.Window - opts.Baseline),
+		Ascending: true,
+		Limit:     500,
+	}
+	seeded := 0
+	for {
+		page, err := s.store.QueryPosts(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, post := range page.Posts {
+			s.trends.Observe(post.ID, post.Topics, time.Unix(int64(post.CreatedAt), 0))
+		}
+		seeded += len(page.Posts)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	s.trends.Refresh(now)
+	log.Printf("API: Seeded trend detector with %d stored posts", seeded)
+	return nil
+}
+
+// refreshTrends moves the detector's window along with the clock, so topics
+// stop trending when their posts stop, and tells clients when that happens
+func (s *Server) refreshTrends(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if s.trends.Refresh(now) {
+			s.broadcastTrending()
+		}
+	}
+}
+
+// broadcastTrending sends the current trending topics to every client
+func (s *Server) broadcastTrending() {
+	ev, err := newTrendingEvent(s.trends.Trending(), time.Now())
+	if err != nil {
+		log.Printf("API: Error encoding trending topics: %v", err)
+		return
+	}
+	s.hub.broadcast(ev)
+}
+
+// newTrendingEvent encodes the trending topics, which raw clients get as a
+// trendingMessage
+func newTrendingEvent(trending []analysis.Trend, now time.Time) (feedEvent, error) {
+	return newFeedEvent(eventTrending, trendingPayload{Trending: trending}, trendingMessage{Type: "trending", Trending: trending}, now)
+}
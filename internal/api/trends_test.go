@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleTrending(t *testing.T) {
+	s := newTestServer(t)
+	opts := analysis.TrendOptions{Bucket: time.Minute, Window: 5 * time.Minute, Baseline: time.Hour, Threshold: 3, MinPosts: 3}
+	s.trends = analysis.NewTrendDetector(opts)
+
+	// An hour of stored posts about the budget, then a burst about a ceasefire
+	now := time.Now().Truncate(time.Minute)
+	var posts []reddit.Post
+	for m := 65; m > 0; m-- {
+		posts = append(posts, reddit.Post{ID: fmt.Sprintf("budget-%d", m), Title: "Budget", Subreddit: "news", CreatedAt: float64(now.Add(-time.Duration(m) * time.Minute).Unix()), Topics: []string{"Budget"}})
+	}
+	for i := 0; i < 5; i++ {
+		posts = append(posts, reddit.Post{ID: fmt.Sprintf("ceasefire-%d", i), Title: "Ceasefire", Subreddit: "news", CreatedAt: float64(now.Unix()), Topics: []string{"Ceasefire", "Budget"}})
+	}
+	for _, post := range posts {
+		if err := s.store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+	if err := s.seedTrends(context.Background(), opts, now); err != nil {
+		t.Fatalf("Failed to seed trends: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleTrending(rec, httptest.NewRequest(http.MethodGet, "/api/trending?limit=5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp trendingResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 1 || resp.Trending[0].Topic != "Ceasefire" || resp.Trending[0].Posts != 5 {
+		t.Errorf("Expected only Ceasefire to trend, got %+v", resp.Trending)
+	}
+
+	// Posts the consumer delivers again after the seed aren't counted twice
+	for _, post := range posts {
+		s.receive(post)
+	}
+	if trending := s.trends.Trending(); len(trending) != 1 || trending[0].Topic != "Ceasefire" || trending[0].Posts != 5 {
+		t.Errorf("Expected redelivered posts to leave the trends alone, got %+v", trending)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleTrending(rec, httptest.NewRequest(http.MethodGet, "/api/trending?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad limit, got %d", rec.Code)
+	}
+}
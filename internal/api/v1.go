@@ -0,0 +1,326 @@
+package api
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// v1Prefix is where the versioned REST API is served
+const v1Prefix = "/api/v1"
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// v1Error is the envelope of every /api/v1 error response
+type v1Error struct {
+	Error v1ErrorBody `json:"error"`
+}
+
+type v1ErrorBody struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// v1ErrorCodes are the machine-readable codes of the statuses /api/v1 returns
+var v1ErrorCodes = map[int]string{
+	http.StatusBadRequest:          "invalid_request",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// v1Post is a post as /api/v1 returns it. Unlike reddit.Post, which keeps
+// the names of its Go fields on the WebSocket, its fields are snake_case like
+// the rest of the API.
+type v1Post struct {
+	ID               string          `json:"id"`
+	Title            string          `json:"title"`
+	Body             string          `json:"body"`
+	Summary          string          `json:"summary"`
+	Subreddit        string          `json:"subreddit"`
+	Score            int32           `json:"score"`
+	URL              string          `json:"url"`
+	Domain           string          `json:"domain"`
+	CreatedAt        float64         `json:"created_at"`
+	Sentiment        float64         `json:"sentiment"`
+	SentimentModel   string          `json:"sentiment_model"`
+	Emotions         reddit.Emotions `json:"emotions"`
+	Toxicity         float64         `json:"toxicity"`
+	Topics           []string        `json:"topics"`
+	Entities         []reddit.Entity `json:"entities"`
+	StoryID          string          `json:"story_id"`
+	Language         string          `json:"language"`
+	AnalyzerVersions string          `json:"analyzer_versions"`
+	Unsupported      bool            `json:"unsupported"`
+	Unscored         bool            `json:"unscored"`
+}
+
+func newV1Post(post reddit.Post) v1Post {
+	v := v1Post{
+		ID:               post.ID,
+		Title:            post.Title,
+		Body:             post.Body,
+		Summary:          post.Summary,
+		Subreddit:        post.Subreddit,
+		Score:            post.Score,
+		URL:              post.URL,
+		Domain:           post.Domain,
+		CreatedAt:        post.CreatedAt,
+		Sentiment:        post.Sentiment,
+		SentimentModel:   post.SentimentModel,
+		Emotions:         post.Emotions,
+		Toxicity:         post.Toxicity,
+		Topics:           post.Topics,
+		Entities:         post.Entities,
+		StoryID:          post.StoryID,
+		Language:         post.Language,
+		AnalyzerVersions: post.AnalyzerVersions,
+		Unsupported:      post.Unsupported,
+		Unscored:         post.Unscored,
+	}
+	// Empty lists rather than null, so clients needn't check
+	if v.Topics == nil {
+		v.Topics = []string{}
+	}
+	if v.Entities == nil {
+		v.Entities = []reddit.Entity{}
+	}
+	return v
+}
+
+// postsPage is the body returned by /api/v1/posts
+type postsPage struct {
+	Count      int      `json:"count"`
+	Posts      []v1Post `json:"posts"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// subredditsResponse is the body returned by /api/v1/subreddits
+type subredditsResponse struct {
+	Count      int                      `json:"count"`
+	Subreddits []storage.SubredditStats `json:"subreddits"`
+}
+
+// topicsResponse is the body returned by /api/v1/topics
+type topicsResponse struct {
+	Count  int                 `json:"count"`
+	Topics []reddit.TopicCount `json:"topics"`
+}
+
+// handleV1 routes the versioned REST API, which is described by the OpenAPI
+// document at /api/v1/openapi.yaml. Unlike the older /api endpoints, every
+// response carries an ETag and every error the same envelope.
+func (s *Server) handleV1(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeV1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, v1Prefix), "/")
+	switch {
+	case path == "/posts":
+		s.handleV1Posts(w, r)
+	case strings.HasPrefix(path, "/posts/"):
+		s.handleV1Post(w, r, strings.TrimPrefix(path, "/posts/"))
+	case path == "/subreddits":
+		s.handleV1Subreddits(w, r)
+	case path == "/topics":
+		s.handleV1Topics(w, r)
+	case path == "/openapi.yaml":
+		writeCached(w, r, "application/yaml", openAPIDocument)
+	default:
+		writeV1Error(w, http.StatusNotFound, fmt.Sprintf("no endpoint %s", r.URL.Path))
+	}
+}
+
+// handleV1Posts pages through stored posts matching the post filter
+// parameters, e.g. /api/v1/posts?subreddit=worldnews&sort=score&limit=20.
+// The next page is at the cursor in next_cursor, also linked from the Link
+// header.
+func (s *Server) handleV1Posts(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter, err := parsePostFilter(params, time.Now())
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.SortBy = storage.SortField(params.Get("sort"))
+	switch order := params.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		writeV1Error(w, http.StatusBadRequest, fmt.Sprintf("invalid order %q: use asc or desc", order))
+		return
+	}
+	if filter.Limit, err = intParam(params, "limit"); err != nil {
+		writeV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Cursor = params.Get("cursor")
+
+	page, err := s.store.QueryPosts(r.Context(), filter)
+	if err != nil {
+		writeV1StoreError(w, err)
+		return
+	}
+	posts := make([]v1Post, len(page.Posts))
+	for i, post := range page.Posts {
+		posts[i] = newV1Post(post)
+	}
+	if page.NextCursor != "" {
+		next := r.URL.Query()
+		next.Set("cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, next.Encode()))
+	}
+	writeV1JSON(w, r, postsPage{
+		Count:      len(posts),
+		Posts:      posts,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// handleV1Post returns one stored post, e.g. /api/v1/posts/abc123
+func (s *Server) handleV1Post(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "" || strings.Contains(id, "/") {
+		writeV1Error(w, http.StatusNotFound, fmt.Sprintf("no endpoint %s", r.URL.Path))
+		return
+	}
+	post, err := s.store.GetPost(r.Context(), id)
+	if err != nil {
+		writeV1StoreError(w, err)
+		return
+	}
+	writeV1JSON(w, r, newV1Post(post))
+}
+
+// handleV1Subreddits lists the subreddits with the most posts over a window,
+// with their average sentiment and toxicity, e.g. /api/v1/subreddits?since=168h
+func (s *Server) handleV1Subreddits(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	since, until, limit, err := windowParams(params, time.Now())
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stats, err := s.store.TopSubreddits(r.Context(), storage.TopSubredditsQuery{Since: since, Until: until, Limit: limit})
+	if err != nil {
+		writeV1StoreError(w, err)
+		return
+	}
+	writeV1JSON(w, r, subredditsResponse{Count: len(stats), Subreddits: stats})
+}
+
+// handleV1Topics lists the most frequent topics over a window, optionally in
+// one subreddit, e.g. /api/v1/topics?subreddit=worldnews&since=48h
+func (s *Server) handleV1Topics(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	since, until, limit, err := windowParams(params, time.Now())
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	topics, err := s.store.TopTopics(r.Context(), storage.TopTopicsQuery{
+		Subreddit: params.Get("subreddit"),
+		Since:     since,
+		Until:     until,
+		Limit:     limit,
+	})
+	if err != nil {
+		writeV1StoreError(w, err)
+		return
+	}
+	writeV1JSON(w, r, topicsResponse{Count: len(topics), Topics: topics})
+}
+
+// windowParams reads the since, until and limit of a rollup query. The
+// window defaults to the last 24 hours.
+func windowParams(params url.Values, now time.Time) (since, until time.Time, limit int, err error) {
+	if since, err = timeParam(params, "since", now); err != nil {
+		return
+	}
+	if since.IsZero() {
+		since = now.Add(-24 * time.Hour)
+	}
+	if until, err = timeParam(params, "until", now); err != nil {
+		return
+	}
+	limit, err = intParam(params, "limit")
+	return
+}
+
+// writeV1JSON writes body with an ETag, or just 304 Not Modified if the
+// client already has it
+func writeV1JSON(w http.ResponseWriter, r *http.Request, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("API: Error encoding response: %v", err)
+		writeV1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeCached(w, r, "application/json", append(data, '\n'))
+}
+
+// writeCached serves data under an ETag of its hash. Clients must revalidate
+// every time, since posts change as their scores do.
+func writeCached(w http.ResponseWriter, r *http.Request, contentType string, data []byte) {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("API: Error writing response: %v", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match too, as GET requests compare them weakly.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeV1Error(w http.ResponseWriter, status int, message string) {
+	code, ok := v1ErrorCodes[status]
+	if !ok {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+	writeJSON(w, status, v1Error{Error: v1ErrorBody{Status: status, Code: code, Message: message}})
+}
+
+// writeV1StoreError maps storage errors to HTTP statuses
+func writeV1StoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidFilter):
+		writeV1Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		writeV1Error(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("API: Storage error: %v", err)
+		writeV1Error(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"goreddit/internal/reddit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func newTestV1Server(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer(t)
+	now := float64(time.Now().Unix())
+	for _, post := range []reddit.Post{
+		{ID: "a", Title: "Ceasefire agreed", Subreddit: "worldnews", Score: 30, CreatedAt: now - 3600, Topics: []string{"Ceasefire"}},
+		{ID: "b", Title: "Ceasefire holds", Subreddit: "worldnews", Score: 20, CreatedAt: now - 1800, Topics: []string{"Ceasefire"}, Toxicity: 0.8},
+		{ID: "c", Title: "Election results", Subreddit: "politics", Score: 10, CreatedAt: now - 60, Topics: []string{"Election"}},
+	} {
+		if err := s.store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+	return s
+}
+
+func getV1(t *testing.T, s *Server, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	s.handleV1(rec, req)
+	return rec
+}
+
+func TestV1Posts(t *testing.T) {
+	s := newTestV1Server(t)
+
+	rec := getV1(t, s, "/api/v1/posts?subreddit=worldnews&sort=score&limit=1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var page postsPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if page.Count != 1 || page.Posts[0].ID != "a" || page.NextCursor == "" {
+		t.Fatalf("Expected the top post and a cursor, got %+v", page)
+	}
+	if link := rec.Header().Get("Link"); !strings.Contains(link, "cursor="+page.NextCursor) || !strings.HasSuffix(link, `rel="next"`) {
+		t.Errorf("Expected a Link to the next page, got %q", link)
+	}
+
+	rec = getV1(t, s, "/api/v1/posts?subreddit=worldnews&sort=score&limit=1&cursor="+page.NextCursor, nil)
+	page = postsPage{}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if page.Count != 1 || page.Posts[0].ID != "b" || page.NextCursor != "" || rec.Header().Get("Link") != "" {
+		t.Errorf("Expected the last page with post b, got %+v", page)
+	}
+
+	rec = getV1(t, s, "/api/v1/posts?order=asc&min_toxicity=0.5", nil)
+	if !strings.Contains(rec.Body.String(), `"count":1`) || !strings.Contains(rec.Body.String(), `"id":"b"`) {
+		t.Errorf("Expected only the toxic post, got %s", rec.Body)
+	}
+
+	rec = getV1(t, s, "/api/v1/posts/c", nil)
+	var post map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&post); err != nil || rec.Code != http.StatusOK || post["title"] != "Election results" {
+		t.Errorf("Expected post c, got %d %+v (%v)", rec.Code, post, err)
+	}
+	// Fields are snake_case, and lists are never null
+	for _, field := range []string{"created_at", "story_id", "analyzer_versions", "sentiment_model"} {
+		if _, ok := post[field]; !ok {
+			t.Errorf("Expected field %s in %+v", field, post)
+		}
+	}
+	if entities, ok := post["entities"].([]any); !ok || len(entities) != 0 {
+		t.Errorf("Expected no entities as an empty list, got %v", post["entities"])
+	}
+}
+
+func TestV1ETag(t *testing.T) {
+	s := newTestV1Server(t)
+
+	rec := getV1(t, s, "/api/v1/posts/a", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d %v", rec.Code, rec.Header())
+	}
+
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		rec = getV1(t, s, "/api/v1/posts/a", http.Header{"If-None-Match": {header}})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: expected 304 with no body, got %d %q", header, rec.Code, rec.Body)
+		}
+	}
+
+	// A new score changes the representation
+	post, _ := s.store.GetPost(context.Background(), "a")
+	post.Score = 99
+	if err := s.store.SavePost(context.Background(), post); err != nil {
+		t.Fatalf("Failed to save post: %v", err)
+	}
+	rec = getV1(t, s, "/api/v1/posts/a", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag after an update, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestV1Errors(t *testing.T) {
+	s := newTestV1Server(t)
+
+	for _, c := range []struct {
+		method, target string
+		status         int
+		code           string
+	}{
+		{http.MethodGet, "/api/v1/posts/missing", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/v1/comments", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/v1/posts?sort=title", http.StatusBadRequest, "invalid_request"},
+		{http.MethodGet, "/api/v1/posts?order=up", http.StatusBadRequest, "invalid_request"},
+		{http.MethodGet, "/api/v1/posts?cursor=bogus", http.StatusBadRequest, "invalid_request"},
+		{http.MethodGet, "/api/v1/posts?since=yesterday", http.StatusBadRequest, "invalid_request"},
+		{http.MethodGet, "/api/v1/topics?limit=-1", http.StatusBadRequest, "invalid_request"},
+		{http.MethodPost, "/api/v1/posts", http.StatusMethodNotAllowed, "method_not_allowed"},
+	} {
+		rec := httptest.NewRecorder()
+		s.handleV1(rec, httptest.NewRequest(c.method, c.target, nil))
+		var body v1Error
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Errorf("%s %s: failed to decode error: %v", c.method, c.target, err)
+			continue
+		}
+		if rec.Code != c.status || body.Error.Status != c.status || body.Error.Code != c.code || body.Error.Message == "" {
+			t.Errorf("%s %s: expected %d %s, got %d %+v", c.method, c.target, c.status, c.code, rec.Code, body)
+		}
+	}
+}
+
+func TestV1Rollups(t *testing.T) {
+	s := newTestV1Server(t)
+
+	rec := getV1(t, s, "/api/v1/subreddits?since=48h", nil)
+	var subreddits subredditsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&subreddits); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if subreddits.Count != 2 || subreddits.Subreddits[0].Subreddit != "worldnews" || subreddits.Subreddits[0].ToxicShare != 0.5 {
+		t.Errorf("Unexpected subreddits: %+v", subreddits)
+	}
+
+	rec = getV1(t, s, "/api/v1/topics?subreddit=worldnews", nil)
+	var topics topicsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&topics); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if topics.Count != 1 || topics.Topics[0] != (reddit.TopicCount{Topic: "Ceasefire", Count: 2}) {
+		t.Errorf("Unexpected topics: %+v", topics)
+	}
+}
+
+func TestV1OpenAPI(t *testing.T) {
+	s := newTestServer(t)
+	rec := getV1(t, s, "/api/v1/openapi.yaml", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("Expected the YAML document, got %d %v", rec.Code, rec.Header())
+	}
+
+	var doc struct {
+		OpenAPI string                    `yaml:"openapi"`
+		Paths   map[string]map[string]any `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse the document: %v", err)
+	}
+	for _, path := range []string{"/posts", "/posts/{id}", "/subreddits", "/topics", "/openapi.yaml"} {
+		if _, ok := doc.Paths[path]["get"]; !ok {
+			t.Errorf("Expected the document to describe GET %s", path)
+		}
+	}
+}
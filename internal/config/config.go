@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -20,6 +21,10 @@ type Config struct {
 		GroupID string   `mapstructure:"group_id"`
 	} `mapstructure:"kafka"`
 
+	Storage struct {
+		Driver string `mapstructure:"driver"` // "postgres" (default) or "sqlite"
+	} `mapstructure:"storage"`
+
 	Postgres struct {
 		Host     string `mapstructure:"host"`
 		Port     int    `mapstructure:"port"`
@@ -29,8 +34,75 @@ type Config struct {
 		SSLMode  string `mapstructure:"sslmode"`
 	} `mapstructure:"postgres"`
 
+	SQLite struct {
+		Path string `mapstructure:"path"`
+	} `mapstructure:"sqlite"`
+
+	Retention struct {
+		PartitionInterval string        `mapstructure:"partition_interval"` // "day" (default) or "week"
+		PremakePartitions int           `mapstructure:"premake_partitions"`
+		KeepDays          int           `mapstructure:"keep_days"` // 0 keeps posts forever
+		ArchiveDir        string        `mapstructure:"archive_dir"`
+		CheckInterval     time.Duration `mapstructure:"check_interval"`
+	} `mapstructure:"retention"`
+
+	Sentiment struct {
+		Analyzer         string  `mapstructure:"analyzer"`          // "bayes" (default)
+		NeutralThreshold float64 `mapstructure:"neutral_threshold"` // scores closer to 0 than this are neutral
+		Model            string  `mapstructure:"model"`             // model file written by cmd/train-sentiment, for the bayes analyzer
+	} `mapstructure:"sentiment"`
+
+	Topics struct {
+		Stopwords     map[string][]string `mapstructure:"stopwords"`      // extra stopwords by language code
+		StopwordFiles map[string]string   `mapstructure:"stopword_files"` // stopword files by language code, one word per line
+		Phrases       []string            `mapstructure:"phrases"`        // extra multi-word topics, like "climate change"
+		PhraseFiles   []string            `mapstructure:"phrase_files"`   // more phrases, one per line, like those written by cmd/collocations
+	} `mapstructure:"topics"`
+
+	Trends struct {
+		Bucket    time.Duration `mapstructure:"bucket"`    // granularity of topic counts
+		Window    time.Duration `mapstructure:"window"`    // recent span scored against the baseline
+		Baseline  time.Duration `mapstructure:"baseline"`  // span before the window that sets normal rates
+		Threshold float64       `mapstructure:"threshold"` // score (standard deviations) to start trending
+		MinPosts  int           `mapstructure:"min_posts"` // fewest posts in the window to trend
+	} `mapstructure:"trends"`
+
+	Stories struct {
+		Window     time.Duration `mapstructure:"window"`     // how far apart posts about one story can be
+		Similarity float64       `mapstructure:"similarity"` // title similarity (0-1) for posts to be one story
+	} `mapstructure:"stories"`
+
+	Summary struct {
+		MinLength int `mapstructure:"min_length"` // characters a post body needs to be summarized
+		Sentences int `mapstructure:"sentences"`  // sentences a summary keeps
+	} `mapstructure:"summary"`
+
+	Toxicity struct {
+		Model string `mapstructure:"model"` // model file written by cmd/toxicity; the embedded model if empty
+	} `mapstructure:"toxicity"`
+
+	Outlets struct {
+		File string `mapstructure:"file"` // CSV of domain, name, bias and category per outlet
+	} `mapstructure:"outlets"`
+
+	Alerts struct {
+		Rules                []map[string]interface{} `mapstructure:"rules"`                  // read-only rules, in the same form as the API's
+		ReloadInterval       time.Duration            `mapstructure:"reload_interval"`        // how often consumers pick up rules edited through the API
+		Timeout              time.Duration            `mapstructure:"timeout"`                // per webhook request
+		Retries              *int                     `mapstructure:"retries"`                // further attempts after a failed delivery; 0 disables retries, unset is 3
+		APIToken             string                   `mapstructure:"api_token"`              // bearer token for changing rules through the API; unset leaves them read-only
+		AllowPrivateWebhooks bool                     `mapstructure:"allow_private_webhooks"` // let rules from the API post to loopback, private and link-local addresses
+	} `mapstructure:"alerts"`
+
 	API struct {
-		Port int `mapstructure:"port"`
+		Port      int `mapstructure:"port"`
+		WebSocket struct {
+			QueueSize    int           `mapstructure:"queue_size"`    // messages waiting to be sent to each client
+			SlowClients  string        `mapstructure:"slow_clients"`  // "drop_oldest" (default) or "disconnect" when a queue is full
+			PingInterval time.Duration `mapstructure:"ping_interval"` // how often clients are pinged
+			WriteTimeout time.Duration `mapstructure:"write_timeout"` // per message, and for a pong to come back
+			Format       string        `mapstructure:"format"`        // "envelope" (default) or "raw", for clients that don't ask
+		} `mapstructure:"websocket"`
 	} `mapstructure:"api"`
 }
 
@@ -3,36 +3,83 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"goreddit/internal/alerts"
+	"goreddit/internal/analysis"
 	"goreddit/internal/config"
 	"goreddit/internal/reddit"
 	"goreddit/internal/storage"
 	"log"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/cdipaolo/sentiment"
 	kafka "github.com/segmentio/kafka-go"
 )
 
 type Consumer struct {
-	reader *kafka.Reader
-	store  *storage.PostgresStore
-	cfg    *config.Config
+	reader   *kafka.Reader
+	store    storage.Store
+	enricher *analysis.Enricher
+	stories  *analysis.StoryClusterer
+	alerts   *alerts.Engine // nil without a store
+	cfg      *config.Config
+	// storyWindow is how far apart posts of a story can be
+	storyWindow time.Duration
 }
 
-func NewConsumer(cfg *config.Config, store *storage.PostgresStore) (*Consumer, error) {
+func NewConsumer(cfg *config.Config, store storage.Store) (*Consumer, error) {
+	enricher, err := analysis.NewEnricher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	storyOpts := analysis.StoryOptionsFromConfig(cfg)
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers: cfg.Kafka.Brokers,
 		Topic:   cfg.Kafka.Topic,
 		GroupID: cfg.Kafka.GroupID,
 	})
 
-	return &Consumer{
-		reader: reader,
-		store:  store,
-		cfg:    cfg,
-	}, nil
+	c := &Consumer{
+		reader:      reader,
+		store:       store,
+		enricher:    enricher,
+		stories:     analysis.NewStoryClusterer(storyOpts),
+		cfg:         cfg,
+		storyWindow: storyOpts.Window,
+	}
+	if store != nil {
+		if err := c.seedStories(context.Background(), time.Now()); err != nil {
+			log.Printf("Failed to seed story clusters from storage: %v", err)
+		}
+		if c.alerts, err = alerts.NewEngine(cfg, store); err != nil {
+			return nil, err
+		}
+		c.alerts.Start()
+	}
+	return c, nil
+}
+
+// seedStories feeds the clusterer the stored posts of its window, so that
+// posts after a restart join the stories of posts from before it
+func (c *Consumer) seedStories(ctx context.Context, now time.Time) error {
+	filter := storage.PostFilter{
+		Since:     now.Add(-c.storyWindow),
+		Ascending: true,
+		Limit:     500,
+	}
+	for {
+		page, err := c.store.QueryPosts(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for i := range page.Posts {
+			c.stories.Assign(&page.Posts[i])
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		filter.Cursor = page.NextCursor
+	}
 }
 
 func (c *Consumer) Start(ctx context.Context) error {
@@ -57,81 +104,72 @@ func (c *Consumer) Start(ctx context.Context) error {
 				continue
 			}
 
-			if err := c.store.SavePost(ctx, post); err != nil {
+			c.enrich(ctx, &post)
+
+			if err := c.save(ctx, &post); err != nil {
 				log.Printf("STANDALONE CONSUMER: Error saving post: %v", err)
 				continue
 			}
 
 			log.Printf("STANDALONE CONSUMER: Saved post: %s from r/%s", post.Title, post.Subreddit)
+			c.alert(ctx, post)
 		}
 	}
 }
 
 func (c *Consumer) Close() error {
+	if c.alerts != nil {
+		c.alerts.Close()
+	}
 	return c.reader.Close()
 }
 
-func (c *Consumer) extractTopics(text string) []string {
-	// Convert to lowercase and remove special characters
-	text = strings.ToLower(text)
-	reg := regexp.MustCompile(`[^a-zA-Z\s]`)
-	text = reg.ReplaceAllString(text, " ")
-
-	// Split into words
-	words := strings.Fields(text)
-
-	// Filter common words and short words
-	topics := make(map[string]bool)
-	stopWords := map[string]bool{
-		"the": true, "be": true, "to": true, "of": true, "and": true,
-		"a": true, "in": true, "that": true, "have": true, "i": true,
-		"it": true, "for": true, "not": true, "on": true, "with": true,
-		"he": true, "as": true, "you": true, "do": true, "at": true,
-		"this": true, "but": true, "his": true, "by": true, "from": true,
-		"they": true, "we": true, "say": true, "her": true, "she": true,
-		"or": true, "an": true, "will": true, "my": true, "one": true,
-		"all": true, "would": true, "there": true, "their": true,
-		"reddit": true, "post": true, "comment": true, "thread": true,
-		"just": true, "like": true, "want": true, "need": true, "got": true,
-		"see": true, "know": true, "think": true, "way": true, "time": true,
-		"people": true, "other": true, "same": true, "good": true, "bad": true,
-		"great": true,
+// alert evaluates the alert rules against a saved post
+func (c *Consumer) alert(ctx context.Context, post reddit.Post) {
+	if c.alerts == nil {
+		return
 	}
-
-	for _, word := range words {
-		// Skip stop words, short words, and common Reddit terms
-		if !stopWords[word] && len(word) > 3 {
-			// Capitalize first letter for better presentation
-			word = strings.Title(strings.ToLower(word))
-			topics[word] = true
-		}
+	if fired := c.alerts.Evaluate(ctx, post, time.Now()); len(fired) > 0 {
+		log.Printf("Post %s fired alerts: %s", post.ID, strings.Join(fired, ", "))
 	}
+}
 
-	// Convert map to slice
-	result := make([]string, 0, len(topics))
-	for topic := range topics {
-		result = append(result, topic)
-	}
+// enrich runs the analyzers over a post and assigns it to a story. Both
+// consumers enrich before saving, since the rollups are maintained from the
+// saved values.
+func (c *Consumer) enrich(ctx context.Context, post *reddit.Post) {
+	c.enricher.Enrich(post)
+	c.settleStory(ctx, post)
+	c.stories.Assign(post)
+}
 
-	return result
+// save stores a post and takes on the story it was stored with
+func (c *Consumer) save(ctx context.Context, post *reddit.Post) error {
+	if err := c.store.SavePost(ctx, *post); err != nil {
+		return err
+	}
+	c.settleStory(ctx, post)
+	c.stories.Assign(post)
+	return nil
 }
 
-func (c *Consumer) analyzeSentiment(text string) float64 {
-	// Initialize sentiment analyzer
-	model, err := sentiment.Restore()
+// settleStory sets the post's story to the one storage has for it, if any.
+// Both consumers cluster posts, each with its own history, so before
+// clustering a post this joins it to the story of an earlier post with the
+// same link, and after saving it this picks up the story of whichever
+// consumer saved it first. Both then alert on and publish the same story.
+func (c *Consumer) settleStory(ctx context.Context, post *reddit.Post) {
+	if c.store == nil {
+		return
+	}
+	storyID, err := c.store.PostStory(ctx, *post, c.storyWindow)
 	if err != nil {
-		log.Printf("Error initializing sentiment analyzer: %v", err)
-		return 0
+		log.Printf("Failed to settle story of post %s: %v", post.ID, err)
+		return
 	}
-
-	// Combine title and body for analysis
-	analysis := model.SentimentAnalysis(strings.ToLower(text), sentiment.English)
-
-	// Convert 0/1 score to -1 to 1 range and handle neutral case
-	if analysis.Score == 0 {
-		return -1.0 // Negative
+	if storyID != "" {
+		post.StoryID = storyID
 	}
-	return 1.0 // Positive
 }
 
 func (c *Consumer) StartWithChannel(ctx context.Context, posts chan<- reddit.Post) error {
@@ -200,15 +238,7 @@ func (c *Consumer) StartWithChannel(ctx context.Context, posts chan<- reddit.Pos
 				continue
 			}
 
-			// Extract topics from title and body
-			text := post.Title
-			if post.Body != "" {
-				text += " " + post.Body
-			}
-			post.Topics = c.extractTopics(text)
-
-			// Analyze sentiment
-			post.Sentiment = c.analyzeSentiment(text)
+			c.enrich(ctx, &post)
 
 			processDuration := time.Since(processStart)
 			log.Printf("API CONSUMER: [%v] Processed post in %v - ID: %s, Title: %s",
@@ -220,11 +250,12 @@ func (c *Consumer) StartWithChannel(ctx context.Context, posts chan<- reddit.Pos
 			// Save to DB if store is provided
 			if c.store != nil {
 				dbStart := time.Now()
-				if err := c.store.SavePost(ctx, post); err != nil {
+				if err := c.save(ctx, &post); err != nil {
 					log.Printf("API CONSUMER: Error saving post: %v", err)
 					// Continue anyway to send to WebSocket
 				} else {
 					log.Printf("API CONSUMER: Saved to DB in %v", time.Since(dbStart))
+					c.alert(ctx, post)
 				}
 			}
 
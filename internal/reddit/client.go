@@ -34,15 +34,78 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 // Post represents a Reddit post with the fields we care about
 type Post struct {
-	ID        string
-	Title     string
-	Body      string
-	Subreddit string
-	Score     int32
-	URL       string
-	CreatedAt float64
-	Sentiment float64  // -1.0 to 1.0 sentiment score
-	Topics    []string // Add this field
+	ID             string
+	Title          string
+	Body           string
+	Summary        string // Key sentences of a long Body; empty for short ones
+	Subreddit      string
+	Score          int32
+	URL            string
+	Domain         string // Registrable domain of URL, like "bbc.co.uk", or "self.<subreddit>"
+	CreatedAt      float64
+	Sentiment      float64  // -1.0 to 1.0 sentiment score
+	SentimentModel string   // Analyzer and model version that scored Sentiment, like "bayes:imdb"
+	Emotions       Emotions // Shares of the post's emotion words in each emotion
+	Toxicity       float64  // 0 to 1 probability the text is toxic; see ToxicThreshold
+	Topics         []string // Add this field
+	Entities       []Entity // People, places and organizations mentioned
+	StoryID        string   // Shared by posts about the same story, like cross-posts
+	Language       string   // ISO 639-1 code, like "en"; empty if it couldn't be told
+	// AnalyzerVersions lists the versions of the analyzers that enriched the
+	// post; see analysis.Enricher.Versions
+	AnalyzerVersions string
+	// Unsupported is set when there's no sentiment model for Language, so
+	// Sentiment was left neutral
+	Unsupported bool
+	// Unscored is set when there's no emotion or toxicity model for Language,
+	// so Emotions and Toxicity don't say anything about the post
+	Unscored bool
+}
+
+// ToxicThreshold is the Toxicity from which a post counts as toxic
+const ToxicThreshold = 0.5
+
+// Emotions is an emotion vector over the eight categories of the NRC Emotion
+// Lexicon. Each value is the share of a text's emotion word associations in
+// that category, so they sum to 1, or are all 0 if it has none.
+type Emotions struct {
+	Anger        float64 `json:"anger"`
+	Anticipation float64 `json:"anticipation"`
+	Disgust      float64 `json:"disgust"`
+	Fear         float64 `json:"fear"`
+	Joy          float64 `json:"joy"`
+	Sadness      float64 `json:"sadness"`
+	Surprise     float64 `json:"surprise"`
+	Trust        float64 `json:"trust"`
+}
+
+// EmotionNames lists the emotions in the order of Emotions.Fields
+var EmotionNames = []string{"anger", "anticipation", "disgust", "fear", "joy", "sadness", "surprise", "trust"}
+
+// Fields returns pointers to the values of e in the order of EmotionNames
+func (e *Emotions) Fields() []*float64 {
+	return []*float64{&e.Anger, &e.Anticipation, &e.Disgust, &e.Fear, &e.Joy, &e.Sadness, &e.Surprise, &e.Trust}
+}
+
+// Entity types extracted from posts
+const (
+	EntityPerson       = "PERSON"
+	EntityPlace        = "GPE" // countries, cities and other geopolitical entities
+	EntityOrganization = "ORG"
+)
+
+// Entity is a named entity in its normalized form, e.g. "United States" for
+// "U.S." or "America"
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// EntityCount represents an entity and its frequency
+type EntityCount struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
 }
 
 // TopicCount represents a topic and its frequency
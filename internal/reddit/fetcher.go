@@ -2,6 +2,7 @@ package reddit
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -11,6 +12,16 @@ import (
 
 type PostChannel chan Post
 
+const (
+	// recheckInterval is how often the latest posts are fetched again, so
+	// that changes to their score or text, and their removal, reach the
+	// consumers
+	recheckInterval = time.Minute
+	// recheckPosts is how many of the latest posts are fetched again, which
+	// is as many as one request returns
+	recheckPosts = 100
+)
+
 // List of news and politics related subreddits
 var TargetSubreddits = []string{
 	"news",
@@ -28,6 +39,8 @@ var TargetSubreddits = []string{
 func (c *Client) StreamPosts(ctx context.Context, posts PostChannel) {
 	log.Printf("Starting to poll the following subreddits: %s", strings.Join(TargetSubreddits, ", "))
 	seenPosts := make(map[string]bool)
+	var recent []Post // the last recheckPosts posts sent, oldest first
+	lastRecheck := time.Now()
 
 	// Create a map for O(1) lookup of valid subreddits
 	validSubreddits := make(map[string]bool)
@@ -78,6 +91,10 @@ func (c *Client) StreamPosts(ctx context.Context, posts PostChannel) {
 				select {
 				case posts <- post:
 					seenPosts[submission.ID] = true
+					if len(recent) == recheckPosts {
+						recent = append(recent[:0], recent[1:]...)
+					}
+					recent = append(recent, post)
 					log.Printf("Sent new post from r/%s: %s", post.Subreddit, post.Title)
 				case <-ctx.Done():
 					close(posts)
@@ -85,6 +102,25 @@ func (c *Client) StreamPosts(ctx context.Context, posts PostChannel) {
 				}
 			}
 
+			// Send the latest posts again if they've changed since
+			if len(recent) > 0 && time.Since(lastRecheck) >= recheckInterval {
+				lastRecheck = time.Now()
+				changed, err := c.recheck(ctx, recent)
+				if err != nil {
+					log.Printf("Error rechecking posts: %v", err)
+				}
+				for _, post := range changed {
+					select {
+					case posts <- post:
+						log.Printf("Sent changed post from r/%s: %s", post.Subreddit, post.Title)
+					case <-ctx.Done():
+						close(posts)
+						return
+					}
+				}
+				recent = withoutRemoved(recent)
+			}
+
 			// Clean up old posts periodically
 			if len(seenPosts) > 10000 {
 				newSeen := make(map[string]bool)
@@ -100,3 +136,54 @@ func (c *Client) StreamPosts(ctx context.Context, posts PostChannel) {
 		}
 	}
 }
+
+// recheck fetches the recent posts again and returns those that changed
+func (c *Client) recheck(ctx context.Context, recent []Post) ([]Post, error) {
+	ids := make([]string, len(recent))
+	for i, post := range recent {
+		ids[i] = "t3_" + post.ID
+	}
+	submissions, _, err := c.client.Listings.GetPosts(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+	}
+	return updatePosts(recent, submissions), nil
+}
+
+// updatePosts updates the recent posts in place from their submissions as
+// fetched again, and returns the posts whose score, title or body changed.
+// Reddit blanks the body of a removed or deleted post to "[removed]" or
+// "[deleted]"; a removed link post can't be told apart.
+func updatePosts(recent []Post, submissions []*reddit.Post) []Post {
+	index := make(map[string]int, len(recent))
+	for i, post := range recent {
+		index[post.ID] = i
+	}
+
+	var changed []Post
+	for _, submission := range submissions {
+		i, ok := index[submission.ID]
+		if !ok {
+			continue
+		}
+		post := &recent[i]
+		score := int32(submission.Score)
+		if post.Score == score && post.Title == submission.Title && post.Body == submission.Body {
+			continue
+		}
+		post.Score, post.Title, post.Body = score, submission.Title, submission.Body
+		changed = append(changed, *post)
+	}
+	return changed
+}
+
+// withoutRemoved drops the posts removed on Reddit, which won't change again
+func withoutRemoved(recent []Post) []Post {
+	kept := recent[:0]
+	for _, post := range recent {
+		if post.Body != "[removed]" && post.Body != "[deleted]" {
+			kept = append(kept, post)
+		}
+	}
+	return kept
+}
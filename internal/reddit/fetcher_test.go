@@ -0,0 +1,38 @@
+package reddit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+func TestUpdatePosts(t *testing.T) {
+	recent := []Post{
+		{ID: "a", Title: "Ceasefire agreed", Body: "Talks ended overnight", Score: 10},
+		{ID: "b", Title: "Election results", Score: 5},
+		{ID: "c", Title: "Storm warning", Body: "Stay inside", Score: 1},
+	}
+	changed := updatePosts(recent, []*reddit.Post{
+		{ID: "a", Title: "Ceasefire agreed", Body: "Talks ended overnight", Score: 25},
+		{ID: "b", Title: "Election results", Score: 5},
+		{ID: "c", Title: "Storm warning", Body: "[removed]", Score: 1},
+		{ID: "d", Title: "Not sent before", Score: 3},
+	})
+
+	want := []Post{
+		{ID: "a", Title: "Ceasefire agreed", Body: "Talks ended overnight", Score: 25},
+		{ID: "c", Title: "Storm warning", Body: "[removed]", Score: 1},
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("Expected the new score and the removal, got %+v", changed)
+	}
+	if recent[0].Score != 25 {
+		t.Errorf("Expected the recent post updated, got %+v", recent[0])
+	}
+
+	// A removed post isn't fetched again
+	if kept := withoutRemoved(recent); len(kept) != 2 || kept[1].ID != "b" {
+		t.Errorf("Expected the removed post dropped, got %+v", kept)
+	}
+}
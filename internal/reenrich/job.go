@@ -0,0 +1,341 @@
+package reenrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/analysis"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultBatchSize = 200
+
+// PostStore reads stored posts page by page and saves them back, updating
+// their rollups
+type PostStore interface {
+	QueryPosts(ctx context.Context, filter storage.PostFilter) (storage.PostPage, error)
+	SavePost(ctx context.Context, post reddit.Post) error
+}
+
+// Options tune a Job
+type Options struct {
+	// DryRun compares the new analyses with the stored ones without saving them
+	DryRun bool
+	// All enriches posts already enriched by the current analyzer versions too
+	All bool
+	// BatchSize is the number of posts read per page, and between checkpoints
+	BatchSize int
+	// Checkpoint is the path of the file progress is kept in, so an
+	// interrupted run resumes where it stopped. Empty disables checkpoints.
+	Checkpoint string
+	// Filter narrows the posts, e.g. to some subreddits or a time range. Its
+	// sort, limit and cursor are set by the job.
+	Filter storage.PostFilter
+}
+
+// Stats counts what a run found and did
+type Stats struct {
+	Scanned int `json:"scanned"`
+	// Current posts were enriched by the current analyzer versions, and skipped
+	Current int `json:"current"`
+	// Changed posts got different analyses
+	Changed int `json:"changed"`
+	// Saved posts were written back, or would be in a dry run, with their new
+	// analyses or just the new versions
+	Saved int `json:"saved"`
+
+	// Labels counts sentiment label changes, like "neutral->negative"
+	Labels    map[string]int `json:"labels,omitempty"`
+	Sentiment int            `json:"sentiment"`
+	Topics    int            `json:"topics"`
+	Entities  int            `json:"entities"`
+	Summaries int            `json:"summaries"`
+	Languages int            `json:"languages"`
+	Domains   int            `json:"domains"`
+	Emotions  int            `json:"emotions"`
+	// Toxic counts posts moved across reddit.ToxicThreshold
+	Toxic int `json:"toxic"`
+}
+
+// LabelChanges lists the sentiment label changes, most frequent first
+func (s Stats) LabelChanges() []string {
+	changes := make([]string, 0, len(s.Labels))
+	for change := range s.Labels {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if s.Labels[changes[i]] != s.Labels[changes[j]] {
+			return s.Labels[changes[i]] > s.Labels[changes[j]]
+		}
+		return changes[i] < changes[j]
+	})
+	for i, change := range changes {
+		changes[i] = fmt.Sprintf("%s: %d", change, s.Labels[change])
+	}
+	return changes
+}
+
+// checkpoint is the progress of a run, saved after every page
+type checkpoint struct {
+	Versions  string             `json:"versions"`
+	DryRun    bool               `json:"dry_run"`
+	All       bool               `json:"all"`
+	Filter    storage.PostFilter `json:"filter"`
+	Cursor    string             `json:"cursor"`
+	Stats     Stats              `json:"stats"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// Job runs the configured analyzers over stored posts again, after a model,
+// stopword list or other analyzer setting changes, and saves the posts whose
+// analyses changed. Saving updates their rollups in place, moving each post
+// from the buckets of its old analyses to those of its new ones. Every saved
+// post records the analyzer versions it was enriched by, and posts already
+// enriched by the current versions are skipped, so a job can be rerun safely.
+// Stories are kept as they are, since they depend on the order posts arrived.
+type Job struct {
+	store     PostStore
+	enricher  *analysis.Enricher
+	threshold float64
+	opts      Options
+}
+
+// NewJob creates a job enriching posts with the analyzers of the config
+func NewJob(cfg *config.Config, store PostStore, opts Options) (*Job, error) {
+	enricher, err := analysis.NewEnricher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	return &Job{store: store, enricher: enricher, threshold: analysis.NeutralThreshold(cfg), opts: opts}, nil
+}
+
+// Versions are the analyzer versions the job enriches with
+func (j *Job) Versions() string {
+	return j.enricher.Versions()
+}
+
+// Run enriches the posts oldest first, resuming from the checkpoint if there
+// is one for the same analyzer versions, mode and posts. The checkpoint is removed
+// once every post has been seen. When ctx is cancelled, Run returns the stats
+// so far with the context's error, and the next run picks up from the last
+// checkpoint.
+func (j *Job) Run(ctx context.Context) (Stats, error) {
+	progress, err := j.loadCheckpoint()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	filter := j.opts.Filter
+	filter.SortBy = storage.SortByCreatedAt
+	filter.Ascending = true
+	filter.Limit = j.opts.BatchSize
+	filter.Cursor = progress.Cursor
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress.Stats, err
+		}
+		page, err := j.store.QueryPosts(ctx, filter)
+		if err != nil {
+			return progress.Stats, err
+		}
+		for _, post := range page.Posts {
+			if err := j.enrich(ctx, post, &progress.Stats); err != nil {
+				return progress.Stats, err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return progress.Stats, j.removeCheckpoint()
+		}
+		filter.Cursor = page.NextCursor
+		progress.Cursor = page.NextCursor
+		if err := j.saveCheckpoint(progress); err != nil {
+			return progress.Stats, err
+		}
+	}
+}
+
+// enrich runs the analyzers over one post, counts what changed and saves it
+// unless this is a dry run
+func (j *Job) enrich(ctx context.Context, post reddit.Post, stats *Stats) error {
+	stats.Scanned++
+	if !j.opts.All && post.AnalyzerVersions == j.enricher.Versions() {
+		stats.Current++
+		return nil
+	}
+
+	enriched := post
+	j.enricher.Enrich(&enriched)
+	changed := j.diff(post, enriched, stats)
+	if changed {
+		stats.Changed++
+	}
+	if !changed && post.AnalyzerVersions == enriched.AnalyzerVersions {
+		return nil
+	}
+	if !j.opts.DryRun {
+		if err := j.store.SavePost(ctx, enriched); err != nil {
+			return fmt.Errorf("failed to save post %s: %w", post.ID, err)
+		}
+	}
+	stats.Saved++
+	return nil
+}
+
+// diff counts the analyses that differ between the stored and enriched post,
+// and reports whether any did
+func (j *Job) diff(stored, enriched reddit.Post, stats *Stats) bool {
+	changed := false
+	count := func(differs bool, counter *int) {
+		if differs {
+			*counter++
+			changed = true
+		}
+	}
+
+	count(stored.Sentiment != enriched.Sentiment || stored.Unsupported != enriched.Unsupported, &stats.Sentiment)
+	storedLabel, enrichedLabel := analysis.LabelOf(stored.Sentiment, j.threshold), analysis.LabelOf(enriched.Sentiment, j.threshold)
+	if storedLabel != enrichedLabel {
+		if stats.Labels == nil {
+			stats.Labels = make(map[string]int)
+		}
+		stats.Labels[string(storedLabel)+"->"+string(enrichedLabel)]++
+	}
+	count(!sameStrings(stored.Topics, enriched.Topics), &stats.Topics)
+	count(!sameEntities(stored.Entities, enriched.Entities), &stats.Entities)
+	count(stored.Summary != enriched.Summary, &stats.Summaries)
+	count(stored.Language != enriched.Language, &stats.Languages)
+	// The store keeps a domain it has when the new one is empty
+	count(enriched.Domain != "" && stored.Domain != enriched.Domain, &stats.Domains)
+	count(stored.Emotions != enriched.Emotions, &stats.Emotions)
+	count((stored.Toxicity >= reddit.ToxicThreshold) != (enriched.Toxicity >= reddit.ToxicThreshold), &stats.Toxic)
+	if stored.Toxicity != enriched.Toxicity || stored.Unscored != enriched.Unscored {
+		changed = true
+	}
+	return changed
+}
+
+// sameStrings compares topics case-insensitively, as the rollups do
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameEntities compares entities regardless of order, since the store
+// doesn't keep it
+func sameEntities(a, b []reddit.Entity) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[reddit.Entity]int, len(a))
+	for _, entity := range a {
+		counts[entity]++
+	}
+	for _, entity := range b {
+		if counts[entity] == 0 {
+			return false
+		}
+		counts[entity]--
+	}
+	return true
+}
+
+// loadCheckpoint reads the progress of an interrupted run. A checkpoint left
+// by other analyzer versions, the other mode, or a run over other posts is
+// ignored, since the posts before it weren't enriched the way this run would.
+func (j *Job) loadCheckpoint() (checkpoint, error) {
+	fresh := checkpoint{Versions: j.enricher.Versions(), DryRun: j.opts.DryRun, All: j.opts.All, Filter: j.opts.Filter}
+	if j.opts.Checkpoint == "" {
+		return fresh, nil
+	}
+	data, err := os.ReadFile(j.opts.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return fresh, nil
+	}
+	if err != nil {
+		return fresh, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fresh, fmt.Errorf("failed to parse checkpoint %s: %w", j.opts.Checkpoint, err)
+	}
+	if saved.Versions != fresh.Versions || saved.DryRun != fresh.DryRun || saved.All != fresh.All ||
+		!sameFilter(saved.Filter, fresh.Filter) {
+		log.Printf("Ignoring checkpoint %s from a run with other analyzers, mode or posts", j.opts.Checkpoint)
+		return fresh, nil
+	}
+	log.Printf("Resuming from checkpoint %s after %d posts", j.opts.Checkpoint, saved.Stats.Scanned)
+	return saved, nil
+}
+
+// sameFilter compares filters by their JSON, which is how checkpoints keep
+// them, so that times read back from a file match the ones they were saved from
+func sameFilter(a, b storage.PostFilter) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// saveCheckpoint replaces the checkpoint file, writing it under a temporary
+// name first so an interruption never leaves half a checkpoint
+func (j *Job) saveCheckpoint(progress checkpoint) error {
+	if j.opts.Checkpoint == "" {
+		return nil
+	}
+	progress.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	dir, name := filepath.Split(j.opts.Checkpoint)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.opts.Checkpoint); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (j *Job) removeCheckpoint() error {
+	if j.opts.Checkpoint == "" {
+		return nil
+	}
+	if err := os.Remove(j.opts.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
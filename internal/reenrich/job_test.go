@@ -0,0 +1,246 @@
+package reenrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goreddit/internal/analysis"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"goreddit/internal/storage"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// newTestStore returns a SQLite store holding posts saved before their
+// analyzers changed: wrong sentiment, no topics and no analyzer versions
+func newTestStore(t *testing.T) (*config.Config, storage.Store) {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = storage.DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for i, post := range []reddit.Post{
+		{ID: "a", Title: "Rescue teams save trapped families after the flood", Sentiment: -0.9},
+		{ID: "b", Title: "Earthquake kills hundreds in Turkey", Sentiment: 0.9},
+		{ID: "c", Title: "Parliament debates the budget", Sentiment: 0.5},
+	} {
+		post.Subreddit = "worldnews"
+		post.CreatedAt = float64(base.Add(time.Duration(i) * time.Minute).Unix())
+		if err := store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post: %v", err)
+		}
+	}
+	return cfg, store
+}
+
+func TestJob(t *testing.T) {
+	cfg, store := newTestStore(t)
+	ctx := context.Background()
+
+	dryRun, err := NewJob(cfg, store, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	stats, err := dryRun.Run(ctx)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if stats.Scanned != 3 || stats.Changed != 3 || stats.Saved != 3 || stats.Topics != 3 || stats.Languages != 3 {
+		t.Errorf("Unexpected dry run stats: %+v", stats)
+	}
+	if len(stats.Labels) == 0 {
+		t.Errorf("Expected sentiment label changes, got %+v", stats)
+	}
+	if post, _ := store.GetPost(ctx, "a"); post.Sentiment != -0.9 || len(post.Topics) != 0 || post.AnalyzerVersions != "" {
+		t.Errorf("Expected a dry run to leave posts alone, got %+v", post)
+	}
+
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	job, err := NewJob(cfg, store, Options{BatchSize: 2, Checkpoint: checkpoint})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if stats, err = job.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if stats.Scanned != 3 || stats.Saved != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if _, err := os.Stat(checkpoint); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the checkpoint removed after a complete run, got %v", err)
+	}
+
+	sum := 0.0
+	for _, id := range []string{"a", "b", "c"} {
+		post, err := store.GetPost(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get post: %v", err)
+		}
+		if post.AnalyzerVersions != job.Versions() || post.SentimentModel != "bayes:imdb" || post.Language != "en" || len(post.Topics) == 0 {
+			t.Errorf("Expected post %s enriched, got %+v", id, post)
+		}
+		sum += post.Sentiment
+	}
+	if a, b := mustGet(t, store, "a"), mustGet(t, store, "b"); a.Sentiment <= b.Sentiment {
+		t.Errorf("Expected the rescue above the earthquake, got %.2f and %.2f", a.Sentiment, b.Sentiment)
+	}
+
+	// The rollups follow the posts to their new sentiment without counting them twice
+	series, err := store.TimeSeries(ctx, storage.SeriesQuery{
+		Resolution: storage.ResolutionHour,
+		Subreddit:  "worldnews",
+		Since:      base,
+		Until:      base.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(series) != 1 || series[0].Posts != 3 || math.Abs(series[0].AvgSentiment-sum/3) > 1e-9 {
+		t.Errorf("Expected 3 posts averaging %.3f, got %+v", sum/3, series)
+	}
+
+	// Posts enriched by the current versions are skipped
+	if stats, err = job.Run(ctx); err != nil || stats.Current != 3 || stats.Saved != 0 {
+		t.Errorf("Expected a second run to skip every post, got %+v (%v)", stats, err)
+	}
+}
+
+// cancellingStore cancels the run once it has saved a number of posts
+type cancellingStore struct {
+	storage.Store
+	saves  *int
+	cancel context.CancelFunc
+}
+
+func (s cancellingStore) SavePost(ctx context.Context, post reddit.Post) error {
+	err := s.Store.SavePost(ctx, post)
+	if *s.saves--; *s.saves == 0 {
+		s.cancel()
+	}
+	return err
+}
+
+func TestJobResumes(t *testing.T) {
+	cfg, store := newTestStore(t)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	opts := Options{BatchSize: 2, Checkpoint: checkpoint}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	saves := 2
+	job, err := NewJob(cfg, cancellingStore{store, &saves, cancel}, opts)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	stats, err := job.Run(ctx)
+	if !errors.Is(err, context.Canceled) || stats.Scanned != 2 {
+		t.Fatalf("Expected the run cancelled after the first page, got %+v (%v)", stats, err)
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("Expected a checkpoint: %v", err)
+	}
+	if post := mustGet(t, store, "c"); post.AnalyzerVersions != "" {
+		t.Errorf("Expected the last post untouched, got %+v", post)
+	}
+
+	// The next run starts after the checkpoint, carrying its stats over
+	job, err = NewJob(cfg, store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	stats, err = job.Run(context.Background())
+	if err != nil || stats.Scanned != 3 || stats.Saved != 3 || stats.Current != 0 {
+		t.Errorf("Expected the run resumed at the last post, got %+v (%v)", stats, err)
+	}
+	if post := mustGet(t, store, "c"); post.AnalyzerVersions != job.Versions() {
+		t.Errorf("Expected the last post enriched, got %+v", post)
+	}
+
+	// A checkpoint from other analyzers is ignored
+	cfg.Sentiment.Analyzer = analysis.LexiconName
+	lexicon, err := NewJob(cfg, store, Options{DryRun: true, Checkpoint: checkpoint})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if err := os.WriteFile(checkpoint, []byte(`{"versions":"old","dry_run":true,"cursor":"bogus"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := lexicon.Run(context.Background()); err != nil || stats.Scanned != 3 {
+		t.Errorf("Expected a stale checkpoint ignored, got %+v (%v)", stats, err)
+	}
+}
+
+func TestJobIgnoresCheckpointOfOtherPosts(t *testing.T) {
+	cfg, store := newTestStore(t)
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	// A time in another zone still matches once read back from the file
+	filter := storage.PostFilter{Subreddits: []string{"worldnews"}, Since: base.In(time.FixedZone("CEST", 2*60*60))}
+	opts := Options{BatchSize: 2, Checkpoint: path, Filter: filter}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	saves := 2
+	job, err := NewJob(cfg, cancellingStore{store, &saves, cancel}, opts)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if _, err := job.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run cancelled, got %v", err)
+	}
+	job, err = NewJob(cfg, store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if stats, err := job.Run(context.Background()); err != nil || stats.Scanned != 3 || stats.Saved != 3 {
+		t.Errorf("Expected the run resumed with the same filter, got %+v (%v)", stats, err)
+	}
+
+	// A checkpoint whose cursor doesn't apply to the run fails it unless ignored
+	for _, tt := range []struct {
+		name    string
+		opts    Options
+		scanned int
+	}{
+		{"all", Options{All: true, Filter: filter}, 3},
+		{"other filter", Options{Filter: storage.PostFilter{Since: base.Add(time.Minute)}}, 2},
+		{"no filter", Options{}, 3},
+	} {
+		data, err := json.Marshal(checkpoint{Versions: job.Versions(), DryRun: true, Filter: filter, Cursor: "bogus"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		tt.opts.DryRun = true
+		tt.opts.Checkpoint = path
+		other, err := NewJob(cfg, store, tt.opts)
+		if err != nil {
+			t.Fatalf("Failed to create job: %v", err)
+		}
+		if stats, err := other.Run(context.Background()); err != nil || stats.Scanned != tt.scanned {
+			t.Errorf("%s: expected the checkpoint ignored, got %+v (%v)", tt.name, stats, err)
+		}
+	}
+}
+
+func mustGet(t *testing.T, store storage.Store, id string) reddit.Post {
+	t.Helper()
+	post, err := store.GetPost(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get post %s: %v", id, err)
+	}
+	return post
+}
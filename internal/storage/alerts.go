@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// alertEventsKeepFor is how long fired alerts are remembered, and so the
+	// longest dedup window a rule can have
+	alertEventsKeepFor = 7 * day
+	// alertRateWindow is the window of a rule's MaxPerHour cap
+	alertRateWindow = time.Hour
+)
+
+// AlertRule is an alert rule created through the API. Its definition is the
+// JSON the alerts package reads; storage doesn't look inside.
+type AlertRule struct {
+	ID         string
+	Definition []byte
+	UpdatedAt  time.Time
+}
+
+// AlertClaim asks to fire a rule for a key, like the ID of the story a post
+// is about
+type AlertClaim struct {
+	RuleID string
+	Key    string
+	// Dedup is how long after firing for a key the rule stays quiet for it
+	Dedup time.Duration
+	// MaxPerHour caps how often the rule fires across all keys; 0 is no cap
+	MaxPerHour int
+	At         time.Time
+}
+
+// ListAlertRules returns the stored alert rules by ID
+func (s *sqlStore) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, definition, %s FROM alert_rules ORDER BY id", s.dialect.toUnix("updated_at")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		var (
+			rule       AlertRule
+			definition string
+			updatedAt  float64
+		)
+		if err := rows.Scan(&rule.ID, &definition, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rule.Definition = []byte(definition)
+		rule.UpdatedAt = time.Unix(int64(updatedAt), 0).UTC()
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveAlertRule creates or replaces an alert rule
+func (s *sqlStore) SaveAlertRule(ctx context.Context, rule AlertRule) error {
+	if rule.UpdatedAt.IsZero() {
+		rule.UpdatedAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO alert_rules (id, definition, updated_at) VALUES ($1, $2, %s)
+		ON CONFLICT (id) DO UPDATE SET
+			definition = EXCLUDED.definition,
+			updated_at = EXCLUDED.updated_at`, s.dialect.fromUnix("$3")),
+		rule.ID, string(rule.Definition), float64(rule.UpdatedAt.Unix()))
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteAlertRule removes an alert rule and what it fired, or returns
+// ErrNotFound
+func (s *sqlStore) DeleteAlertRule(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin delete transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM alert_rules WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	} else if deleted == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM alert_events WHERE rule_id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete alert events: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM alert_firings WHERE rule_id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete alert firings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit alert rule deletion: %w", err)
+	}
+	return nil
+}
+
+// ClaimAlert records that a rule fires for a key and reports whether it
+// should, which it shouldn't if it already fired for the key within the
+// dedup window or has reached its hourly cap. Claims are settled in the
+// database, so consumers sharing it fire once between them: claims of one
+// rule take a lock on it, or on SQLite the write lock, before counting what
+// it fired.
+func (s *sqlStore) ClaimAlert(ctx context.Context, claim AlertClaim) (bool, error) {
+	if claim.Dedup > alertEventsKeepFor {
+		claim.Dedup = alertEventsKeepFor
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin alert claim: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.dialect.lockAlertRule != "" {
+		if _, err := tx.ExecContext(ctx, s.dialect.lockAlertRule, claim.RuleID); err != nil {
+			return false, fmt.Errorf("failed to lock alert rule: %w", err)
+		}
+	}
+
+	if claim.MaxPerHour > 0 {
+		var fired int
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT COUNT(*) FROM alert_firings WHERE rule_id = $1 AND fired_at > %s", s.dialect.fromUnix("$2")),
+			claim.RuleID, float64(claim.At.Add(-alertRateWindow).Unix())).Scan(&fired)
+		if err != nil {
+			return false, fmt.Errorf("failed to count recent alerts: %w", err)
+		}
+		if fired >= claim.MaxPerHour {
+			return false, nil
+		}
+	}
+
+	// A repeat only takes the row over once the previous one is out of the window
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO alert_events (rule_id, dedup_key, fired_at) VALUES ($1, $2, %s)
+		ON CONFLICT (rule_id, dedup_key) DO UPDATE SET fired_at = EXCLUDED.fired_at
+		WHERE alert_events.fired_at <= %s`, s.dialect.fromUnix("$3"), s.dialect.fromUnix("$4")),
+		claim.RuleID, claim.Key, float64(claim.At.Unix()), float64(claim.At.Add(-claim.Dedup).Unix()))
+	if err != nil {
+		return false, fmt.Errorf("failed to record alert: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record alert: %w", err)
+	}
+	if claimed == 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO alert_firings (rule_id, fired_at) VALUES ($1, "+s.dialect.fromUnix("$2")+")",
+		claim.RuleID, float64(claim.At.Unix())); err != nil {
+		return false, fmt.Errorf("failed to record alert firing: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit alert claim: %w", err)
+	}
+	return true, nil
+}
+
+// deleteExpiredAlerts forgets alerts fired longer ago than any dedup window,
+// and firings older than the hourly cap's window
+func (s *sqlStore) deleteExpiredAlerts(ctx context.Context, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM alert_events WHERE fired_at < "+s.dialect.fromUnix("$1"),
+		float64(now.Add(-alertEventsKeepFor).Unix()))
+	if err != nil {
+		return fmt.Errorf("failed to delete expired alerts: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, "DELETE FROM alert_firings WHERE fired_at < "+s.dialect.fromUnix("$1"),
+		float64(now.Add(-alertRateWindow).Unix()))
+	if err != nil {
+		return fmt.Errorf("failed to delete expired alert firings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreAlertRules(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	for _, rule := range []AlertRule{
+		{ID: "b", Definition: []byte(`{"id":"b"}`)},
+		{ID: "a", Definition: []byte(`{"id":"a"}`)},
+		{ID: "b", Definition: []byte(`{"id":"b","name":"B"}`)},
+	} {
+		if err := store.SaveAlertRule(ctx, rule); err != nil {
+			t.Fatalf("SaveAlertRule failed: %v", err)
+		}
+	}
+	rules, err := store.ListAlertRules(ctx)
+	if err != nil {
+		t.Fatalf("ListAlertRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].ID != "a" || string(rules[1].Definition) != `{"id":"b","name":"B"}` || rules[1].UpdatedAt.IsZero() {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+
+	if err := store.DeleteAlertRule(ctx, "a"); err != nil {
+		t.Fatalf("DeleteAlertRule failed: %v", err)
+	}
+	if err := store.DeleteAlertRule(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteStoreClaimAlert(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	claim := func(rule, key string, at time.Time, maxPerHour int) bool {
+		t.Helper()
+		claimed, err := store.ClaimAlert(ctx, AlertClaim{RuleID: rule, Key: key, Dedup: time.Hour, MaxPerHour: maxPerHour, At: at})
+		if err != nil {
+			t.Fatalf("ClaimAlert failed: %v", err)
+		}
+		return claimed
+	}
+
+	if !claim("quake", "s1", now, 0) {
+		t.Error("Expected the first alert for a story to be claimed")
+	}
+	if claim("quake", "s1", now.Add(30*time.Minute), 0) {
+		t.Error("Expected a repeat within the dedup window to be held back")
+	}
+	if !claim("other", "s1", now.Add(30*time.Minute), 0) {
+		t.Error("Expected another rule to alert on the same story")
+	}
+	if !claim("quake", "s1", now.Add(time.Hour), 0) {
+		t.Error("Expected a repeat after the dedup window to be claimed")
+	}
+
+	// Of quake's alerts, only the last one is within the hour before this
+	if !claim("quake", "s2", now.Add(time.Hour+time.Minute), 2) {
+		t.Error("Expected an alert under the hourly cap to be claimed")
+	}
+	if claim("quake", "s3", now.Add(time.Hour+2*time.Minute), 2) {
+		t.Error("Expected an alert over the hourly cap to be held back")
+	}
+
+	// Firing again for a story counts toward the cap like any other alert
+	later := now.Add(3 * time.Hour)
+	claimed, err := store.ClaimAlert(ctx, AlertClaim{RuleID: "flappy", Key: "s1", Dedup: 10 * time.Minute, MaxPerHour: 2, At: later})
+	if err != nil || !claimed {
+		t.Fatalf("Expected the first alert to be claimed, got %v (%v)", claimed, err)
+	}
+	claimed, err = store.ClaimAlert(ctx, AlertClaim{RuleID: "flappy", Key: "s1", Dedup: 10 * time.Minute, MaxPerHour: 2, At: later.Add(15 * time.Minute)})
+	if err != nil || !claimed {
+		t.Fatalf("Expected a repeat after the dedup window to be claimed, got %v (%v)", claimed, err)
+	}
+	if claim("flappy", "s2", later.Add(30*time.Minute), 2) {
+		t.Error("Expected a repeated alert to count toward the hourly cap")
+	}
+
+	// Fired alerts are forgotten after a week
+	if _, err := store.Maintain(ctx, RetentionPolicy{}, now.Add(8*day)); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	for _, table := range []string{"alert_events", "alert_firings"} {
+		var left int
+		if err := store.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&left); err != nil || left != 0 {
+			t.Errorf("Expected expired rows of %s to be deleted, %d left (%v)", table, left, err)
+		}
+	}
+}
+
+func TestSQLiteStoreClaimAlertConcurrently(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Consumers claiming different stories at once stay within the cap
+	var wg sync.WaitGroup
+	var claimed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := store.ClaimAlert(ctx, AlertClaim{RuleID: "quake", Key: fmt.Sprintf("s%d", i), Dedup: time.Hour, MaxPerHour: 3, At: now})
+			if err != nil {
+				t.Errorf("ClaimAlert failed: %v", err)
+			}
+			if ok {
+				claimed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := claimed.Load(); n != 3 {
+		t.Errorf("Expected 3 alerts claimed under the cap, got %d", n)
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// emotionColumns are the reddit_posts columns of a post's emotions, in the
+// order of reddit.EmotionNames
+const emotionColumns = "emotion_anger, emotion_anticipation, emotion_disgust, emotion_fear, " +
+	"emotion_joy, emotion_sadness, emotion_surprise, emotion_trust"
+
+// dialect captures the SQL differences between the supported databases, so
+// the shared queries in sqlStore can run against either of them
+type dialect struct {
+	name string
+	// migrations is the embedded migrations directory for this database
+	migrations string
+	// postColumns is the select list scanned by scanPost
+	postColumns string
+	// postKey is the conflict target for upserting a post
+	postKey string
+	// fromUnix converts a bound unix-seconds value to the created_at column type
+	fromUnix func(placeholder string) string
+	// toUnix converts a timestamp column to float unix seconds
+	toUnix func(column string) string
+	// like is the case-insensitive LIKE operator
+	like string
+	// hasTopic returns a condition matching rows whose topics include the bound value
+	hasTopic func(placeholder string) string
+	// topicsArg converts topics to a bindable value
+	topicsArg func(topics []string) any
+	// topicsDest wraps a scan destination for the topics column
+	topicsDest func(topics *[]string) any
+	// lock serializes migrations across processes and returns the matching unlock
+	lock func(ctx context.Context, conn *sql.Conn) (func(), error)
+	// lockPost, if set, is run inside SavePost's transaction with the post ID
+	// bound to $1 so that concurrent saves of one post apply rollups in turn
+	lockPost string
+	// lockAlertRule, if set, is run inside ClaimAlert's transaction with the
+	// rule ID bound to $1 so that concurrent claims check its cap in turn
+	lockAlertRule string
+}
+
+var postgresDialect = dialect{
+	name:        DriverPostgres,
+	migrations:  "migrations/postgres",
+	postColumns: "id, title, body, summary, subreddit, score, url, domain, EXTRACT(EPOCH FROM created_at)::float8, sentiment, topics, story_id, language, unsupported, unscored, toxicity, sentiment_model, analyzer_versions, " + emotionColumns,
+	// created_at is part of the key because reddit_posts is partitioned on it
+	postKey: "id, created_at",
+	fromUnix: func(placeholder string) string {
+		return "to_timestamp(" + placeholder + ")"
+	},
+	toUnix: func(column string) string {
+		return "EXTRACT(EPOCH FROM " + column + ")::float8"
+	},
+	like: "ILIKE",
+	hasTopic: func(placeholder string) string {
+		return placeholder + " = ANY(topics)"
+	},
+	topicsArg: func(topics []string) any {
+		if topics == nil {
+			topics = []string{}
+		}
+		return pq.Array(topics)
+	},
+	topicsDest: func(topics *[]string) any {
+		return pq.Array(topics)
+	},
+	lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+		}, nil
+	},
+	lockPost: `SELECT pg_advisory_xact_lock(hashtext($1))`,
+	// Keyed apart from the post locks, whose IDs could hash alike
+	lockAlertRule: `SELECT pg_advisory_xact_lock(hashtext('alert_rules'), hashtext($1))`,
+}
+
+// sqliteDialect stores topics as a JSON array. SQLite has no advisory locks;
+// migrations, saves and alert claims rely on the IMMEDIATE transactions opened
+// by OpenSQLite instead.
+var sqliteDialect = dialect{
+	name:        DriverSQLite,
+	migrations:  "migrations/sqlite",
+	postColumns: "id, title, body, summary, subreddit, score, url, domain, created_at, sentiment, topics, story_id, language, unsupported, unscored, toxicity, sentiment_model, analyzer_versions, " + emotionColumns,
+	postKey:     "id",
+	fromUnix: func(placeholder string) string {
+		return placeholder
+	},
+	toUnix: func(column string) string {
+		return column
+	},
+	like: "LIKE",
+	hasTopic: func(placeholder string) string {
+		return "EXISTS (SELECT 1 FROM json_each(topics) WHERE value = " + placeholder + ")"
+	},
+	topicsArg: func(topics []string) any {
+		return jsonStrings{&topics}
+	},
+	topicsDest: func(topics *[]string) any {
+		return jsonStrings{topics}
+	},
+	lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+		return func() {}, nil
+	},
+}
+
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "", DriverPostgres:
+		return postgresDialect, nil
+	case DriverSQLite:
+		return sqliteDialect, nil
+	default:
+		return dialect{}, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+// jsonStrings reads and writes a string slice as a JSON array column
+type jsonStrings struct {
+	s *[]string
+}
+
+func (j jsonStrings) Value() (driver.Value, error) {
+	if *j.s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(*j.s)
+	return string(data), err
+}
+
+func (j jsonStrings) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*j.s = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into string list", src)
+	}
+	return json.Unmarshal(data, j.s)
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+	"time"
+)
+
+// TopDomainsQuery selects the most linked domains over a window
+type TopDomainsQuery struct {
+	Subreddit string
+	Since     time.Time // inclusive, required
+	Until     time.Time // exclusive, defaults to now
+	Limit     int       // defaults to 50, capped at 500
+}
+
+// DomainStats sums up the posts linking to one domain
+type DomainStats struct {
+	Domain       string  `json:"domain"`
+	Posts        int     `json:"posts"`
+	Share        float64 `json:"share"` // of the window's posts with a domain
+	AvgScore     float64 `json:"avg_score"`
+	AvgSentiment float64 `json:"avg_sentiment"` // of the posts whose sentiment was analyzed
+}
+
+// applyDomainRollups adds (sign 1) or removes (sign -1) a post's
+// contribution to the domain rollups. Posts without a domain count nowhere.
+func (s *sqlStore) applyDomainRollups(ctx context.Context, tx *sql.Tx, post reddit.Post, sign int) error {
+	if post.Domain == "" {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO domain_rollups (resolution, bucket, subreddit, domain, post_count, analyzed_count, score_sum, sentiment_sum)
+		VALUES ($1, %s, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (resolution, domain, subreddit, bucket) DO UPDATE SET
+			post_count = domain_rollups.post_count + EXCLUDED.post_count,
+			analyzed_count = domain_rollups.analyzed_count + EXCLUDED.analyzed_count,
+			score_sum = domain_rollups.score_sum + EXCLUDED.score_sum,
+			sentiment_sum = domain_rollups.sentiment_sum + EXCLUDED.sentiment_sum
+	`, s.dialect.fromUnix("$2"))
+
+	post, analyzed, _ := analyzedCounts(post, sign)
+	subreddit := strings.ToLower(post.Subreddit)
+	domain := strings.ToLower(post.Domain)
+	score := int64(sign) * int64(post.Score)
+	sentiment := float64(sign) * post.Sentiment
+	for _, key := range rollupBuckets(post.CreatedAt) {
+		bucket := float64(key.bucket.Unix())
+		if _, err := tx.ExecContext(ctx, query, key.resolution, bucket, subreddit, domain, sign, analyzed, score, sentiment); err != nil {
+			return fmt.Errorf("failed to update domain rollup: %w", err)
+		}
+	}
+	return nil
+}
+
+// TopDomains returns the most linked domains in the window, most linked
+// first, with their share of the posts and average score and sentiment
+func (s *sqlStore) TopDomains(ctx context.Context, q TopDomainsQuery) ([]DomainStats, error) {
+	b := &queryBuilder{}
+	limit, err := s.rollupWindow(b, q.Subreddit, q.Since, q.Until, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT domain, SUM(post_count) AS total, SUM(analyzed_count), SUM(score_sum), SUM(sentiment_sum),
+			SUM(SUM(post_count)) OVER ()
+		FROM domain_rollups%s
+		GROUP BY domain
+		HAVING SUM(post_count) > 0
+		ORDER BY total DESC, domain
+		LIMIT %s`, b.whereClause(), b.arg(limit))
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []DomainStats{}
+	for rows.Next() {
+		var (
+			ds                DomainStats
+			analyzed          int
+			scoreSum, sentSum float64
+			allPosts          float64
+		)
+		if err := rows.Scan(&ds.Domain, &ds.Posts, &analyzed, &scoreSum, &sentSum, &allPosts); err != nil {
+			return nil, fmt.Errorf("failed to scan top domains: %w", err)
+		}
+		ds.AvgScore = scoreSum / float64(ds.Posts)
+		ds.AvgSentiment = average(sentSum, analyzed)
+		if allPosts > 0 {
+			ds.Share = float64(ds.Posts) / allPosts
+		}
+		domains = append(domains, ds)
+	}
+	return domains, rows.Err()
+}
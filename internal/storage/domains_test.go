@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"goreddit/internal/reddit"
+)
+
+func TestSQLiteStoreTopDomains(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) float64 { return float64(base.Add(d).Unix()) }
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "WorldNews", Domain: "apnews.com", Score: 100, Sentiment: -1, CreatedAt: at(time.Hour)},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", Domain: "apnews.com", Score: 50, Sentiment: 0, CreatedAt: at(2 * time.Hour)},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "worldnews", Domain: "bbc.co.uk", Score: 30, Sentiment: 0.5, CreatedAt: at(3 * time.Hour)},
+		reddit.Post{ID: "d", Title: "D", Subreddit: "politics", Domain: "bbc.co.uk", Score: 10, CreatedAt: at(4 * time.Hour)},
+		reddit.Post{ID: "e", Title: "E", Subreddit: "worldnews", CreatedAt: at(5 * time.Hour)},
+	)
+
+	domains, err := store.TopDomains(ctx, TopDomainsQuery{Subreddit: "worldnews", Since: base, Until: base.Add(day)})
+	if err != nil {
+		t.Fatalf("TopDomains failed: %v", err)
+	}
+	want := []DomainStats{
+		{Domain: "apnews.com", Posts: 2, Share: 2.0 / 3, AvgScore: 75, AvgSentiment: -0.5},
+		{Domain: "bbc.co.uk", Posts: 1, Share: 1.0 / 3, AvgScore: 30, AvgSentiment: 0.5},
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("Expected %+v, got %+v", want, domains)
+	}
+
+	// A new score moves the average, and a re-saved post without a domain
+	// keeps the one it has
+	seedPosts(t, store, reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", Score: 150, CreatedAt: at(2 * time.Hour)})
+	if post, _ := store.GetPost(ctx, "b"); post.Domain != "apnews.com" {
+		t.Errorf("Expected post b to keep its domain, got %q", post.Domain)
+	}
+	domains, err = store.TopDomains(ctx, TopDomainsQuery{Since: base, Until: base.Add(day), Limit: 1})
+	if err != nil {
+		t.Fatalf("TopDomains failed: %v", err)
+	}
+	if len(domains) != 1 || domains[0].Domain != "apnews.com" || domains[0].AvgScore != 125 || domains[0].Share != 0.5 {
+		t.Errorf("Unexpected domains after a new score: %+v", domains)
+	}
+
+	page, err := store.QueryPosts(ctx, PostFilter{Domains: []string{"BBC.co.uk"}})
+	if err != nil {
+		t.Fatalf("QueryPosts failed: %v", err)
+	}
+	if ids := postIDs(page.Posts); !reflect.DeepEqual(ids, []string{"d", "c"}) {
+		t.Errorf("Expected the bbc.co.uk posts, got %v", ids)
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+	"time"
+)
+
+// TopEntitiesQuery selects the most mentioned entities over a window
+type TopEntitiesQuery struct {
+	Subreddit string
+	Type      string    // PERSON, GPE or ORG; empty for all
+	Since     time.Time // inclusive, required
+	Until     time.Time // exclusive, defaults to now
+	Limit     int       // defaults to 50, capped at 500
+}
+
+// uniqueEntities dedupes entities so a post counts once per entity
+func uniqueEntities(entities []reddit.Entity) []reddit.Entity {
+	seen := make(map[reddit.Entity]bool, len(entities))
+	unique := make([]reddit.Entity, 0, len(entities))
+	for _, entity := range entities {
+		if entity.Name != "" && entity.Type != "" && !seen[entity] {
+			seen[entity] = true
+			unique = append(unique, entity)
+		}
+	}
+	return unique
+}
+
+// loadEntities returns the stored entities of the given posts by post ID
+func (s *sqlStore) loadEntities(ctx context.Context, q querier, ids []string) (map[string][]reddit.Entity, error) {
+	entities := make(map[string][]reddit.Entity)
+	if len(ids) == 0 {
+		return entities, nil
+	}
+
+	b := &queryBuilder{}
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		placeholders[i] = b.arg(id)
+	}
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(
+		"SELECT post_id, entity, type FROM post_entities WHERE post_id IN (%s) ORDER BY post_id, type, entity",
+		strings.Join(placeholders, ", ")), b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var entity reddit.Entity
+		if err := rows.Scan(&id, &entity.Name, &entity.Type); err != nil {
+			return nil, fmt.Errorf("failed to scan entity: %w", err)
+		}
+		entities[id] = append(entities[id], entity)
+	}
+	return entities, rows.Err()
+}
+
+// attachEntities fills in the entities of posts read from reddit_posts
+func (s *sqlStore) attachEntities(ctx context.Context, posts []reddit.Post) error {
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	entities, err := s.loadEntities(ctx, s.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Entities = entities[posts[i].ID]
+	}
+	return nil
+}
+
+// saveEntities replaces the stored entities of a post
+func (s *sqlStore) saveEntities(ctx context.Context, tx *sql.Tx, post reddit.Post) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM post_entities WHERE post_id = $1", post.ID); err != nil {
+		return fmt.Errorf("failed to clear entities: %w", err)
+	}
+	for _, entity := range uniqueEntities(post.Entities) {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO post_entities (post_id, entity, type) VALUES ($1, $2, $3)",
+			post.ID, entity.Name, entity.Type)
+		if err != nil {
+			return fmt.Errorf("failed to save entity: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteOrphanEntities removes the entities of posts that no longer exist.
+// Their rollups are kept, like those of topics.
+func (s *sqlStore) deleteOrphanEntities(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM post_entities
+		WHERE NOT EXISTS (SELECT 1 FROM reddit_posts WHERE reddit_posts.id = post_entities.post_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to delete entities of expired posts: %w", err)
+	}
+	return nil
+}
+
+// TopEntities returns the most mentioned entities in the window, most
+// mentioned first
+func (s *sqlStore) TopEntities(ctx context.Context, q TopEntitiesQuery) ([]reddit.EntityCount, error) {
+	b := &queryBuilder{}
+	limit, err := s.rollupWindow(b, q.Subreddit, q.Since, q.Until, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if q.Type != "" {
+		b.where("type = " + b.arg(q.Type))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT entity, type, SUM(post_count) AS total
+		FROM entity_rollups%s
+		GROUP BY entity, type
+		HAVING SUM(post_count) > 0
+		ORDER BY total DESC, entity, type
+		LIMIT %s`, b.whereClause(), b.arg(limit))
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []reddit.EntityCount{}
+	for rows.Next() {
+		var ec reddit.EntityCount
+		if err := rows.Scan(&ec.Name, &ec.Type, &ec.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan top entities: %w", err)
+		}
+		entities = append(entities, ec)
+	}
+	return entities, rows.Err()
+}
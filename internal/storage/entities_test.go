@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"goreddit/internal/reddit"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreEntities(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	putin := reddit.Entity{Name: "Vladimir Putin", Type: reddit.EntityPerson}
+	ukraine := reddit.Entity{Name: "Ukraine", Type: reddit.EntityPlace}
+	nato := reddit.Entity{Name: "NATO", Type: reddit.EntityOrganization}
+
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "worldnews", CreatedAt: float64(base.Unix()), Sentiment: -1, Entities: []reddit.Entity{putin, ukraine, ukraine}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", CreatedAt: float64(base.Add(time.Minute).Unix()), Sentiment: 1, Entities: []reddit.Entity{ukraine, nato}},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "news", CreatedAt: float64(base.Add(2 * time.Minute).Unix()), Entities: []reddit.Entity{ukraine}},
+	)
+
+	got, err := store.GetPost(ctx, "a")
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if want := []reddit.Entity{ukraine, putin}; !reflect.DeepEqual(got.Entities, want) {
+		t.Errorf("Expected entities %+v, got %+v", want, got.Entities)
+	}
+
+	page, err := store.QueryPosts(ctx, PostFilter{Entity: "NATO"})
+	if err != nil {
+		t.Fatalf("QueryPosts failed: %v", err)
+	}
+	if ids := postIDs(page.Posts); !reflect.DeepEqual(ids, []string{"b"}) || len(page.Posts[0].Entities) != 2 {
+		t.Errorf("Expected post b with its entities, got %+v", page.Posts)
+	}
+
+	window := TopEntitiesQuery{Since: base, Until: base.Add(time.Hour)}
+	top, err := store.TopEntities(ctx, window)
+	if err != nil {
+		t.Fatalf("TopEntities failed: %v", err)
+	}
+	if len(top) != 3 || top[0].Name != "Ukraine" || top[0].Count != 3 || top[0].Type != reddit.EntityPlace {
+		t.Errorf("Unexpected top entities: %+v", top)
+	}
+
+	window.Type = reddit.EntityPerson
+	if top, err := store.TopEntities(ctx, window); err != nil || len(top) != 1 || top[0].Name != "Vladimir Putin" {
+		t.Errorf("Unexpected top people: %+v (%v)", top, err)
+	}
+
+	// Re-saving replaces the stored entities and moves the rollups with them
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "worldnews", CreatedAt: float64(base.Unix()), Sentiment: -1, Entities: []reddit.Entity{nato}},
+	)
+	if got, _ := store.GetPost(ctx, "a"); !reflect.DeepEqual(got.Entities, []reddit.Entity{nato}) {
+		t.Errorf("Expected entities to be replaced, got %+v", got.Entities)
+	}
+	series, err := store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionHour, Subreddit: "worldnews", Entity: "NATO", Since: base, Until: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(series) != 1 || series[0].Posts != 2 || series[0].AvgSentiment != 0 {
+		t.Errorf("Unexpected entity series: %+v", series)
+	}
+	if _, err := store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionHour, Topic: "x", Entity: "NATO", Since: base}); err == nil {
+		t.Error("Expected an error for a topic and entity series")
+	}
+
+	// Expiring posts removes their entities but keeps the rollups
+	if _, err := store.Maintain(ctx, RetentionPolicy{KeepFor: time.Hour}, base.Add(time.Hour+90*time.Second)); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	var remaining int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM post_entities").Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count entities: %v", err)
+	}
+	if remaining != 1 {
+		t.Errorf("Expected only post c's entity to remain, got %d", remaining)
+	}
+	if top, err := store.TopEntities(ctx, TopEntitiesQuery{Since: base, Until: base.Add(time.Hour)}); err != nil || len(top) != 2 {
+		t.Errorf("Expected rollups to outlive posts, got %+v (%v)", top, err)
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_topics.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN topics TEXT;")},
+		"m/0002_add_topics.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN topics;")},
+		"m/0001_init.up.sql":         {Data: []byte("CREATE TABLE t (id INT);")},
+		"m/README.md":                {Data: []byte("ignored")},
+	}
+
+	migrations, err := loadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "init" || migrations[0].Down != "" {
+		t.Errorf("Unexpected first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_topics" || migrations[1].Down == "" {
+		t.Errorf("Unexpected second migration: %+v", migrations[1])
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing up":     {"m/0001_init.down.sql": {Data: []byte("DROP TABLE t;")}},
+		"bad version":    {"m/abc_init.up.sql": {Data: []byte("SELECT 1;")}},
+		"bad direction":  {"m/0001_init.sql": {Data: []byte("SELECT 1;")}},
+		"name collision": {"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "m/0001_b.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, d := range []dialect{postgresDialect, sqliteDialect} {
+		migrations, err := loadMigrations(migrationFiles, d.migrations)
+		if err != nil {
+			t.Fatalf("Failed to load embedded %s migrations: %v", d.name, err)
+		}
+
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s: expected contiguous versions, got %d at position %d", d.name, m.Version, i)
+			}
+			if m.Down == "" {
+				t.Errorf("%s: migration %04d_%s has no down script", d.name, m.Version, m.Name)
+			}
+		}
+	}
+}
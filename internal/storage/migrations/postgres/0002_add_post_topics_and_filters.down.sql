@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reddit_posts_subreddit_lower;
+DROP INDEX IF EXISTS idx_reddit_posts_topics;
+
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS topics;
@@ -0,0 +1,4 @@
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS topics TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_topics ON reddit_posts USING GIN (topics);
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_subreddit_lower ON reddit_posts (LOWER(subreddit));
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reddit_posts_search;
+
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,8 @@
+-- Title matches weigh more than body matches when ranking
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(body, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_search ON reddit_posts USING GIN (search_vector);
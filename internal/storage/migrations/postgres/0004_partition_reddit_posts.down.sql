@@ -0,0 +1,36 @@
+ALTER TABLE reddit_posts RENAME TO reddit_posts_partitioned;
+ALTER INDEX reddit_posts_pkey RENAME TO reddit_posts_partitioned_pkey;
+
+CREATE TABLE reddit_posts (
+    id VARCHAR(255) PRIMARY KEY,
+    title TEXT NOT NULL,
+    body TEXT,
+    subreddit VARCHAR(255) NOT NULL,
+    score INT NOT NULL,
+    url TEXT,
+    created_at FLOAT NOT NULL,
+    sentiment FLOAT,
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    stored_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+            setweight(to_tsvector('english', COALESCE(body, '')), 'B')
+        ) STORED
+);
+
+INSERT INTO reddit_posts (id, title, body, subreddit, score, url, created_at, sentiment, topics, stored_at)
+SELECT DISTINCT ON (id) id, title, body, subreddit, score, url, EXTRACT(EPOCH FROM created_at), sentiment, topics, stored_at
+FROM reddit_posts_partitioned
+ORDER BY id, stored_at DESC;
+
+-- Drops every partition along with the parent
+DROP TABLE reddit_posts_partitioned;
+
+CREATE INDEX idx_reddit_posts_subreddit ON reddit_posts (subreddit);
+CREATE INDEX idx_reddit_posts_subreddit_lower ON reddit_posts (LOWER(subreddit));
+CREATE INDEX idx_reddit_posts_created_at ON reddit_posts (created_at);
+CREATE INDEX idx_reddit_posts_score ON reddit_posts (score);
+CREATE INDEX idx_reddit_posts_sentiment ON reddit_posts (sentiment);
+CREATE INDEX idx_reddit_posts_topics ON reddit_posts USING GIN (topics);
+CREATE INDEX idx_reddit_posts_search ON reddit_posts USING GIN (search_vector);
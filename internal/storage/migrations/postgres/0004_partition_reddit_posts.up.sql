@@ -0,0 +1,39 @@
+-- Re-create reddit_posts range-partitioned on a timestamptz created_at.
+-- Existing rows land in the default partition; the maintenance job creates
+-- dated partitions ahead of time and moves any matching rows out of default.
+ALTER TABLE reddit_posts RENAME TO reddit_posts_unpartitioned;
+ALTER INDEX reddit_posts_pkey RENAME TO reddit_posts_unpartitioned_pkey;
+
+CREATE TABLE reddit_posts (
+    id VARCHAR(255) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT,
+    subreddit VARCHAR(255) NOT NULL,
+    score INT NOT NULL,
+    url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sentiment FLOAT,
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    stored_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+            setweight(to_tsvector('english', COALESCE(body, '')), 'B')
+        ) STORED,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE reddit_posts_default PARTITION OF reddit_posts DEFAULT;
+
+INSERT INTO reddit_posts (id, title, body, subreddit, score, url, created_at, sentiment, topics, stored_at)
+SELECT id, title, body, subreddit, score, url, to_timestamp(created_at), sentiment, topics, stored_at
+FROM reddit_posts_unpartitioned;
+
+DROP TABLE reddit_posts_unpartitioned;
+
+CREATE INDEX idx_reddit_posts_subreddit_lower ON reddit_posts (LOWER(subreddit));
+CREATE INDEX idx_reddit_posts_created_at ON reddit_posts (created_at);
+CREATE INDEX idx_reddit_posts_score ON reddit_posts (score);
+CREATE INDEX idx_reddit_posts_sentiment ON reddit_posts (sentiment);
+CREATE INDEX idx_reddit_posts_topics ON reddit_posts USING GIN (topics);
+CREATE INDEX idx_reddit_posts_search ON reddit_posts USING GIN (search_vector);
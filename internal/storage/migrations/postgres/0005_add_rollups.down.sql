@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS topic_rollups;
+DROP TABLE IF EXISTS subreddit_rollups;
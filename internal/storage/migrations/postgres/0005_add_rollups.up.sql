@@ -0,0 +1,54 @@
+-- Per-subreddit and per-topic counters, maintained incrementally as posts are
+-- saved. resolution is 'minute', 'hour' or 'day' and bucket is the start of
+-- the period. Sentiment is stored as a sum so averages can be re-aggregated.
+CREATE TABLE IF NOT EXISTS subreddit_rollups (
+    resolution TEXT NOT NULL,
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    subreddit TEXT NOT NULL,
+    post_count INT NOT NULL,
+    sentiment_sum FLOAT NOT NULL,
+    PRIMARY KEY (resolution, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subreddit_rollups_bucket ON subreddit_rollups (resolution, bucket);
+
+CREATE TABLE IF NOT EXISTS topic_rollups (
+    resolution TEXT NOT NULL,
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    subreddit TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    post_count INT NOT NULL,
+    sentiment_sum FLOAT NOT NULL,
+    PRIMARY KEY (resolution, topic, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_topic_rollups_bucket ON topic_rollups (resolution, bucket);
+
+-- Backfill from posts saved before rollups existed. Buckets are truncated in
+-- UTC to match the ones computed when saving.
+WITH resolutions (resolution) AS (VALUES ('minute'), ('hour'), ('day')),
+posts AS (
+    SELECT r.resolution,
+        date_trunc(r.resolution, p.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS bucket,
+        LOWER(p.subreddit) AS subreddit,
+        COALESCE(p.sentiment, 0) AS sentiment,
+        p.topics
+    FROM reddit_posts p CROSS JOIN resolutions r
+)
+INSERT INTO subreddit_rollups (resolution, bucket, subreddit, post_count, sentiment_sum)
+SELECT resolution, bucket, subreddit, COUNT(*), SUM(sentiment)
+FROM posts
+GROUP BY resolution, bucket, subreddit;
+
+WITH resolutions (resolution) AS (VALUES ('minute'), ('hour'), ('day')),
+post_topics AS (
+    SELECT DISTINCT p.id, t.topic, LOWER(p.subreddit) AS subreddit, p.created_at, COALESCE(p.sentiment, 0) AS sentiment
+    FROM reddit_posts p CROSS JOIN LATERAL unnest(p.topics) AS t(topic)
+    WHERE t.topic <> ''
+)
+INSERT INTO topic_rollups (resolution, bucket, subreddit, topic, post_count, sentiment_sum)
+SELECT r.resolution,
+    date_trunc(r.resolution, pt.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+    pt.subreddit, pt.topic, COUNT(*), SUM(pt.sentiment)
+FROM post_topics pt CROSS JOIN resolutions r
+GROUP BY 1, 2, pt.subreddit, pt.topic;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS entity_rollups;
+DROP TABLE IF EXISTS post_entities;
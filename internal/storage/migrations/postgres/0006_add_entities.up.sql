@@ -0,0 +1,25 @@
+-- Named entities extracted from posts. There is no foreign key because
+-- reddit_posts is partitioned and its key includes created_at; rows for
+-- expired posts are removed by storage maintenance.
+CREATE TABLE IF NOT EXISTS post_entities (
+    post_id TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    type TEXT NOT NULL,
+    PRIMARY KEY (post_id, type, entity)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_entities_entity ON post_entities (entity, type);
+
+-- Per-entity counters, maintained like topic_rollups
+CREATE TABLE IF NOT EXISTS entity_rollups (
+    resolution TEXT NOT NULL,
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    subreddit TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    type TEXT NOT NULL,
+    post_count INT NOT NULL,
+    sentiment_sum FLOAT NOT NULL,
+    PRIMARY KEY (resolution, entity, type, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_rollups_bucket ON entity_rollups (resolution, bucket);
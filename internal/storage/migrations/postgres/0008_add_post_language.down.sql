@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reddit_posts_language;
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS unsupported;
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS language;
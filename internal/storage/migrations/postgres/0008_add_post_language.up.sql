@@ -0,0 +1,6 @@
+-- The detected language of a post, as an ISO 639-1 code, and whether its
+-- sentiment was left neutral for want of a model for that language.
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS language TEXT;
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS unsupported BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_language ON reddit_posts (language);
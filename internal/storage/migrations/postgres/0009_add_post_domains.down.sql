@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS domain_rollups;
+DROP INDEX IF EXISTS idx_reddit_posts_domain;
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS domain;
@@ -0,0 +1,20 @@
+-- The registrable domain of a post's link, like "bbc.co.uk", and per-domain
+-- counters maintained like topic_rollups. Scores are summed too, so the
+-- outlets of a subreddit can be compared by average score. Posts saved
+-- before this migration have no domain until they are enriched again.
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS domain TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_domain ON reddit_posts (domain);
+
+CREATE TABLE IF NOT EXISTS domain_rollups (
+    resolution TEXT NOT NULL,
+    bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+    subreddit TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    post_count INT NOT NULL,
+    score_sum BIGINT NOT NULL,
+    sentiment_sum FLOAT NOT NULL,
+    PRIMARY KEY (resolution, domain, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_rollups_bucket ON domain_rollups (resolution, bucket);
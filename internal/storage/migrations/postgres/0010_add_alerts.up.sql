@@ -0,0 +1,18 @@
+-- Alert rules created through the API, as the JSON the alerts package reads,
+-- and the alerts fired for each rule. An alert is keyed by the story it's
+-- about, so that a rule fires once per story within its dedup window even
+-- when several consumers evaluate the same post.
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id TEXT PRIMARY KEY,
+    definition TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alert_events (
+    rule_id TEXT NOT NULL,
+    dedup_key TEXT NOT NULL,
+    fired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (rule_id, dedup_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_fired_at ON alert_events (rule_id, fired_at);
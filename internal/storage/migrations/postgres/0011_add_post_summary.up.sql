@@ -0,0 +1,2 @@
+-- Key sentences of long post bodies, picked by the consumer
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS summary TEXT;
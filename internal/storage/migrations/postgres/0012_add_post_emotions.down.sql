@@ -0,0 +1,19 @@
+ALTER TABLE subreddit_rollups
+    DROP COLUMN IF EXISTS anger_sum,
+    DROP COLUMN IF EXISTS anticipation_sum,
+    DROP COLUMN IF EXISTS disgust_sum,
+    DROP COLUMN IF EXISTS fear_sum,
+    DROP COLUMN IF EXISTS joy_sum,
+    DROP COLUMN IF EXISTS sadness_sum,
+    DROP COLUMN IF EXISTS surprise_sum,
+    DROP COLUMN IF EXISTS trust_sum;
+
+ALTER TABLE reddit_posts
+    DROP COLUMN IF EXISTS emotion_anger,
+    DROP COLUMN IF EXISTS emotion_anticipation,
+    DROP COLUMN IF EXISTS emotion_disgust,
+    DROP COLUMN IF EXISTS emotion_fear,
+    DROP COLUMN IF EXISTS emotion_joy,
+    DROP COLUMN IF EXISTS emotion_sadness,
+    DROP COLUMN IF EXISTS emotion_surprise,
+    DROP COLUMN IF EXISTS emotion_trust;
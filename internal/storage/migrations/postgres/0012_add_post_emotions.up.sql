@@ -0,0 +1,23 @@
+-- Emotion vectors of posts, one share per NRC emotion, and their sums per
+-- subreddit rollup so averages can be re-aggregated like sentiment. Posts
+-- saved before this migration count as having no emotion words until they
+-- are enriched again.
+ALTER TABLE reddit_posts
+    ADD COLUMN IF NOT EXISTS emotion_anger FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_anticipation FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_disgust FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_fear FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_joy FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_sadness FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_surprise FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS emotion_trust FLOAT NOT NULL DEFAULT 0;
+
+ALTER TABLE subreddit_rollups
+    ADD COLUMN IF NOT EXISTS anger_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS anticipation_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS disgust_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS fear_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS joy_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS sadness_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS surprise_sum FLOAT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS trust_sum FLOAT NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+ALTER TABLE subreddit_rollups
+    DROP COLUMN IF EXISTS toxicity_sum,
+    DROP COLUMN IF EXISTS toxic_count;
+
+DROP INDEX IF EXISTS idx_reddit_posts_toxicity;
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS toxicity;
@@ -0,0 +1,4 @@
+-- The analyzer and model version that scored each post's sentiment, like
+-- "bayes:imdb", so scores from different models can be told apart. Posts saved
+-- before this migration have none until they are enriched again.
+ALTER TABLE reddit_posts ADD COLUMN IF NOT EXISTS sentiment_model TEXT;
@@ -0,0 +1 @@
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS analyzer_versions;
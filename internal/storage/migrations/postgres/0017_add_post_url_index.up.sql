@@ -0,0 +1,2 @@
+-- Consumers look up the story of earlier posts linking to the same URL
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_url ON reddit_posts (url, created_at);
@@ -0,0 +1,5 @@
+ALTER TABLE domain_rollups DROP COLUMN IF EXISTS analyzed_count;
+ALTER TABLE entity_rollups DROP COLUMN IF EXISTS analyzed_count;
+ALTER TABLE topic_rollups DROP COLUMN IF EXISTS analyzed_count;
+ALTER TABLE subreddit_rollups DROP COLUMN IF EXISTS analyzed_count, DROP COLUMN IF EXISTS scored_count;
+ALTER TABLE reddit_posts DROP COLUMN IF EXISTS unscored;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS reddit_posts (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    body TEXT,
+    subreddit TEXT NOT NULL,
+    score INTEGER NOT NULL,
+    url TEXT,
+    created_at REAL NOT NULL,
+    sentiment REAL,
+    topics TEXT NOT NULL DEFAULT '[]',
+    stored_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_subreddit_lower ON reddit_posts (LOWER(subreddit));
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_created_at ON reddit_posts (created_at);
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_score ON reddit_posts (score);
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_sentiment ON reddit_posts (sentiment);
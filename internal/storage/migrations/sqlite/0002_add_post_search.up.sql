@@ -0,0 +1,23 @@
+-- External-content FTS5 index over reddit_posts, kept in sync by triggers
+CREATE VIRTUAL TABLE IF NOT EXISTS reddit_posts_fts USING fts5(
+    title,
+    body,
+    content = 'reddit_posts',
+    content_rowid = 'rowid',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS reddit_posts_fts_insert AFTER INSERT ON reddit_posts BEGIN
+    INSERT INTO reddit_posts_fts (rowid, title, body) VALUES (new.rowid, new.title, new.body);
+END;
+
+CREATE TRIGGER IF NOT EXISTS reddit_posts_fts_delete AFTER DELETE ON reddit_posts BEGIN
+    INSERT INTO reddit_posts_fts (reddit_posts_fts, rowid, title, body) VALUES ('delete', old.rowid, old.title, old.body);
+END;
+
+CREATE TRIGGER IF NOT EXISTS reddit_posts_fts_update AFTER UPDATE OF title, body ON reddit_posts BEGIN
+    INSERT INTO reddit_posts_fts (reddit_posts_fts, rowid, title, body) VALUES ('delete', old.rowid, old.title, old.body);
+    INSERT INTO reddit_posts_fts (rowid, title, body) VALUES (new.rowid, new.title, new.body);
+END;
+
+INSERT INTO reddit_posts_fts (reddit_posts_fts) VALUES ('rebuild');
@@ -0,0 +1,45 @@
+-- Per-subreddit and per-topic counters, maintained incrementally as posts are
+-- saved. resolution is 'minute', 'hour' or 'day' and bucket is the start of
+-- the period. Sentiment is stored as a sum so averages can be re-aggregated.
+CREATE TABLE IF NOT EXISTS subreddit_rollups (
+    resolution TEXT NOT NULL,
+    bucket REAL NOT NULL,
+    subreddit TEXT NOT NULL,
+    post_count INTEGER NOT NULL,
+    sentiment_sum REAL NOT NULL,
+    PRIMARY KEY (resolution, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subreddit_rollups_bucket ON subreddit_rollups (resolution, bucket);
+
+CREATE TABLE IF NOT EXISTS topic_rollups (
+    resolution TEXT NOT NULL,
+    bucket REAL NOT NULL,
+    subreddit TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    post_count INTEGER NOT NULL,
+    sentiment_sum REAL NOT NULL,
+    PRIMARY KEY (resolution, topic, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_topic_rollups_bucket ON topic_rollups (resolution, bucket);
+
+-- Backfill from posts saved before rollups existed
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400))
+INSERT INTO subreddit_rollups (resolution, bucket, subreddit, post_count, sentiment_sum)
+SELECT r.resolution, CAST(p.created_at AS INTEGER) / r.size * r.size, LOWER(p.subreddit),
+    COUNT(*), SUM(COALESCE(p.sentiment, 0))
+FROM reddit_posts p CROSS JOIN resolutions r
+GROUP BY 1, 2, 3;
+
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400)),
+post_topics AS (
+    SELECT DISTINCT p.id, t.value AS topic, LOWER(p.subreddit) AS subreddit, p.created_at, COALESCE(p.sentiment, 0) AS sentiment
+    FROM reddit_posts p, json_each(p.topics) t
+    WHERE t.value <> ''
+)
+INSERT INTO topic_rollups (resolution, bucket, subreddit, topic, post_count, sentiment_sum)
+SELECT r.resolution, CAST(pt.created_at AS INTEGER) / r.size * r.size, pt.subreddit, pt.topic,
+    COUNT(*), SUM(pt.sentiment)
+FROM post_topics pt CROSS JOIN resolutions r
+GROUP BY 1, 2, 3, 4;
@@ -0,0 +1,24 @@
+-- Named entities extracted from posts. Rows for expired posts are removed by
+-- storage maintenance, as in Postgres.
+CREATE TABLE IF NOT EXISTS post_entities (
+    post_id TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    type TEXT NOT NULL,
+    PRIMARY KEY (post_id, type, entity)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_entities_entity ON post_entities (entity, type);
+
+-- Per-entity counters, maintained like topic_rollups
+CREATE TABLE IF NOT EXISTS entity_rollups (
+    resolution TEXT NOT NULL,
+    bucket REAL NOT NULL,
+    subreddit TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    type TEXT NOT NULL,
+    post_count INTEGER NOT NULL,
+    sentiment_sum REAL NOT NULL,
+    PRIMARY KEY (resolution, entity, type, subreddit, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_rollups_bucket ON entity_rollups (resolution, bucket);
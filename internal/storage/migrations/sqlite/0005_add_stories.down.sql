@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stories;
+DROP INDEX IF EXISTS idx_reddit_posts_story_id;
+ALTER TABLE reddit_posts DROP COLUMN story_id;
@@ -0,0 +1,15 @@
+-- Posts about the same story, like one article cross-posted to several
+-- subreddits, share a story_id. The story itself keeps the title and link of
+-- the post that started it; its counts are read from reddit_posts.
+ALTER TABLE reddit_posts ADD COLUMN story_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_story_id ON reddit_posts (story_id);
+
+CREATE TABLE IF NOT EXISTS stories (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    url TEXT,
+    created_at REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stories_created_at ON stories (created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reddit_posts_language;
+ALTER TABLE reddit_posts DROP COLUMN unsupported;
+ALTER TABLE reddit_posts DROP COLUMN language;
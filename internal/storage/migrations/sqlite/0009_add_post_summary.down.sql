@@ -0,0 +1 @@
+ALTER TABLE reddit_posts DROP COLUMN summary;
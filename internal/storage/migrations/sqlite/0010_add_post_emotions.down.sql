@@ -0,0 +1,17 @@
+ALTER TABLE subreddit_rollups DROP COLUMN anger_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN anticipation_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN disgust_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN fear_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN joy_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN sadness_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN surprise_sum;
+ALTER TABLE subreddit_rollups DROP COLUMN trust_sum;
+
+ALTER TABLE reddit_posts DROP COLUMN emotion_anger;
+ALTER TABLE reddit_posts DROP COLUMN emotion_anticipation;
+ALTER TABLE reddit_posts DROP COLUMN emotion_disgust;
+ALTER TABLE reddit_posts DROP COLUMN emotion_fear;
+ALTER TABLE reddit_posts DROP COLUMN emotion_joy;
+ALTER TABLE reddit_posts DROP COLUMN emotion_sadness;
+ALTER TABLE reddit_posts DROP COLUMN emotion_surprise;
+ALTER TABLE reddit_posts DROP COLUMN emotion_trust;
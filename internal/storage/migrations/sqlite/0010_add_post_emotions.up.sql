@@ -0,0 +1,21 @@
+-- Emotion vectors of posts, one share per NRC emotion, and their sums per
+-- subreddit rollup so averages can be re-aggregated like sentiment. Posts
+-- saved before this migration count as having no emotion words until they
+-- are enriched again.
+ALTER TABLE reddit_posts ADD COLUMN emotion_anger REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_anticipation REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_disgust REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_fear REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_joy REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_sadness REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_surprise REAL NOT NULL DEFAULT 0;
+ALTER TABLE reddit_posts ADD COLUMN emotion_trust REAL NOT NULL DEFAULT 0;
+
+ALTER TABLE subreddit_rollups ADD COLUMN anger_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN anticipation_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN disgust_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN fear_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN joy_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN sadness_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN surprise_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN trust_sum REAL NOT NULL DEFAULT 0;
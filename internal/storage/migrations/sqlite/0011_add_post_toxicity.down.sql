@@ -0,0 +1,5 @@
+ALTER TABLE subreddit_rollups DROP COLUMN toxic_count;
+ALTER TABLE subreddit_rollups DROP COLUMN toxicity_sum;
+
+DROP INDEX IF EXISTS idx_reddit_posts_toxicity;
+ALTER TABLE reddit_posts DROP COLUMN toxicity;
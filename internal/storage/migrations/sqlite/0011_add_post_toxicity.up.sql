@@ -0,0 +1,10 @@
+-- Toxicity scores of posts, and per subreddit rollup their sum and the count
+-- of toxic posts, so a subreddit's average toxicity and share of toxic posts
+-- can be followed over time. Posts saved before this migration score 0 until
+-- they are enriched again.
+ALTER TABLE reddit_posts ADD COLUMN toxicity REAL NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_reddit_posts_toxicity ON reddit_posts (toxicity);
+
+ALTER TABLE subreddit_rollups ADD COLUMN toxicity_sum REAL NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN toxic_count INTEGER NOT NULL DEFAULT 0;
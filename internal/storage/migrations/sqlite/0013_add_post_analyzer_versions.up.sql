@@ -0,0 +1,4 @@
+-- The versions of the analyzers that enriched each post, so the reenrich job
+-- can find posts scored by analyzers that have changed since. Posts saved
+-- before this migration have none, and are enriched again by the first run.
+ALTER TABLE reddit_posts ADD COLUMN analyzer_versions TEXT;
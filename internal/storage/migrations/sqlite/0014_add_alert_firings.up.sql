@@ -0,0 +1,11 @@
+-- Every time an alert rule fired, for its hourly cap. alert_events keeps one
+-- row per dedup key, which a repeat takes over, so it undercounts rules that
+-- fire again for the same story.
+CREATE TABLE IF NOT EXISTS alert_firings (
+    rule_id TEXT NOT NULL,
+    fired_at REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_firings_rule_id ON alert_firings (rule_id, fired_at);
+
+INSERT INTO alert_firings (rule_id, fired_at) SELECT rule_id, fired_at FROM alert_events;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_reddit_posts_url;
@@ -0,0 +1,6 @@
+ALTER TABLE domain_rollups DROP COLUMN analyzed_count;
+ALTER TABLE entity_rollups DROP COLUMN analyzed_count;
+ALTER TABLE topic_rollups DROP COLUMN analyzed_count;
+ALTER TABLE subreddit_rollups DROP COLUMN scored_count;
+ALTER TABLE subreddit_rollups DROP COLUMN analyzed_count;
+ALTER TABLE reddit_posts DROP COLUMN unscored;
@@ -0,0 +1,74 @@
+-- Posts in languages the analyzers don't support are left neutral, so they
+-- mustn't weigh on averages. Every rollup counts the posts whose sentiment was
+-- analyzed, and subreddit rollups those whose toxicity and emotions were
+-- scored, which posts marked unscored weren't; so far those are the posts
+-- whose sentiment wasn't analyzed either.
+ALTER TABLE reddit_posts ADD COLUMN unscored INTEGER NOT NULL DEFAULT 0;
+
+UPDATE reddit_posts SET unscored = 1 WHERE unsupported;
+
+ALTER TABLE subreddit_rollups ADD COLUMN analyzed_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE subreddit_rollups ADD COLUMN scored_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE topic_rollups ADD COLUMN analyzed_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE entity_rollups ADD COLUMN analyzed_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE domain_rollups ADD COLUMN analyzed_count INTEGER NOT NULL DEFAULT 0;
+
+-- Backfill: every post counted so far, except the unsupported ones still stored
+UPDATE subreddit_rollups SET analyzed_count = post_count, scored_count = post_count;
+UPDATE topic_rollups SET analyzed_count = post_count;
+UPDATE entity_rollups SET analyzed_count = post_count;
+UPDATE domain_rollups SET analyzed_count = post_count;
+
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400)),
+unsupported AS (
+    SELECT r.resolution, CAST(p.created_at AS INTEGER) / r.size * r.size AS bucket,
+        LOWER(p.subreddit) AS subreddit, COUNT(*) AS posts
+    FROM reddit_posts p CROSS JOIN resolutions r
+    WHERE p.unsupported
+    GROUP BY 1, 2, 3
+)
+UPDATE subreddit_rollups AS sr
+SET analyzed_count = sr.analyzed_count - u.posts, scored_count = sr.scored_count - u.posts
+FROM unsupported u
+WHERE sr.resolution = u.resolution AND sr.bucket = u.bucket AND sr.subreddit = u.subreddit;
+
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400)),
+post_topics AS (
+    SELECT DISTINCT p.id, t.value AS topic, LOWER(p.subreddit) AS subreddit, p.created_at
+    FROM reddit_posts p, json_each(p.topics) t
+    WHERE t.value <> '' AND p.unsupported
+),
+unsupported AS (
+    SELECT r.resolution, CAST(pt.created_at AS INTEGER) / r.size * r.size AS bucket,
+        pt.subreddit, pt.topic, COUNT(*) AS posts
+    FROM post_topics pt CROSS JOIN resolutions r
+    GROUP BY 1, 2, 3, 4
+)
+UPDATE topic_rollups AS tr SET analyzed_count = tr.analyzed_count - u.posts
+FROM unsupported u
+WHERE tr.resolution = u.resolution AND tr.bucket = u.bucket AND tr.subreddit = u.subreddit AND tr.topic = u.topic;
+
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400)),
+unsupported AS (
+    SELECT r.resolution, CAST(p.created_at AS INTEGER) / r.size * r.size AS bucket,
+        LOWER(p.subreddit) AS subreddit, e.entity, e.type, COUNT(*) AS posts
+    FROM reddit_posts p JOIN post_entities e ON e.post_id = p.id CROSS JOIN resolutions r
+    WHERE p.unsupported
+    GROUP BY 1, 2, 3, 4, 5
+)
+UPDATE entity_rollups AS er SET analyzed_count = er.analyzed_count - u.posts
+FROM unsupported u
+WHERE er.resolution = u.resolution AND er.bucket = u.bucket AND er.subreddit = u.subreddit
+    AND er.entity = u.entity AND er.type = u.type;
+
+WITH resolutions (resolution, size) AS (VALUES ('minute', 60), ('hour', 3600), ('day', 86400)),
+unsupported AS (
+    SELECT r.resolution, CAST(p.created_at AS INTEGER) / r.size * r.size AS bucket,
+        LOWER(p.subreddit) AS subreddit, LOWER(p.domain) AS domain, COUNT(*) AS posts
+    FROM reddit_posts p CROSS JOIN resolutions r
+    WHERE p.unsupported AND p.domain IS NOT NULL AND p.domain <> ''
+    GROUP BY 1, 2, 3, 4
+)
+UPDATE domain_rollups AS dr SET analyzed_count = dr.analyzed_count - u.posts
+FROM unsupported u
+WHERE dr.resolution = u.resolution AND dr.bucket = u.bucket AND dr.subreddit = u.subreddit AND dr.domain = u.domain;
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	partitionPrefix  = "reddit_posts_"
+	defaultPartition = "reddit_posts_default"
+	day              = 24 * time.Hour
+)
+
+// partition is one dated reddit_posts partition. The name encodes its range:
+// reddit_posts_d20240501 covers that day and reddit_posts_w20240429 covers
+// the week starting that Monday, all in UTC.
+type partition struct {
+	name       string
+	start, end time.Time
+}
+
+func newPartition(start time.Time, interval PartitionInterval) partition {
+	start = start.UTC()
+	if interval == PartitionWeekly {
+		return partition{name: partitionPrefix + "w" + start.Format("20060102"), start: start, end: start.Add(7 * day)}
+	}
+	return partition{name: partitionPrefix + "d" + start.Format("20060102"), start: start, end: start.Add(day)}
+}
+
+// parsePartition recovers a partition's range from its name
+func parsePartition(name string) (partition, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok || len(suffix) != 9 {
+		return partition{}, false
+	}
+
+	start, err := time.Parse("20060102", suffix[1:])
+	if err != nil {
+		return partition{}, false
+	}
+
+	switch suffix[0] {
+	case 'd':
+		return newPartition(start, PartitionDaily), true
+	case 'w':
+		return newPartition(start, PartitionWeekly), true
+	default:
+		return partition{}, false
+	}
+}
+
+func (p partition) covers(t time.Time) bool {
+	return !t.Before(p.start) && t.Before(p.end)
+}
+
+func (p partition) overlaps(o partition) bool {
+	return p.start.Before(o.end) && o.start.Before(p.end)
+}
+
+// planPartitions returns the partitions to create so that the current period
+// and the next policy.Premake periods are covered. Weekly partitions fall back
+// to daily ones around days already covered by other partitions, which keeps
+// ranges from overlapping when the interval setting changes.
+func planPartitions(existing []partition, policy RetentionPolicy, now time.Time) []partition {
+	today := now.UTC().Truncate(day)
+	horizon := today.Add(time.Duration(policy.Premake+1) * day)
+	if policy.Interval == PartitionWeekly {
+		horizon = mondayOf(today).Add(time.Duration(policy.Premake+1) * 7 * day)
+	}
+
+	known := append([]partition(nil), existing...)
+	var planned []partition
+
+	for d := today; d.Before(horizon); d = d.Add(day) {
+		covered := false
+		for _, p := range known {
+			if p.covers(d) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+
+		next := newPartition(d, PartitionDaily)
+		if policy.Interval == PartitionWeekly {
+			week := newPartition(mondayOf(d), PartitionWeekly)
+			free := true
+			for _, p := range known {
+				if p.overlaps(week) {
+					free = false
+					break
+				}
+			}
+			if free {
+				next = week
+			}
+		}
+
+		known = append(known, next)
+		planned = append(planned, next)
+	}
+
+	return planned
+}
+
+// mondayOf returns midnight UTC on the Monday of t's week
+func mondayOf(t time.Time) time.Time {
+	t = t.UTC().Truncate(day)
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.Add(-time.Duration(offset) * day)
+}
+
+// Maintain creates upcoming partitions and enforces the retention policy.
+// Expired partitions are archived (if configured) and dropped whole; rows
+// that ended up in the default partition expire individually. Minute and hour
+// rollups past their own retention are pruned as well, and so are fired
+// alerts older than any dedup window.
+func (s *PostgresStore) Maintain(ctx context.Context, policy RetentionPolicy, now time.Time) (MaintenanceReport, error) {
+	var report MaintenanceReport
+
+	if err := s.pruneRollups(ctx, now); err != nil {
+		return report, err
+	}
+	if err := s.deleteExpiredAlerts(ctx, now); err != nil {
+		return report, err
+	}
+
+	existing, err := s.listPartitions(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, p := range planPartitions(existing, policy, now) {
+		if err := s.createPartition(ctx, p); err != nil {
+			return report, err
+		}
+		report.PartitionsCreated = append(report.PartitionsCreated, p.name)
+	}
+
+	cutoff := policy.cutoff(now)
+	if cutoff.IsZero() {
+		return report, nil
+	}
+
+	for _, p := range existing {
+		if p.end.After(cutoff) {
+			continue
+		}
+
+		count := 0
+		if policy.ArchiveDir != "" {
+			path, archived, err := s.archivePosts(ctx, s.db, policy.ArchiveDir, p.name,
+				"SELECT "+s.dialect.postColumns+" FROM "+p.name)
+			if err != nil {
+				return report, err
+			}
+			if path != "" {
+				report.ArchiveFiles = append(report.ArchiveFiles, path)
+			}
+			count = archived
+		} else if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+p.name).Scan(&count); err != nil {
+			return report, fmt.Errorf("failed to count posts in %s: %w", p.name, err)
+		}
+		report.PostsExpired += count
+
+		if _, err := s.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+p.name); err != nil {
+			return report, fmt.Errorf("failed to drop partition %s: %w", p.name, err)
+		}
+		report.PartitionsDropped = append(report.PartitionsDropped, p.name)
+	}
+
+	expired, path, err := s.expirePosts(ctx, defaultPartition, policy.ArchiveDir, cutoff)
+	if err != nil {
+		return report, err
+	}
+	if err := s.deleteOrphanEntities(ctx); err != nil {
+		return report, err
+	}
+	if err := s.deleteOrphanStories(ctx); err != nil {
+		return report, err
+	}
+	report.PostsExpired += expired
+	if path != "" {
+		report.ArchiveFiles = append(report.ArchiveFiles, path)
+	}
+
+	return report, nil
+}
+
+// listPartitions returns the dated partitions currently attached to reddit_posts
+func (s *PostgresStore) listPartitions(ctx context.Context) ([]partition, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'reddit_posts'::regclass
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []partition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to list partitions: %w", err)
+		}
+		if p, ok := parsePartition(name); ok {
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions, rows.Err()
+}
+
+// createPartition adds a partition, first moving any rows in its range out of
+// the default partition, which would otherwise make the new range invalid
+func (s *PostgresStore) createPartition(ctx context.Context, p partition) error {
+	// Generated columns such as search_vector can't be copied explicitly
+	var columns string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position)
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'reddit_posts' AND is_generated = 'NEVER'
+	`).Scan(&columns)
+	if err != nil {
+		return fmt.Errorf("failed to read reddit_posts columns: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin partition transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// DDL can't take bind parameters; the bounds are generated, not user input
+	start, end := p.start.Format(time.RFC3339), p.end.Format(time.RFC3339)
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TEMP TABLE reddit_posts_moving ON COMMIT DROP AS
+			SELECT %[1]s FROM %[2]s WHERE created_at >= '%[3]s' AND created_at < '%[4]s';
+		DELETE FROM %[2]s WHERE created_at >= '%[3]s' AND created_at < '%[4]s';
+		CREATE TABLE %[5]s PARTITION OF reddit_posts FOR VALUES FROM ('%[3]s') TO ('%[4]s');
+		INSERT INTO reddit_posts (%[1]s) SELECT %[1]s FROM reddit_posts_moving;
+	`, columns, defaultPartition, start, end, p.name))
+	if err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", p.name, err)
+	}
+
+	return tx.Commit()
+}
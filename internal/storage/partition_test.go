@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func partitionNames(partitions []partition) []string {
+	names := make([]string, len(partitions))
+	for i, p := range partitions {
+		names[i] = p.name
+	}
+	return names
+}
+
+func TestParsePartition(t *testing.T) {
+	p, ok := parsePartition("reddit_posts_w20240429")
+	if !ok {
+		t.Fatal("Expected weekly partition name to parse")
+	}
+	if !p.start.Equal(time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)) || !p.end.Equal(time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected range %v - %v", p.start, p.end)
+	}
+
+	for _, name := range []string{"reddit_posts_default", "reddit_posts_x20240429", "reddit_posts_d2024", "other_d20240429"} {
+		if _, ok := parsePartition(name); ok {
+			t.Errorf("Expected %q not to parse", name)
+		}
+	}
+}
+
+func TestPlanPartitionsDaily(t *testing.T) {
+	now := time.Date(2024, 5, 1, 15, 30, 0, 0, time.UTC)
+	existing := []partition{newPartition(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), PartitionDaily)}
+
+	planned := planPartitions(existing, RetentionPolicy{Interval: PartitionDaily, Premake: 2}, now)
+
+	got := partitionNames(planned)
+	want := []string{"reddit_posts_d20240501", "reddit_posts_d20240503"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestPlanPartitionsWeekly(t *testing.T) {
+	// Wednesday; the current week starts Monday 2024-04-29
+	now := time.Date(2024, 5, 1, 15, 30, 0, 0, time.UTC)
+
+	planned := planPartitions(nil, RetentionPolicy{Interval: PartitionWeekly, Premake: 1}, now)
+	got := partitionNames(planned)
+	if len(got) != 2 || got[0] != "reddit_posts_w20240429" || got[1] != "reddit_posts_w20240506" {
+		t.Errorf("Unexpected weekly plan: %v", got)
+	}
+
+	// Switching from daily to weekly fills the rest of a partly covered week with days
+	existing := []partition{
+		newPartition(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), PartitionDaily),
+		newPartition(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), PartitionDaily),
+	}
+	planned = planPartitions(existing, RetentionPolicy{Interval: PartitionWeekly, Premake: 1}, now)
+	got = partitionNames(planned)
+	want := []string{
+		"reddit_posts_d20240503",
+		"reddit_posts_d20240504",
+		"reddit_posts_d20240505",
+		"reddit_posts_w20240506",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	all := append(existing, planned...)
+	for i := range all {
+		for j := i + 1; j < len(all); j++ {
+			if all[i].overlaps(all[j]) {
+				t.Errorf("Partitions %s and %s overlap", all[i].name, all[j].name)
+			}
+		}
+	}
+}
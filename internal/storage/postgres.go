@@ -5,16 +5,33 @@ import (
 	"database/sql"
 	"fmt"
 	"goreddit/internal/config"
-	"goreddit/internal/reddit"
 
 	_ "github.com/lib/pq"
 )
 
+// PostgresStore is the production Store backed by PostgreSQL
 type PostgresStore struct {
-	db *sql.DB
+	sqlStore
 }
 
+// NewPostgresStore connects to postgres and applies any pending schema migrations
 func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
+	db, err := OpenPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := newSQLStore(db, postgresDialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{sqlStore: base}, nil
+}
+
+// OpenPostgres opens and pings a postgres connection without touching the schema
+func OpenPostgres(cfg *config.Config) (*sql.DB, error) {
 	connStr := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Postgres.Host,
@@ -31,40 +48,37 @@ func NewPostgresStore(cfg *config.Config) (*PostgresStore, error) {
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
 
-	return &PostgresStore{db: db}, nil
+	return db, nil
 }
 
-func (s *PostgresStore) SavePost(ctx context.Context, post reddit.Post) error {
-	/*query := `
-		INSERT INTO reddit_posts (
-			id, title, body, subreddit, score, url, created_at, sentiment
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (id) DO UPDATE SET
-			score = EXCLUDED.score,
-			sentiment = EXCLUDED.sentiment
-	`
-
-	_, err := s.db.ExecContext(ctx, query,
-		post.ID,
-		post.Title,
-		post.Body,
-		post.Subreddit,
-		post.Score,
-		post.URL,
-		post.CreatedAt,
-		post.Sentiment,
-	)
-
+// SearchPosts ranks posts against the query using the search_vector column.
+// Headlines are only generated for the page being returned.
+func (s *PostgresStore) SearchPosts(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	q, terms, err := q.normalize()
 	if err != nil {
-		return fmt.Errorf("failed to save post: %w", err)
+		return nil, err
 	}
-	*/
-	return nil
-}
 
-func (s *PostgresStore) Close() error {
-	return s.db.Close()
+	b := &queryBuilder{}
+	tsq := "to_tsquery('english', " + b.arg(tsquery(terms)) + ")"
+	b.where("search_vector @@ " + tsq)
+	addFilterConds(s.dialect, b, q.Filter)
+
+	headlineOpts := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MinWords=8, MaxWords=24", HighlightStart, HighlightStop)
+	query := fmt.Sprintf(`
+		SELECT %s, rank, ts_headline('english', title || ' ' || COALESCE(body, ''), %s, %s)
+		FROM (
+			SELECT *, ts_rank_cd(search_vector, %s) AS rank
+			FROM reddit_posts%s
+			ORDER BY rank DESC, id
+			LIMIT %s OFFSET %s
+		) matches
+		ORDER BY rank DESC, id`,
+		s.dialect.postColumns, tsq, b.arg(headlineOpts), tsq, b.whereClause(), b.arg(q.Limit), b.arg(q.Offset))
+
+	return s.searchRows(ctx, query, b.args)
 }
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 500
+)
+
+var (
+	// ErrNotFound is returned when a requested post does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidFilter is returned when a query filter or cursor can't be used
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// SortField selects the column posts are ordered by
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByScore     SortField = "score"
+	SortBySentiment SortField = "sentiment"
+)
+
+// sortExpr maps a sort field to the SQL expression used for ordering and
+// keyset comparisons. Sentiment can be NULL, so it's coalesced to neutral.
+var sortExpr = map[SortField]string{
+	SortByCreatedAt: "created_at",
+	SortByScore:     "score",
+	SortBySentiment: "COALESCE(sentiment, 0)",
+}
+
+// PostFilter selects posts for QueryPosts. Zero values mean "no constraint".
+type PostFilter struct {
+	Subreddits   []string
+	Since        time.Time // inclusive
+	Until        time.Time // exclusive
+	MinScore     *int32
+	MinSentiment *float64
+	MaxSentiment *float64
+	MinToxicity  *float64
+	Topic        string
+	Entity       string // normalized entity name, like "United States"
+	StoryID      string
+	Languages    []string // ISO 639-1 codes, like "en"
+	Domains      []string // registrable domains, like "bbc.co.uk"
+	Text         string   // case-insensitive substring of title or body
+
+	SortBy    SortField // defaults to SortByCreatedAt
+	Ascending bool      // defaults to newest/highest first
+	Limit     int       // defaults to 50, capped at 500
+	Cursor    string    // NextCursor from a previous page
+}
+
+// PostPage is one page of QueryPosts results
+type PostPage struct {
+	Posts []reddit.Post
+	// NextCursor continues the query after the last post, empty on the last page
+	NextCursor string
+}
+
+// cursor is the keyset position after the last row of a page
+type cursor struct {
+	Sort  SortField `json:"s"`
+	Value float64   `json:"v"`
+	ID    string    `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+	return c, nil
+}
+
+// queryBuilder accumulates WHERE conditions and their positional arguments
+type queryBuilder struct {
+	conds []string
+	args  []any
+}
+
+// arg binds a value and returns its placeholder
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *queryBuilder) where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// escapeLike escapes LIKE wildcards so text is matched literally
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// normalize applies defaults and validates the filter
+func (f PostFilter) normalize() (PostFilter, error) {
+	if f.SortBy == "" {
+		f.SortBy = SortByCreatedAt
+	}
+	if _, ok := sortExpr[f.SortBy]; !ok {
+		return f, fmt.Errorf("%w: unknown sort field %q", ErrInvalidFilter, f.SortBy)
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultQueryLimit
+	}
+	if f.Limit > maxQueryLimit {
+		f.Limit = maxQueryLimit
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Until.After(f.Since) {
+		return f, fmt.Errorf("%w: until must be after since", ErrInvalidFilter)
+	}
+	if f.MinSentiment != nil && f.MaxSentiment != nil && *f.MinSentiment > *f.MaxSentiment {
+		return f, fmt.Errorf("%w: min sentiment is above max sentiment", ErrInvalidFilter)
+	}
+	return f, nil
+}
+
+// buildPostQuery renders a normalized filter into a SELECT over reddit_posts.
+// It fetches one row past the limit so the caller can tell if there's a next page.
+func buildPostQuery(d dialect, f PostFilter) (string, []any, error) {
+	b := &queryBuilder{}
+	addFilterConds(d, b, f)
+
+	expr := sortExpr[f.SortBy]
+	op, dir := "<", "DESC"
+	if f.Ascending {
+		op, dir = ">", "ASC"
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		if c.Sort != f.SortBy {
+			return "", nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidFilter, c.Sort)
+		}
+		value := b.arg(c.Value)
+		if f.SortBy == SortByCreatedAt {
+			value = d.fromUnix(value)
+		}
+		b.where(fmt.Sprintf("(%s, id) %s (%s, %s)", expr, op, value, b.arg(c.ID)))
+	}
+
+	query := "SELECT " + d.postColumns + " FROM reddit_posts" + b.whereClause() +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", expr, dir, dir, b.arg(f.Limit+1))
+
+	return query, b.args, nil
+}
+
+// addFilterConds adds the filter's constraints on reddit_posts columns to b
+func addFilterConds(d dialect, b *queryBuilder, f PostFilter) {
+	if len(f.Subreddits) > 0 {
+		placeholders := make([]string, len(f.Subreddits))
+		for i, sub := range f.Subreddits {
+			placeholders[i] = b.arg(strings.ToLower(sub))
+		}
+		b.where(fmt.Sprintf("LOWER(subreddit) IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if !f.Since.IsZero() {
+		b.where("created_at >= " + d.fromUnix(b.arg(float64(f.Since.Unix()))))
+	}
+	if !f.Until.IsZero() {
+		b.where("created_at < " + d.fromUnix(b.arg(float64(f.Until.Unix()))))
+	}
+	if f.MinScore != nil {
+		b.where("score >= " + b.arg(*f.MinScore))
+	}
+	if f.MinSentiment != nil {
+		b.where("sentiment >= " + b.arg(*f.MinSentiment))
+	}
+	if f.MaxSentiment != nil {
+		b.where("sentiment <= " + b.arg(*f.MaxSentiment))
+	}
+	if f.MinToxicity != nil {
+		b.where("toxicity >= " + b.arg(*f.MinToxicity))
+	}
+	if f.Topic != "" {
+		b.where(d.hasTopic(b.arg(f.Topic)))
+	}
+	if f.Entity != "" {
+		b.where("reddit_posts.id IN (SELECT post_id FROM post_entities WHERE entity = " + b.arg(f.Entity) + ")")
+	}
+	if f.StoryID != "" {
+		b.where("story_id = " + b.arg(f.StoryID))
+	}
+	if len(f.Languages) > 0 {
+		placeholders := make([]string, len(f.Languages))
+		for i, lang := range f.Languages {
+			placeholders[i] = b.arg(strings.ToLower(lang))
+		}
+		b.where(fmt.Sprintf("language IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if len(f.Domains) > 0 {
+		placeholders := make([]string, len(f.Domains))
+		for i, domain := range f.Domains {
+			placeholders[i] = b.arg(strings.ToLower(domain))
+		}
+		b.where(fmt.Sprintf("domain IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if f.Text != "" {
+		pattern := b.arg("%" + escapeLike(f.Text) + "%")
+		b.where(fmt.Sprintf(`(title %[1]s %[2]s ESCAPE '\' OR body %[1]s %[2]s ESCAPE '\')`, d.like, pattern))
+	}
+}
+
+// CursorAfter returns a cursor continuing a query sorted by sort after post,
+// e.g. to resume a feed after the last post a client was sent
+func CursorAfter(sort SortField, post reddit.Post) string {
+	return nextCursor(sort, post)
+}
+
+// nextCursor returns the cursor after the last post of a page
+func nextCursor(sort SortField, last reddit.Post) string {
+	c := cursor{Sort: sort, ID: last.ID}
+	switch sort {
+	case SortByScore:
+		c.Value = float64(last.Score)
+	case SortBySentiment:
+		c.Value = last.Sentiment
+	default:
+		c.Value = last.CreatedAt
+	}
+	return encodeCursor(c)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"goreddit/internal/reddit"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPostQuery(t *testing.T) {
+	minScore := int32(10)
+	minSentiment := 0.2
+	filter, err := PostFilter{
+		Subreddits:   []string{"WorldNews", "politics"},
+		Since:        time.Unix(1000, 0),
+		MinScore:     &minScore,
+		MinSentiment: &minSentiment,
+		Topic:        "Ukraine",
+		Text:         "100%_sure",
+		SortBy:       SortByScore,
+		Limit:        20,
+	}.normalize()
+	if err != nil {
+		t.Fatalf("Failed to normalize filter: %v", err)
+	}
+
+	query, args, err := buildPostQuery(postgresDialect, filter)
+	if err != nil {
+		t.Fatalf("Failed to build query: %v", err)
+	}
+
+	for _, want := range []string{
+		"LOWER(subreddit) IN ($1, $2)",
+		"created_at >= to_timestamp($3)",
+		"score >= $4",
+		"sentiment >= $5",
+		"$6 = ANY(topics)",
+		`(title ILIKE $7 ESCAPE '\' OR body ILIKE $7 ESCAPE '\')`,
+		"ORDER BY score DESC, id DESC LIMIT $8",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %q, got: %s", want, query)
+		}
+	}
+
+	if args[0] != "worldnews" {
+		t.Errorf("Expected subreddit to be lowercased, got %v", args[0])
+	}
+	if args[6] != `%100\%\_sure%` {
+		t.Errorf("Expected LIKE pattern to be escaped, got %v", args[6])
+	}
+	if args[7] != 21 {
+		t.Errorf("Expected limit+1 to be fetched, got %v", args[7])
+	}
+}
+
+func TestBuildPostQueryCursor(t *testing.T) {
+	last := reddit.Post{ID: "abc", Score: 42, CreatedAt: 1700000000}
+
+	filter, _ := PostFilter{SortBy: SortByScore, Ascending: true, Cursor: nextCursor(SortByScore, last)}.normalize()
+	query, args, err := buildPostQuery(postgresDialect, filter)
+	if err != nil {
+		t.Fatalf("Failed to build query: %v", err)
+	}
+	if !strings.Contains(query, "(score, id) > ($1, $2)") || !strings.Contains(query, "ORDER BY score ASC, id ASC") {
+		t.Errorf("Unexpected keyset query: %s", query)
+	}
+	if args[0] != float64(42) || args[1] != "abc" {
+		t.Errorf("Unexpected cursor args: %v", args)
+	}
+
+	// A cursor can't be reused with a different sort order
+	filter.SortBy = SortByCreatedAt
+	if _, _, err := buildPostQuery(postgresDialect, filter); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for mismatched cursor, got %v", err)
+	}
+
+	filter.Cursor = "not-a-cursor!"
+	if _, _, err := buildPostQuery(postgresDialect, filter); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for malformed cursor, got %v", err)
+	}
+}
+
+func TestPostFilterNormalize(t *testing.T) {
+	f, err := PostFilter{Limit: 10000}.normalize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.SortBy != SortByCreatedAt || f.Limit != maxQueryLimit {
+		t.Errorf("Unexpected defaults: %+v", f)
+	}
+
+	lo, hi := 0.5, -0.5
+	bad := []PostFilter{
+		{SortBy: "title"},
+		{Since: time.Unix(200, 0), Until: time.Unix(100, 0)},
+		{MinSentiment: &lo, MaxSentiment: &hi},
+	}
+	for _, f := range bad {
+		if _, err := f.normalize(); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for %+v, got %v", f, err)
+		}
+	}
+}
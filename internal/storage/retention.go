@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"goreddit/internal/config"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PartitionInterval is the time range covered by one reddit_posts partition
+type PartitionInterval string
+
+const (
+	PartitionDaily  PartitionInterval = "day"
+	PartitionWeekly PartitionInterval = "week"
+)
+
+const (
+	defaultPremakePartitions = 7
+	defaultMaintenanceEvery  = time.Hour
+)
+
+// RetentionPolicy controls partition creation and how long posts are kept
+type RetentionPolicy struct {
+	Interval PartitionInterval
+	// Premake is how many partitions past the current one exist at all times
+	Premake int
+	// KeepFor is how long posts are kept; zero keeps them forever
+	KeepFor time.Duration
+	// ArchiveDir, if set, receives a gzipped NDJSON file of expired posts
+	// before they're dropped
+	ArchiveDir string
+	// Every is how often RunMaintenance applies the policy
+	Every time.Duration
+}
+
+// MaintenanceReport describes what one maintenance pass changed
+type MaintenanceReport struct {
+	PartitionsCreated []string
+	PartitionsDropped []string
+	PostsExpired      int
+	ArchiveFiles      []string
+}
+
+// RetentionPolicyFromConfig reads the retention section, applying defaults
+func RetentionPolicyFromConfig(cfg *config.Config) (RetentionPolicy, error) {
+	r := cfg.Retention
+	policy := RetentionPolicy{
+		Interval:   PartitionInterval(r.PartitionInterval),
+		Premake:    r.PremakePartitions,
+		KeepFor:    time.Duration(r.KeepDays) * 24 * time.Hour,
+		ArchiveDir: r.ArchiveDir,
+		Every:      r.CheckInterval,
+	}
+
+	switch policy.Interval {
+	case "":
+		policy.Interval = PartitionDaily
+	case PartitionDaily, PartitionWeekly:
+	default:
+		return policy, fmt.Errorf("unknown retention.partition_interval %q", r.PartitionInterval)
+	}
+	if policy.Premake <= 0 {
+		policy.Premake = defaultPremakePartitions
+	}
+	if policy.KeepFor < 0 {
+		return policy, fmt.Errorf("retention.keep_days can't be negative")
+	}
+	if policy.Every <= 0 {
+		policy.Every = defaultMaintenanceEvery
+	}
+	return policy, nil
+}
+
+// cutoff returns the time before which posts have expired, or zero if posts
+// are kept forever
+func (p RetentionPolicy) cutoff(now time.Time) time.Time {
+	if p.KeepFor <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-p.KeepFor)
+}
+
+// RunMaintenance applies the retention policy immediately and then on every
+// tick until ctx is cancelled
+func RunMaintenance(ctx context.Context, store Store, policy RetentionPolicy) {
+	ticker := time.NewTicker(policy.Every)
+	defer ticker.Stop()
+
+	for {
+		report, err := store.Maintain(ctx, policy, time.Now())
+		if err != nil {
+			log.Printf("Storage maintenance failed: %v", err)
+		} else if len(report.PartitionsCreated) > 0 || len(report.PartitionsDropped) > 0 || report.PostsExpired > 0 {
+			log.Printf("Storage maintenance: created partitions %v, dropped partitions %v, expired %d posts, archived to %v",
+				report.PartitionsCreated, report.PartitionsDropped, report.PostsExpired, report.ArchiveFiles)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirePosts deletes the rows of table created before cutoff, archiving them
+// first if archiveDir is set. It returns the number of rows and the archive path.
+func (s *sqlStore) expirePosts(ctx context.Context, table, archiveDir string, cutoff time.Time) (int, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to begin expiry transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	b := &queryBuilder{}
+	b.where("created_at < " + s.dialect.fromUnix(b.arg(float64(cutoff.Unix()))))
+
+	path := ""
+	if archiveDir != "" {
+		name := fmt.Sprintf("%s_before_%s", table, cutoff.UTC().Format("20060102T150405Z"))
+		path, _, err = s.archivePosts(ctx, tx, archiveDir, name,
+			"SELECT "+s.dialect.postColumns+" FROM "+table+b.whereClause(), b.args...)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM "+table+b.whereClause(), b.args...)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to delete expired posts: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to delete expired posts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("failed to commit expired posts: %w", err)
+	}
+	return int(deleted), path, nil
+}
+
+// archivePosts writes the posts selected by query to a gzipped NDJSON file
+// named name in dir. The file is only renamed into place once complete, and
+// nothing is written if the query selects no posts.
+func (s *sqlStore) archivePosts(ctx context.Context, q querier, dir, name, query string, args ...any) (string, int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, name+".ndjson.gz")
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read posts to archive: %w", err)
+	}
+	defer rows.Close()
+
+	gz := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(gz)
+	count := 0
+	for rows.Next() {
+		post, err := s.scanPost(rows)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to scan post to archive: %w", err)
+		}
+		if err := enc.Encode(post); err != nil {
+			return "", 0, fmt.Errorf("failed to write archive: %w", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", 0, fmt.Errorf("failed to read posts to archive: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if count == 0 {
+		return "", 0, nil
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return path, count, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goreddit/internal/reddit"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Resolution is the bucket size of a rollup time series
+type Resolution string
+
+const (
+	ResolutionMinute Resolution = "minute"
+	ResolutionHour   Resolution = "hour"
+	ResolutionDay    Resolution = "day"
+)
+
+// resolutions lists every maintained resolution with its bucket size and how
+// long its rollups are kept; zero keeps them forever
+var resolutions = []struct {
+	name    Resolution
+	size    time.Duration
+	keepFor time.Duration
+}{
+	{ResolutionMinute, time.Minute, 7 * day},
+	{ResolutionHour, time.Hour, 90 * day},
+	{ResolutionDay, day, 0},
+}
+
+// maxSeriesPoints bounds how many buckets a single series query may span
+const maxSeriesPoints = 10000
+
+// SeriesQuery selects a rollup time series. Without a topic or entity the
+// series counts every post; without a subreddit it sums all subreddits.
+type SeriesQuery struct {
+	Resolution Resolution
+	Subreddit  string
+	Topic      string
+	// Entity and EntityType select an entity series instead of a topic one;
+	// EntityType may be left empty to match the name under any type
+	Entity     string
+	EntityType string
+	Since      time.Time // inclusive, required
+	Until      time.Time // exclusive, defaults to now
+}
+
+// emotionSumColumns are the subreddit_rollups columns summing the emotions of
+// posts, in the order of reddit.EmotionNames
+const emotionSumColumns = "anger_sum, anticipation_sum, disgust_sum, fear_sum, joy_sum, sadness_sum, surprise_sum, trust_sum"
+
+// SeriesPoint is one bucket of a time series
+type SeriesPoint struct {
+	Bucket       time.Time `json:"bucket"`
+	Posts        int       `json:"posts"`
+	AvgSentiment float64   `json:"avg_sentiment"` // of the posts whose sentiment was analyzed
+	// AvgEmotions, AvgToxicity and ToxicShare, the share of posts at or
+	// above reddit.ToxicThreshold, are of the posts that were scored and only
+	// set in subreddit series, without a topic or entity
+	AvgEmotions *reddit.Emotions `json:"avg_emotions,omitempty"`
+	AvgToxicity *float64         `json:"avg_toxicity,omitempty"`
+	ToxicShare  *float64         `json:"toxic_share,omitempty"`
+}
+
+// TopTopicsQuery selects the most frequent topics over a window
+type TopTopicsQuery struct {
+	Subreddit string
+	Since     time.Time // inclusive, required
+	Until     time.Time // exclusive, defaults to now
+	Limit     int       // defaults to 50, capped at 500
+}
+
+func bucketSize(r Resolution) (time.Duration, bool) {
+	for _, res := range resolutions {
+		if res.name == r {
+			return res.size, true
+		}
+	}
+	return 0, false
+}
+
+// window validates a since/until pair, defaulting until to now
+func window(since, until time.Time) (time.Time, time.Time, error) {
+	if since.IsZero() {
+		return since, until, fmt.Errorf("%w: since is required", ErrInvalidFilter)
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if !until.After(since) {
+		return since, until, fmt.Errorf("%w: until must be after since", ErrInvalidFilter)
+	}
+	return since, until, nil
+}
+
+// coarsestResolution picks the largest bucket size that still leaves a few
+// buckets in the window, or a larger one if rollups of that size starting at
+// since have been pruned by now; window edges are counted at bucket
+// granularity
+func coarsestResolution(since, until, now time.Time) Resolution {
+	var size time.Duration
+	switch span := until.Sub(since); {
+	case span <= 6*time.Hour:
+		size = time.Minute
+	case span <= 14*day:
+		size = time.Hour
+	default:
+		size = day
+	}
+	for _, res := range resolutions {
+		if res.size >= size && (res.keepFor == 0 || !since.Before(now.Add(-res.keepFor))) {
+			return res.name
+		}
+	}
+	return ResolutionDay
+}
+
+// rollupKey identifies one counter row touched by a post
+type rollupKey struct {
+	resolution Resolution
+	bucket     time.Time
+}
+
+// rollupBuckets returns the bucket of every resolution a post falls into
+func rollupBuckets(createdAt float64) []rollupKey {
+	t := time.Unix(int64(createdAt), 0).UTC()
+	keys := make([]rollupKey, len(resolutions))
+	for i, res := range resolutions {
+		keys[i] = rollupKey{res.name, t.Truncate(res.size)}
+	}
+	return keys
+}
+
+// uniqueTopics dedupes topics so a post counts once per topic
+func uniqueTopics(topics []string) []string {
+	seen := make(map[string]bool, len(topics))
+	unique := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if topic != "" && !seen[topic] {
+			seen[topic] = true
+			unique = append(unique, topic)
+		}
+	}
+	return unique
+}
+
+// sameRollups reports whether two versions of a post count identically
+func sameRollups(a, b reddit.Post) bool {
+	if a.Sentiment != b.Sentiment || a.Emotions != b.Emotions || a.Toxicity != b.Toxicity || a.CreatedAt != b.CreatedAt || !strings.EqualFold(a.Subreddit, b.Subreddit) ||
+		a.Domain != b.Domain || a.Unsupported != b.Unsupported || a.Unscored != b.Unscored {
+		return false
+	}
+	at, bt := uniqueTopics(a.Topics), uniqueTopics(b.Topics)
+	if len(at) != len(bt) {
+		return false
+	}
+	sort.Strings(at)
+	sort.Strings(bt)
+	for i := range at {
+		if at[i] != bt[i] {
+			return false
+		}
+	}
+
+	ae, be := uniqueEntities(a.Entities), uniqueEntities(b.Entities)
+	if len(ae) != len(be) {
+		return false
+	}
+	mentioned := make(map[reddit.Entity]bool, len(ae))
+	for _, entity := range ae {
+		mentioned[entity] = true
+	}
+	for _, entity := range be {
+		if !mentioned[entity] {
+			return false
+		}
+	}
+	return true
+}
+
+// analyzedCounts returns what a post adds (sign 1) or removes (sign -1) to
+// the counts of posts whose sentiment was analyzed and whose emotions and
+// toxicity were scored, and the post with the scores it lacks zeroed, so that
+// only the posts counted add to the sums averaged over them
+func analyzedCounts(post reddit.Post, sign int) (reddit.Post, int, int) {
+	analyzed, scored := sign, sign
+	if post.Unsupported {
+		post.Sentiment, analyzed = 0, 0
+	}
+	if post.Unscored {
+		post.Emotions, post.Toxicity, scored = reddit.Emotions{}, 0, 0
+	}
+	return post, analyzed, scored
+}
+
+// average divides a sum by a count, or returns 0 if nothing was counted
+func average(sum float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// applyRollups adds (sign 1) or removes (sign -1) a post's contribution to
+// every rollup it falls into
+func (s *sqlStore) applyRollups(ctx context.Context, tx *sql.Tx, post reddit.Post, sign int) error {
+	post, analyzed, scored := analyzedCounts(post, sign)
+	subreddit := strings.ToLower(post.Subreddit)
+	sentiment := float64(sign) * post.Sentiment
+	topics := uniqueTopics(post.Topics)
+	entities := uniqueEntities(post.Entities)
+
+	subredditQuery := fmt.Sprintf(`
+		INSERT INTO subreddit_rollups (
+			resolution, bucket, subreddit, post_count, analyzed_count, scored_count, sentiment_sum, toxicity_sum, toxic_count, %s
+		) VALUES ($1, %s, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (resolution, subreddit, bucket) DO UPDATE SET
+			post_count = subreddit_rollups.post_count + EXCLUDED.post_count,
+			analyzed_count = subreddit_rollups.analyzed_count + EXCLUDED.analyzed_count,
+			scored_count = subreddit_rollups.scored_count + EXCLUDED.scored_count,
+			sentiment_sum = subreddit_rollups.sentiment_sum + EXCLUDED.sentiment_sum,
+			toxicity_sum = subreddit_rollups.toxicity_sum + EXCLUDED.toxicity_sum,
+			toxic_count = subreddit_rollups.toxic_count + EXCLUDED.toxic_count,
+			anger_sum = subreddit_rollups.anger_sum + EXCLUDED.anger_sum,
+			anticipation_sum = subreddit_rollups.anticipation_sum + EXCLUDED.anticipation_sum,
+			disgust_sum = subreddit_rollups.disgust_sum + EXCLUDED.disgust_sum,
+			fear_sum = subreddit_rollups.fear_sum + EXCLUDED.fear_sum,
+			joy_sum = subreddit_rollups.joy_sum + EXCLUDED.joy_sum,
+			sadness_sum = subreddit_rollups.sadness_sum + EXCLUDED.sadness_sum,
+			surprise_sum = subreddit_rollups.surprise_sum + EXCLUDED.surprise_sum,
+			trust_sum = subreddit_rollups.trust_sum + EXCLUDED.trust_sum
+	`, emotionSumColumns, s.dialect.fromUnix("$2"))
+	toxic := 0
+	if post.Toxicity >= reddit.ToxicThreshold {
+		toxic = sign
+	}
+	emotions := make([]any, 0, len(reddit.EmotionNames))
+	for _, value := range post.Emotions.Fields() {
+		emotions = append(emotions, float64(sign)*(*value))
+	}
+
+	topicQuery := fmt.Sprintf(`
+		INSERT INTO topic_rollups (resolution, bucket, subreddit, topic, post_count, analyzed_count, sentiment_sum)
+		VALUES ($1, %s, $3, $4, $5, $6, $7)
+		ON CONFLICT (resolution, topic, subreddit, bucket) DO UPDATE SET
+			post_count = topic_rollups.post_count + EXCLUDED.post_count,
+			analyzed_count = topic_rollups.analyzed_count + EXCLUDED.analyzed_count,
+			sentiment_sum = topic_rollups.sentiment_sum + EXCLUDED.sentiment_sum
+	`, s.dialect.fromUnix("$2"))
+
+	entityQuery := fmt.Sprintf(`
+		INSERT INTO entity_rollups (resolution, bucket, subreddit, entity, type, post_count, analyzed_count, sentiment_sum)
+		VALUES ($1, %s, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (resolution, entity, type, subreddit, bucket) DO UPDATE SET
+			post_count = entity_rollups.post_count + EXCLUDED.post_count,
+			analyzed_count = entity_rollups.analyzed_count + EXCLUDED.analyzed_count,
+			sentiment_sum = entity_rollups.sentiment_sum + EXCLUDED.sentiment_sum
+	`, s.dialect.fromUnix("$2"))
+
+	for _, key := range rollupBuckets(post.CreatedAt) {
+		bucket := float64(key.bucket.Unix())
+		args := append([]any{key.resolution, bucket, subreddit, sign, analyzed, scored, sentiment, float64(sign) * post.Toxicity, toxic}, emotions...)
+		if _, err := tx.ExecContext(ctx, subredditQuery, args...); err != nil {
+			return fmt.Errorf("failed to update subreddit rollup: %w", err)
+		}
+		for _, topic := range topics {
+			if _, err := tx.ExecContext(ctx, topicQuery, key.resolution, bucket, subreddit, topic, sign, analyzed, sentiment); err != nil {
+				return fmt.Errorf("failed to update topic rollup: %w", err)
+			}
+		}
+		for _, entity := range entities {
+			if _, err := tx.ExecContext(ctx, entityQuery, key.resolution, bucket, subreddit, entity.Name, entity.Type, sign, analyzed, sentiment); err != nil {
+				return fmt.Errorf("failed to update entity rollup: %w", err)
+			}
+		}
+	}
+	return s.applyDomainRollups(ctx, tx, post, sign)
+}
+
+// TimeSeries returns post counts and average sentiment per bucket for a
+// subreddit, a topic or an entity, or a subreddit's share of a topic or
+// entity, and average emotions and toxicity for a subreddit. Averages leave
+// out posts the analyzers didn't support. Empty buckets are omitted.
+func (s *sqlStore) TimeSeries(ctx context.Context, q SeriesQuery) ([]SeriesPoint, error) {
+	size, ok := bucketSize(q.Resolution)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown resolution %q", ErrInvalidFilter, q.Resolution)
+	}
+	since, until, err := window(q.Since, q.Until)
+	if err != nil {
+		return nil, err
+	}
+	if until.Sub(since)/size > maxSeriesPoints {
+		return nil, fmt.Errorf("%w: window spans more than %d %s buckets", ErrInvalidFilter, maxSeriesPoints, q.Resolution)
+	}
+
+	if q.Topic != "" && q.Entity != "" {
+		return nil, fmt.Errorf("%w: a series is for a topic or an entity, not both", ErrInvalidFilter)
+	}
+	table := "subreddit_rollups"
+	switch {
+	case q.Topic != "":
+		table = "topic_rollups"
+	case q.Entity != "":
+		table = "entity_rollups"
+	}
+
+	b := &queryBuilder{}
+	b.where("resolution = " + b.arg(q.Resolution))
+	b.where("bucket >= " + s.dialect.fromUnix(b.arg(float64(since.UTC().Truncate(size).Unix()))))
+	b.where("bucket < " + s.dialect.fromUnix(b.arg(float64(until.Unix()))))
+	if q.Subreddit != "" {
+		b.where("subreddit = " + b.arg(strings.ToLower(q.Subreddit)))
+	}
+	if q.Topic != "" {
+		b.where("topic = " + b.arg(q.Topic))
+	}
+	if q.Entity != "" {
+		b.where("entity = " + b.arg(q.Entity))
+		if q.EntityType != "" {
+			b.where("type = " + b.arg(q.EntityType))
+		}
+	}
+
+	subredditSums := ""
+	if table == "subreddit_rollups" {
+		subredditSums = ", SUM(scored_count), SUM(toxicity_sum), SUM(toxic_count)"
+		for _, column := range strings.Split(emotionSumColumns, ", ") {
+			subredditSums += ", SUM(" + column + ")"
+		}
+	}
+	query := fmt.Sprintf(`
+		SELECT %s, SUM(post_count), SUM(analyzed_count), SUM(sentiment_sum)%s
+		FROM %s%s
+		GROUP BY bucket
+		HAVING SUM(post_count) > 0
+		ORDER BY bucket`, s.dialect.toUnix("bucket"), subredditSums, table, b.whereClause())
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time series: %w", err)
+	}
+	defer rows.Close()
+
+	points := []SeriesPoint{}
+	for rows.Next() {
+		var (
+			bucket       float64
+			point        SeriesPoint
+			sentimentSum float64
+			toxicitySum  float64
+			analyzed     int
+			scored       int
+			toxicCount   int
+		)
+		dest := []any{&bucket, &point.Posts, &analyzed, &sentimentSum}
+		if subredditSums != "" {
+			dest = append(dest, &scored, &toxicitySum, &toxicCount)
+			point.AvgEmotions = &reddit.Emotions{}
+			for _, value := range point.AvgEmotions.Fields() {
+				dest = append(dest, value)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan time series: %w", err)
+		}
+		point.Bucket = time.Unix(int64(bucket), 0).UTC()
+		point.AvgSentiment = average(sentimentSum, analyzed)
+		if subredditSums != "" {
+			for _, value := range point.AvgEmotions.Fields() {
+				*value = average(*value, scored)
+			}
+			avg, share := average(toxicitySum, scored), average(float64(toxicCount), scored)
+			point.AvgToxicity, point.ToxicShare = &avg, &share
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// TopTopics returns the most frequent topics in the window, most frequent first
+func (s *sqlStore) TopTopics(ctx context.Context, q TopTopicsQuery) ([]reddit.TopicCount, error) {
+	b := &queryBuilder{}
+	limit, err := s.rollupWindow(b, q.Subreddit, q.Since, q.Until, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT topic, SUM(post_count) AS total
+		FROM topic_rollups%s
+		GROUP BY topic
+		HAVING SUM(post_count) > 0
+		ORDER BY total DESC, topic
+		LIMIT %s`, b.whereClause(), b.arg(limit))
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top topics: %w", err)
+	}
+	defer rows.Close()
+
+	topics := []reddit.TopicCount{}
+	for rows.Next() {
+		var tc reddit.TopicCount
+		if err := rows.Scan(&tc.Topic, &tc.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan top topics: %w", err)
+		}
+		topics = append(topics, tc)
+	}
+	return topics, rows.Err()
+}
+
+// rollupWindow adds the conditions selecting a window of rollups, at the
+// coarsest resolution that suits its length and is still kept for its start,
+// and returns the normalized limit
+func (s *sqlStore) rollupWindow(b *queryBuilder, subreddit string, since, until time.Time, limit int) (int, error) {
+	since, until, err := window(since, until)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	resolution := coarsestResolution(since, until, time.Now())
+	size, _ := bucketSize(resolution)
+
+	b.where("resolution = " + b.arg(resolution))
+	b.where("bucket >= " + s.dialect.fromUnix(b.arg(float64(since.UTC().Truncate(size).Unix()))))
+	b.where("bucket < " + s.dialect.fromUnix(b.arg(float64(until.Unix()))))
+	if subreddit != "" {
+		b.where("subreddit = " + b.arg(strings.ToLower(subreddit)))
+	}
+	return limit, nil
+}
+
+// pruneRollups deletes fine-grained rollups older than their resolution keeps
+func (s *sqlStore) pruneRollups(ctx context.Context, now time.Time) error {
+	for _, res := range resolutions {
+		if res.keepFor == 0 {
+			continue
+		}
+		cutoff := float64(now.Add(-res.keepFor).Unix())
+		for _, table := range []string{"subreddit_rollups", "topic_rollups", "entity_rollups", "domain_rollups"} {
+			query := fmt.Sprintf("DELETE FROM %s WHERE resolution = $1 AND bucket < %s", table, s.dialect.fromUnix("$2"))
+			if _, err := s.db.ExecContext(ctx, query, res.name, cutoff); err != nil {
+				return fmt.Errorf("failed to prune %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
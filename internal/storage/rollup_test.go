@@ -0,0 +1,414 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"goreddit/internal/reddit"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRollupBuckets(t *testing.T) {
+	// 2024-05-01T10:31:45Z
+	keys := rollupBuckets(1714559505)
+	want := map[Resolution]string{
+		ResolutionMinute: "2024-05-01T10:31:00Z",
+		ResolutionHour:   "2024-05-01T10:00:00Z",
+		ResolutionDay:    "2024-05-01T00:00:00Z",
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("Expected %d buckets, got %d", len(want), len(keys))
+	}
+	for _, key := range keys {
+		if got := key.bucket.Format(time.RFC3339); got != want[key.resolution] {
+			t.Errorf("%s bucket: expected %s, got %s", key.resolution, want[key.resolution], got)
+		}
+	}
+}
+
+func TestSameRollups(t *testing.T) {
+	post := reddit.Post{Subreddit: "news", CreatedAt: 100, Sentiment: 1, Topics: []string{"Tax", "Budget"}}
+
+	tests := []struct {
+		name   string
+		change func(p *reddit.Post)
+		same   bool
+	}{
+		{"score only", func(p *reddit.Post) { p.Score = 50 }, true},
+		{"topics reordered and repeated", func(p *reddit.Post) { p.Topics = []string{"Budget", "Tax", "Tax"} }, true},
+		{"subreddit case", func(p *reddit.Post) { p.Subreddit = "News" }, true},
+		{"sentiment", func(p *reddit.Post) { p.Sentiment = -1 }, false},
+		{"emotions", func(p *reddit.Post) { p.Emotions.Fear = 1 }, false},
+		{"toxicity", func(p *reddit.Post) { p.Toxicity = 0.7 }, false},
+		{"unsupported", func(p *reddit.Post) { p.Unsupported, p.Unscored = true, true }, false},
+		{"topic added", func(p *reddit.Post) { p.Topics = []string{"Tax", "Budget", "Senate"} }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := post
+			tt.change(&changed)
+			if got := sameRollups(post, changed); got != tt.same {
+				t.Errorf("Expected %v, got %v", tt.same, got)
+			}
+		})
+	}
+}
+
+func TestSQLiteStoreTimeSeries(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) float64 { return float64(base.Add(d).Unix()) }
+
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "WorldNews", CreatedAt: at(5 * time.Minute), Sentiment: 1, Topics: []string{"Ukraine", "Ukraine"},
+			Emotions: reddit.Emotions{Joy: 0.5, Trust: 0.5}, Toxicity: 0.2},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", CreatedAt: at(50 * time.Minute), Sentiment: -1, Topics: []string{"Ukraine", "Energy"},
+			Emotions: reddit.Emotions{Fear: 1}, Toxicity: 0.9},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "worldnews", CreatedAt: at(70 * time.Minute), Sentiment: 1, Topics: []string{"Energy"}},
+		reddit.Post{ID: "d", Title: "D", Subreddit: "news", CreatedAt: at(80 * time.Minute), Sentiment: -1, Topics: []string{"Energy"}},
+	)
+
+	series, err := store.TimeSeries(ctx, SeriesQuery{
+		Resolution: ResolutionHour,
+		Subreddit:  "worldnews",
+		Since:      base,
+		Until:      base.Add(3 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(series) != 2 || series[0].Posts != 2 || series[0].AvgSentiment != 0 || series[1].Posts != 1 || series[1].AvgSentiment != 1 {
+		t.Fatalf("Unexpected subreddit series: %+v", series)
+	}
+	if !series[1].Bucket.Equal(base.Add(time.Hour)) {
+		t.Errorf("Expected second bucket at %v, got %v", base.Add(time.Hour), series[1].Bucket)
+	}
+	if e := series[0].AvgEmotions; e == nil || e.Joy != 0.25 || e.Trust != 0.25 || e.Fear != 0.5 || e.Anger != 0 {
+		t.Errorf("Unexpected average emotions: %+v", e)
+	}
+	if avg, share := series[0].AvgToxicity, series[0].ToxicShare; avg == nil || math.Abs(*avg-0.55) > 1e-9 || share == nil || *share != 0.5 {
+		t.Errorf("Unexpected toxicity: %v, %v", avg, share)
+	}
+
+	// A topic series across all subreddits counts each post once
+	series, err = store.TimeSeries(ctx, SeriesQuery{
+		Resolution: ResolutionDay,
+		Topic:      "Energy",
+		Since:      base,
+		Until:      base.Add(day),
+	})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(series) != 1 || series[0].Posts != 3 || math.Abs(series[0].AvgSentiment+1.0/3) > 1e-9 || series[0].AvgEmotions != nil {
+		t.Fatalf("Unexpected topic series: %+v", series)
+	}
+
+	// Re-saving a post moves its contribution instead of adding to it
+	seedPosts(t, store,
+		reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", CreatedAt: at(50 * time.Minute), Sentiment: 1, Topics: []string{"Ukraine"}},
+	)
+	series, err = store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionMinute, Topic: "Ukraine", Since: base, Until: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(series) != 2 || series[1].Posts != 1 || series[1].AvgSentiment != 1 {
+		t.Fatalf("Unexpected series after update: %+v", series)
+	}
+	series, err = store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionMinute, Topic: "Energy", Since: base, Until: base.Add(time.Hour)})
+	if err != nil || len(series) != 0 {
+		t.Fatalf("Expected the removed topic to leave an empty series, got %+v (%v)", series, err)
+	}
+
+	for _, q := range []SeriesQuery{
+		{Resolution: "week", Since: base},
+		{Resolution: ResolutionHour},
+		{Resolution: ResolutionHour, Since: base, Until: base.Add(-time.Hour)},
+		{Resolution: ResolutionMinute, Since: base, Until: base.Add(30 * day)},
+	} {
+		if _, err := store.TimeSeries(ctx, q); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for %+v, got %v", q, err)
+		}
+	}
+}
+
+func TestSQLiteStoreTopTopics(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	// Recent enough that every resolution is still kept
+	base := time.Now().UTC().Truncate(day).Add(-40 * day)
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "news", CreatedAt: float64(base.Unix()), Topics: []string{"Energy", "Tax"}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", CreatedAt: float64(base.Add(2 * day).Unix()), Topics: []string{"Energy"}},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "politics", CreatedAt: float64(base.Add(20 * day).Unix()), Topics: []string{"Tax"}},
+	)
+
+	topics, err := store.TopTopics(ctx, TopTopicsQuery{Since: base, Until: base.Add(30 * day)})
+	if err != nil {
+		t.Fatalf("TopTopics failed: %v", err)
+	}
+	if len(topics) != 2 || topics[0].Topic != "Energy" || topics[0].Count != 2 || topics[1].Topic != "Tax" || topics[1].Count != 2 {
+		t.Errorf("Unexpected topics: %+v", topics)
+	}
+
+	topics, err = store.TopTopics(ctx, TopTopicsQuery{Subreddit: "News", Since: base.Add(time.Hour), Until: base.Add(3 * day), Limit: 1})
+	if err != nil {
+		t.Fatalf("TopTopics failed: %v", err)
+	}
+	if len(topics) != 1 || topics[0].Topic != "Energy" || topics[0].Count != 1 {
+		t.Errorf("Unexpected topics for a narrow window: %+v", topics)
+	}
+}
+
+func TestSQLiteStorePrunesRollups(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	seedPosts(t, store, reddit.Post{ID: "a", Title: "A", Subreddit: "news", CreatedAt: float64(created.Unix()), Topics: []string{"Tax"}})
+
+	if _, err := store.Maintain(ctx, RetentionPolicy{}, created.Add(30*day)); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+
+	for _, res := range []Resolution{ResolutionMinute, ResolutionHour, ResolutionDay} {
+		series, err := store.TimeSeries(ctx, SeriesQuery{Resolution: res, Topic: "Tax", Since: created.Add(-time.Hour), Until: created.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TimeSeries failed: %v", err)
+		}
+		kept := res != ResolutionMinute
+		if (len(series) == 1) != kept {
+			t.Errorf("%s rollups: expected kept=%v, got %+v", res, kept, series)
+		}
+	}
+}
+
+func TestSQLiteStoreTopTopicsAfterPruning(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	month := now.Truncate(day).Add(-30*day + 12*time.Hour)
+	quarter := now.Truncate(day).Add(-100*day + 12*time.Hour)
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "news", CreatedAt: float64(month.Unix()), Topics: []string{"Tax"}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", CreatedAt: float64(quarter.Unix()), Topics: []string{"Energy"}},
+	)
+	if _, err := store.Maintain(ctx, RetentionPolicy{}, now); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+
+	// Short windows whose minute or hour rollups are gone are read from
+	// coarser ones
+	for _, c := range []struct {
+		at    time.Time
+		topic string
+	}{
+		{month, "Tax"},
+		{quarter, "Energy"},
+	} {
+		topics, err := store.TopTopics(ctx, TopTopicsQuery{Since: c.at.Add(-3 * time.Hour), Until: c.at.Add(3 * time.Hour)})
+		if err != nil {
+			t.Fatalf("TopTopics failed: %v", err)
+		}
+		if len(topics) != 1 || topics[0].Topic != c.topic || topics[0].Count != 1 {
+			t.Errorf("Expected %s in the window at %v, got %+v", c.topic, c.at, topics)
+		}
+	}
+}
+
+func TestCoarsestResolution(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		since time.Time
+		span  time.Duration
+		want  Resolution
+	}{
+		{"recent short window", now.Add(-time.Hour), time.Hour, ResolutionMinute},
+		{"recent week", now.Add(-7 * day), 7 * day, ResolutionHour},
+		{"recent month", now.Add(-30 * day), 30 * day, ResolutionDay},
+		{"short window past minute retention", now.Add(-30 * day), time.Hour, ResolutionHour},
+		{"short window past hour retention", now.Add(-100 * day), time.Hour, ResolutionDay},
+		{"week past hour retention", now.Add(-100 * day), 7 * day, ResolutionDay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coarsestResolution(tt.since, tt.since.Add(tt.span), now); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSQLiteRollupMigrationBackfills(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "News", CreatedAt: float64(created.Unix()), Sentiment: 1, Topics: []string{"Tax", "Tax"}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", CreatedAt: float64(created.Unix()) + 10, Sentiment: -0.5, Topics: []string{"Tax"}},
+	)
+	query := SeriesQuery{Resolution: ResolutionMinute, Topic: "Tax", Since: created.Add(-time.Hour), Until: created.Add(time.Hour)}
+	before, err := store.TimeSeries(ctx, query)
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+
+	// Rolling the rollups back and forth rebuilds them from the stored posts
+	migrator, err := NewMigrator(store.db, DriverSQLite)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	// 0003_add_rollups is the first migration to roll back
+	if _, err := migrator.Down(ctx, version-2); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	after, err := store.TimeSeries(ctx, query)
+	if err != nil {
+		t.Fatalf("TimeSeries failed: %v", err)
+	}
+	if len(before) != 1 || len(after) != 1 || before[0] != after[0] || after[0].Posts != 2 {
+		t.Errorf("Expected backfilled series %+v, got %+v", before, after)
+	}
+}
+
+func TestSQLiteStoreAveragesAnalyzedPosts(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := float64(base.Unix())
+	german := reddit.Post{ID: "c", Title: "Steuern", Subreddit: "news", URL: "https://spiegel.de/a", Domain: "spiegel.de", CreatedAt: at + 20,
+		Topics: []string{"Tax"}, Language: "de", Unsupported: true, Unscored: true}
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "news", URL: "https://spiegel.de/b", Domain: "spiegel.de", CreatedAt: at, Sentiment: 0.8,
+			Toxicity: 0.6, Emotions: reddit.Emotions{Joy: 1}, Topics: []string{"Tax"}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", CreatedAt: at + 10, Sentiment: -0.4, Toxicity: 0.2, Topics: []string{"Tax"}},
+		german,
+	)
+
+	// The unsupported post counts, but its placeholder scores don't
+	check := func(wantSentiment, wantToxicity, wantJoy, wantDomainSentiment float64) {
+		t.Helper()
+		series, err := store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionHour, Subreddit: "news", Since: base, Until: base.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TimeSeries failed: %v", err)
+		}
+		if len(series) != 1 || series[0].Posts != 3 || math.Abs(series[0].AvgSentiment-wantSentiment) > 1e-9 ||
+			math.Abs(*series[0].AvgToxicity-wantToxicity) > 1e-9 || math.Abs(series[0].AvgEmotions.Joy-wantJoy) > 1e-9 {
+			t.Errorf("Unexpected series %+v", series)
+		}
+		topics, err := store.TimeSeries(ctx, SeriesQuery{Resolution: ResolutionHour, Topic: "Tax", Since: base, Until: base.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TimeSeries failed: %v", err)
+		}
+		if len(topics) != 1 || math.Abs(topics[0].AvgSentiment-wantSentiment) > 1e-9 {
+			t.Errorf("Unexpected topic series %+v", topics)
+		}
+		subs, err := store.TopSubreddits(ctx, TopSubredditsQuery{Since: base, Until: base.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TopSubreddits failed: %v", err)
+		}
+		if len(subs) != 1 || subs[0].Posts != 3 || math.Abs(subs[0].AvgSentiment-wantSentiment) > 1e-9 || math.Abs(subs[0].AvgToxicity-wantToxicity) > 1e-9 {
+			t.Errorf("Unexpected subreddits %+v", subs)
+		}
+		domains, err := store.TopDomains(ctx, TopDomainsQuery{Since: base, Until: base.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TopDomains failed: %v", err)
+		}
+		if len(domains) != 1 || domains[0].Posts != 2 || math.Abs(domains[0].AvgSentiment-wantDomainSentiment) > 1e-9 {
+			t.Errorf("Unexpected domains %+v", domains)
+		}
+	}
+	check(0.2, 0.4, 0.5, 0.8)
+
+	// Once analyzed, it counts toward the averages too
+	german.Unsupported, german.Unscored, german.Sentiment, german.Toxicity = false, false, 0.5, 0.7
+	if err := store.SavePost(ctx, german); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+	check(0.3, 0.5, 1.0/3, 0.65)
+}
+
+func TestSQLiteAnalyzedCountMigrationBackfills(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "news", Domain: "bbc.com", CreatedAt: float64(created.Unix()), Sentiment: 1, Toxicity: 0.6, Topics: []string{"Tax"}},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", Domain: "bbc.com", CreatedAt: float64(created.Unix()) + 10, Sentiment: -0.5, Toxicity: 0.2, Topics: []string{"Tax"},
+			Entities: []reddit.Entity{{Name: "Senate", Type: "ORG"}}},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "news", Domain: "bbc.com", CreatedAt: float64(created.Unix()) + 20, Topics: []string{"Tax"},
+			Entities: []reddit.Entity{{Name: "Senate", Type: "ORG"}}, Language: "de", Unsupported: true, Unscored: true},
+	)
+	queries := []SeriesQuery{
+		{Resolution: ResolutionMinute, Subreddit: "news"},
+		{Resolution: ResolutionMinute, Topic: "Tax"},
+		{Resolution: ResolutionMinute, Entity: "Senate"},
+	}
+	series := func() [][]SeriesPoint {
+		t.Helper()
+		var all [][]SeriesPoint
+		for _, q := range queries {
+			q.Since, q.Until = created.Add(-time.Hour), created.Add(time.Hour)
+			points, err := store.TimeSeries(ctx, q)
+			if err != nil {
+				t.Fatalf("TimeSeries failed: %v", err)
+			}
+			all = append(all, points)
+		}
+		return all
+	}
+	domains := func() []DomainStats {
+		t.Helper()
+		stats, err := store.TopDomains(ctx, TopDomainsQuery{Since: created.Add(-time.Hour), Until: created.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("TopDomains failed: %v", err)
+		}
+		return stats
+	}
+	before, beforeDomains := series(), domains()
+
+	// Rolling back the analyzed counts and applying them again leaves the
+	// unsupported posts out of the averages as saving them did
+	migrator, err := NewMigrator(store.db, DriverSQLite)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read version: %v", err)
+	}
+	// 0016_add_rollup_analyzed_counts is the first migration to roll back
+	if _, err := migrator.Down(ctx, version-15); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	after, afterDomains := series(), domains()
+	if !reflect.DeepEqual(before, after) || !reflect.DeepEqual(beforeDomains, afterDomains) {
+		t.Errorf("Expected backfilled series %+v and domains %+v, got %+v and %+v", before, beforeDomains, after, afterDomains)
+	}
+	if len(after[2]) != 1 || after[2][0].Posts != 2 || after[2][0].AvgSentiment != -0.5 {
+		t.Errorf("Unexpected entity series %+v", after[2])
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"fmt"
+	"goreddit/internal/reddit"
+	"strings"
+	"unicode"
+)
+
+// Snippet highlights wrap matched words in these markers
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// SearchQuery is a ranked full-text search over post titles and bodies.
+//
+// Text supports plain words (all must match), "exact phrases", prefix*
+// matches and -excluded words or phrases.
+type SearchQuery struct {
+	Text string
+	// Filter narrows the matches; its sort order and cursor are ignored
+	Filter PostFilter
+	Limit  int // defaults to 50, capped at 500
+	Offset int
+}
+
+// SearchResult is a matching post with its relevance and a highlighted excerpt
+type SearchResult struct {
+	Post    reddit.Post `json:"post"`
+	Rank    float64     `json:"rank"`
+	Snippet string      `json:"snippet"`
+}
+
+// searchTerm is one unit of a parsed search query
+type searchTerm struct {
+	words   []string // more than one word is an exact phrase
+	prefix  bool     // the last word matches as a prefix
+	exclude bool
+}
+
+// parseSearchQuery splits the query syntax into terms. Words are lowercased
+// and reduced to letters and digits, so the terms can be rendered for either
+// database without escaping.
+func parseSearchQuery(text string) ([]searchTerm, error) {
+	var terms []searchTerm
+	positive := false
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		exclude := false
+		if runes[i] == '-' {
+			exclude = true
+			i++
+		}
+
+		// Take a quoted phrase or a single whitespace-delimited word
+		var raw string
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		term := searchTerm{
+			words:   searchWords(raw),
+			prefix:  strings.HasSuffix(raw, "*"),
+			exclude: exclude,
+		}
+		if len(term.words) == 0 {
+			continue
+		}
+
+		terms = append(terms, term)
+		positive = positive || !exclude
+	}
+
+	if !positive {
+		return nil, fmt.Errorf("%w: search query needs at least one word to match", ErrInvalidFilter)
+	}
+	return terms, nil
+}
+
+// searchWords splits raw text into lowercase runs of letters and digits
+func searchWords(raw string) []string {
+	return strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tsquery renders terms in postgres to_tsquery syntax
+func tsquery(terms []searchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		words := append([]string(nil), term.words...)
+		if term.prefix {
+			words[len(words)-1] += ":*"
+		}
+
+		part := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			part = "(" + part + ")"
+		}
+		if term.exclude {
+			part = "!" + part
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " & ")
+}
+
+// fts5Query renders terms in SQLite FTS5 MATCH syntax. FTS5 only has a
+// binary NOT, so exclusions follow the required terms.
+func fts5Query(terms []searchTerm) string {
+	var include, exclude []string
+	for _, term := range terms {
+		part := `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			part += "*"
+		}
+		if term.exclude {
+			exclude = append(exclude, part)
+		} else {
+			include = append(include, part)
+		}
+	}
+
+	query := strings.Join(include, " AND ")
+	for _, part := range exclude {
+		query += " NOT " + part
+	}
+	return query
+}
+
+// normalize applies defaults and validates the query
+func (q SearchQuery) normalize() (SearchQuery, []searchTerm, error) {
+	terms, err := parseSearchQuery(q.Text)
+	if err != nil {
+		return q, nil, err
+	}
+
+	filter := q.Filter
+	filter.SortBy, filter.Cursor = "", ""
+	if q.Filter, err = filter.normalize(); err != nil {
+		return q, nil, err
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = defaultQueryLimit
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	if q.Offset < 0 {
+		return q, nil, fmt.Errorf("%w: offset can't be negative", ErrInvalidFilter)
+	}
+	return q, terms, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	cases := []struct {
+		text     string
+		tsquery  string
+		fts5     string
+		hasError bool
+	}{
+		{text: "ceasefire", tsquery: "ceasefire", fts5: `"ceasefire"`},
+		{text: "Gaza ceasefire", tsquery: "gaza & ceasefire", fts5: `"gaza" AND "ceasefire"`},
+		{text: `"white house" sanction*`, tsquery: "(white <-> house) & sanction:*", fts5: `"white house" AND "sanction"*`},
+		{text: `ukraine -"peace talks" -russia`, tsquery: "ukraine & !(peace <-> talks) & !russia", fts5: `"ukraine" NOT "peace talks" NOT "russia"`},
+		{text: "U.S. tariffs", tsquery: "(u <-> s) & tariffs", fts5: `"u s" AND "tariffs"`},
+		{text: "it's'); DROP TABLE", tsquery: "(it <-> s) & drop & table", fts5: `"it s" AND "drop" AND "table"`},
+		{text: "   ", hasError: true},
+		{text: "-russia", hasError: true},
+		{text: `"" * -`, hasError: true},
+	}
+
+	for _, tc := range cases {
+		terms, err := parseSearchQuery(tc.text)
+		if tc.hasError {
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("%q: expected ErrInvalidFilter, got %v", tc.text, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.text, err)
+			continue
+		}
+		if got := tsquery(terms); got != tc.tsquery {
+			t.Errorf("%q: expected tsquery %q, got %q", tc.text, tc.tsquery, got)
+		}
+		if got := fts5Query(terms); got != tc.fts5 {
+			t.Errorf("%q: expected fts5 query %q, got %q", tc.text, tc.fts5, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goreddit/internal/config"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const defaultSQLitePath = "data/goreddit.db"
+
+// SQLiteStore is a Store backed by an embedded SQLite file, for local runs
+// and tests that shouldn't need a Postgres server
+type SQLiteStore struct {
+	sqlStore
+}
+
+// NewSQLiteStore opens the configured SQLite file and applies any pending migrations
+func NewSQLiteStore(cfg *config.Config) (*SQLiteStore, error) {
+	db, err := OpenSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := newSQLStore(db, sqliteDialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{sqlStore: base}, nil
+}
+
+// OpenSQLite opens the configured SQLite file without touching the schema.
+// Transactions take the write lock up front (IMMEDIATE) so concurrent writers,
+// including migrations in other processes, queue on busy_timeout instead of
+// failing halfway through.
+func OpenSQLite(cfg *config.Config) (*sql.DB, error) {
+	path := cfg.SQLite.Path
+	if path == "" {
+		path = defaultSQLitePath
+	}
+
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create sqlite directory: %w", err)
+		}
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "foreign_keys(ON)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY churn
+	// and keeps ":memory:" databases from being split across connections
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping sqlite: %w", err)
+	}
+
+	return db, nil
+}
+
+// SearchPosts ranks posts against the query using the reddit_posts_fts index.
+// bm25 scores are negated so that, as in postgres, a higher rank is better.
+func (s *SQLiteStore) SearchPosts(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	q, terms, err := q.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &queryBuilder{}
+	match := b.arg(fts5Query(terms))
+	start, stop := b.arg(HighlightStart), b.arg(HighlightStop)
+	addFilterConds(s.dialect, b, q.Filter)
+
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT rowid,
+				-bm25(reddit_posts_fts, 10.0, 1.0) AS rank,
+				snippet(reddit_posts_fts, -1, %s, %s, '...', 24) AS snippet
+			FROM reddit_posts_fts
+			WHERE reddit_posts_fts MATCH %s
+		)
+		SELECT %s, matches.rank, matches.snippet
+		FROM reddit_posts JOIN matches ON matches.rowid = reddit_posts.rowid%s
+		ORDER BY matches.rank DESC, id
+		LIMIT %s OFFSET %s`,
+		start, stop, match, s.dialect.postColumns, b.whereClause(), b.arg(q.Limit), b.arg(q.Offset))
+
+	return s.searchRows(ctx, query, b.args)
+}
+
+// Maintain enforces the retention policy. SQLite has no partitions, so expired
+// posts are archived (if configured) and deleted row by row. Minute and hour
+// rollups past their own retention are pruned as well, and so are fired
+// alerts older than any dedup window.
+func (s *SQLiteStore) Maintain(ctx context.Context, policy RetentionPolicy, now time.Time) (MaintenanceReport, error) {
+	var report MaintenanceReport
+
+	if err := s.pruneRollups(ctx, now); err != nil {
+		return report, err
+	}
+	if err := s.deleteExpiredAlerts(ctx, now); err != nil {
+		return report, err
+	}
+
+	cutoff := policy.cutoff(now)
+	if cutoff.IsZero() {
+		return report, nil
+	}
+
+	expired, path, err := s.expirePosts(ctx, "reddit_posts", policy.ArchiveDir, cutoff)
+	if err != nil {
+		return report, err
+	}
+	if err := s.deleteOrphanEntities(ctx); err != nil {
+		return report, err
+	}
+	if err := s.deleteOrphanStories(ctx); err != nil {
+		return report, err
+	}
+	report.PostsExpired = expired
+	if path != "" {
+		report.ArchiveFiles = append(report.ArchiveFiles, path)
+	}
+	return report, nil
+}
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Storage.Driver = DriverSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "test.db")
+
+	store, err := NewSQLiteStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func seedPosts(t *testing.T, store Store, posts ...reddit.Post) {
+	t.Helper()
+	for _, post := range posts {
+		if err := store.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Failed to save post %s: %v", post.ID, err)
+		}
+	}
+}
+
+func postIDs(posts []reddit.Post) []string {
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+func TestSQLiteStoreSaveAndGet(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	post := reddit.Post{
+		ID:             "p1",
+		Title:          "Test Post",
+		Body:           "Test Body",
+		Summary:        "Test summary",
+		Subreddit:      "worldnews",
+		Score:          100,
+		URL:            "https://reddit.com/test",
+		CreatedAt:      1700000000,
+		Sentiment:      0.5,
+		SentimentModel: "bayes:imdb",
+		Emotions:       reddit.Emotions{Anticipation: 0.25, Trust: 0.75},
+		Topics:         []string{"Ukraine", "Ceasefire"},
+		Language:       "en",
+	}
+	seedPosts(t, store, post)
+
+	// Updating the same post keeps a single row with the new score
+	post.Score = 200
+	seedPosts(t, store, post)
+
+	got, err := store.GetPost(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to get post: %v", err)
+	}
+	if got.Score != 200 || got.Title != post.Title || len(got.Topics) != 2 || got.Topics[1] != "Ceasefire" || got.Summary != post.Summary || got.SentimentModel != post.SentimentModel || got.Emotions != post.Emotions || got.Language != "en" || got.Unsupported {
+		t.Errorf("Unexpected post: %+v", got)
+	}
+
+	if _, err := store.GetPost(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteStoreQueryPosts(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "Ceasefire talks resume", Subreddit: "worldnews", Score: 10, CreatedAt: 100, Sentiment: 0.4, Topics: []string{"Ceasefire"}},
+		reddit.Post{ID: "b", Title: "Election results", Subreddit: "politics", Score: 50, CreatedAt: 200, Sentiment: -0.2, Topics: []string{"Election"}, Language: "en", Toxicity: 0.8},
+		reddit.Post{ID: "c", Title: "Markets rally", Subreddit: "News", Score: 30, CreatedAt: 300, Sentiment: 0.8, Topics: []string{"Markets"}, Language: "de", Unsupported: true},
+		reddit.Post{ID: "d", Title: "Another ceasefire", Body: "100% confirmed", Subreddit: "worldnews", Score: 5, CreatedAt: 400, Sentiment: -0.9, Topics: []string{"Ceasefire"}},
+	)
+
+	minScore := int32(10)
+	minSentiment := 0.0
+	minToxicity := reddit.ToxicThreshold
+	cases := []struct {
+		name   string
+		filter PostFilter
+		want   []string
+	}{
+		{"newest first", PostFilter{}, []string{"d", "c", "b", "a"}},
+		{"subreddit is case-insensitive", PostFilter{Subreddits: []string{"news", "POLITICS"}}, []string{"c", "b"}},
+		{"topic", PostFilter{Topic: "Ceasefire"}, []string{"d", "a"}},
+		{"text", PostFilter{Text: "CEASEFIRE"}, []string{"d", "a"}},
+		{"language", PostFilter{Languages: []string{"DE", "fr"}}, []string{"c"}},
+		{"min toxicity", PostFilter{MinToxicity: &minToxicity}, []string{"b"}},
+		{"text matches literal percent", PostFilter{Text: "100%"}, []string{"d"}},
+		{"min score by score", PostFilter{MinScore: &minScore, SortBy: SortByScore}, []string{"b", "c", "a"}},
+		{"sentiment ascending", PostFilter{MinSentiment: &minSentiment, SortBy: SortBySentiment, Ascending: true}, []string{"a", "c"}},
+	}
+
+	for _, tc := range cases {
+		page, err := store.QueryPosts(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: query failed: %v", tc.name, err)
+		}
+		got := postIDs(page.Posts)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSQLiteStorePagination(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	// Equal scores force the keyset to fall back to the ID tiebreaker
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "news", Score: 1, CreatedAt: 1},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "news", Score: 1, CreatedAt: 2},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "news", Score: 1, CreatedAt: 3},
+		reddit.Post{ID: "d", Title: "D", Subreddit: "news", Score: 2, CreatedAt: 4},
+		reddit.Post{ID: "e", Title: "E", Subreddit: "news", Score: 2, CreatedAt: 5},
+	)
+
+	var seen []string
+	filter := PostFilter{SortBy: SortByScore, Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Pagination did not terminate")
+		}
+		page, err := store.QueryPosts(ctx, filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		seen = append(seen, postIDs(page.Posts)...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	want := []string{"e", "d", "c", "b", "a"}
+	if len(seen) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, seen)
+		}
+	}
+}
+
+func TestSQLiteMigrationsRollBack(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(store.db, DriverSQLite)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	latest := len(migrator.migrations)
+	if version, err := migrator.Version(ctx); err != nil || version != latest {
+		t.Fatalf("Expected version %d, got %d (%v)", latest, version, err)
+	}
+
+	if _, err := migrator.Down(ctx, latest); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if version, _ := migrator.Version(ctx); version != 0 {
+		t.Errorf("Expected version 0 after rollback, got %d", version)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || applied != latest {
+		t.Fatalf("Expected %d migrations reapplied, got %d (%v)", latest, applied, err)
+	}
+}
+
+func TestSQLiteStoreSearchPosts(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "Ceasefire agreed in Gaza", Body: "Both sides accepted the terms.", Subreddit: "worldnews", Score: 10, CreatedAt: 100},
+		reddit.Post{ID: "b", Title: "Markets rally", Body: "Investors cheered news of a ceasefire.", Subreddit: "news", Score: 50, CreatedAt: 200},
+		reddit.Post{ID: "c", Title: "Peace talks stall", Body: "No ceasefire yet, officials say.", Subreddit: "worldnews", Score: 30, CreatedAt: 300},
+		reddit.Post{ID: "d", Title: "Sanctions announced", Body: "New sanctioning rules take effect.", Subreddit: "politics", Score: 5, CreatedAt: 400},
+	)
+
+	results, err := store.SearchPosts(ctx, SearchQuery{Text: "ceasefire"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	// A title match outranks body matches
+	if results[0].Post.ID != "a" {
+		t.Errorf("Expected title match first, got %s", results[0].Post.ID)
+	}
+	for _, r := range results {
+		if !strings.Contains(r.Snippet, HighlightStart+"Ceasefire"+HighlightStop) &&
+			!strings.Contains(r.Snippet, HighlightStart+"ceasefire"+HighlightStop) {
+			t.Errorf("Expected highlighted snippet, got %q", r.Snippet)
+		}
+	}
+
+	cases := []struct {
+		query SearchQuery
+		want  []string
+	}{
+		{SearchQuery{Text: `"peace talks"`}, []string{"c"}},
+		{SearchQuery{Text: `ceasefire -"peace talks"`}, []string{"a", "b"}},
+		{SearchQuery{Text: "sanction*"}, []string{"d"}},
+		{SearchQuery{Text: "ceasefire", Filter: PostFilter{Subreddits: []string{"worldnews"}, Since: time.Unix(200, 0)}}, []string{"c"}},
+		{SearchQuery{Text: "ceasefire", Limit: 1, Offset: 1}, nil},
+	}
+	for _, tc := range cases {
+		results, err := store.SearchPosts(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("%q: search failed: %v", tc.query.Text, err)
+		}
+		if tc.want == nil {
+			if len(results) != 1 {
+				t.Errorf("%q: expected one result at offset 1, got %d", tc.query.Text, len(results))
+			}
+			continue
+		}
+		got := make([]string, len(results))
+		for i, r := range results {
+			got[i] = r.Post.ID
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%q: expected %v, got %v", tc.query.Text, tc.want, got)
+		}
+	}
+}
+
+func TestSQLiteStoreMaintain(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	seedPosts(t, store,
+		reddit.Post{ID: "old", Title: "Old", Subreddit: "news", CreatedAt: float64(now.Add(-40 * day).Unix())},
+		reddit.Post{ID: "new", Title: "New", Subreddit: "news", CreatedAt: float64(now.Add(-time.Hour).Unix())},
+	)
+
+	// Keeping posts forever is a no-op
+	report, err := store.Maintain(ctx, RetentionPolicy{}, now)
+	if err != nil || report.PostsExpired != 0 {
+		t.Fatalf("Expected nothing to expire, got %+v (%v)", report, err)
+	}
+
+	archiveDir := t.TempDir()
+	report, err = store.Maintain(ctx, RetentionPolicy{KeepFor: 30 * day, ArchiveDir: archiveDir}, now)
+	if err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	if report.PostsExpired != 1 || len(report.ArchiveFiles) != 1 {
+		t.Fatalf("Expected one archived post, got %+v", report)
+	}
+
+	if _, err := store.GetPost(ctx, "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected expired post to be deleted, got %v", err)
+	}
+	if _, err := store.GetPost(ctx, "new"); err != nil {
+		t.Errorf("Expected recent post to be kept, got %v", err)
+	}
+
+	f, err := os.Open(report.ArchiveFiles[0])
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	var archived reddit.Post
+	if err := json.NewDecoder(gz).Decode(&archived); err != nil || archived.ID != "old" {
+		t.Errorf("Expected archived post, got %+v (%v)", archived, err)
+	}
+
+	// A second pass has nothing left to archive and writes no file
+	report, err = store.Maintain(ctx, RetentionPolicy{KeepFor: 30 * day, ArchiveDir: archiveDir}, now)
+	if err != nil || report.PostsExpired != 0 || len(report.ArchiveFiles) != 0 {
+		t.Errorf("Expected an empty second pass, got %+v (%v)", report, err)
+	}
+}
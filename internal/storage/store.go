@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goreddit/internal/config"
+	"goreddit/internal/reddit"
+	"log"
+	"time"
+)
+
+// Store persists enriched posts and serves queries over them
+type Store interface {
+	SavePost(ctx context.Context, post reddit.Post) error
+	GetPost(ctx context.Context, id string) (reddit.Post, error)
+	QueryPosts(ctx context.Context, filter PostFilter) (PostPage, error)
+	SearchPosts(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+	// TimeSeries reads post counts, average sentiment, emotions and toxicity per bucket from the rollups
+	TimeSeries(ctx context.Context, query SeriesQuery) ([]SeriesPoint, error)
+	// TopTopics reads the most frequent topics over a window from the rollups
+	TopTopics(ctx context.Context, query TopTopicsQuery) ([]reddit.TopicCount, error)
+	// TopEntities reads the most mentioned entities over a window from the rollups
+	TopEntities(ctx context.Context, query TopEntitiesQuery) ([]reddit.EntityCount, error)
+	// TopDomains reads the most linked domains over a window from the rollups
+	TopDomains(ctx context.Context, query TopDomainsQuery) ([]DomainStats, error)
+	// TopSubreddits reads the subreddits with the most posts over a window from the rollups
+	TopSubreddits(ctx context.Context, query TopSubredditsQuery) ([]SubredditStats, error)
+	// ListStories returns groups of posts about the same story
+	ListStories(ctx context.Context, query StoryQuery) ([]Story, error)
+	// PostStory returns the story a post belongs to in storage, or "" if none
+	PostStory(ctx context.Context, post reddit.Post, window time.Duration) (string, error)
+	// GetStory returns a story with its per-subreddit breakdown, or ErrNotFound
+	GetStory(ctx context.Context, id string) (Story, error)
+	// ListAlertRules returns the alert rules created through the API
+	ListAlertRules(ctx context.Context) ([]AlertRule, error)
+	// SaveAlertRule creates or replaces an alert rule
+	SaveAlertRule(ctx context.Context, rule AlertRule) error
+	// DeleteAlertRule removes an alert rule, or returns ErrNotFound
+	DeleteAlertRule(ctx context.Context, id string) error
+	// ClaimAlert reports whether a rule should fire for a key, recording it if so
+	ClaimAlert(ctx context.Context, claim AlertClaim) (bool, error)
+	// Maintain prepares upcoming storage and removes posts past retention
+	Maintain(ctx context.Context, policy RetentionPolicy, now time.Time) (MaintenanceReport, error)
+	Close() error
+}
+
+// New creates the store selected by storage.driver in the config
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.Storage.Driver {
+	case "", DriverPostgres:
+		return NewPostgresStore(cfg)
+	case DriverSQLite:
+		return NewSQLiteStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// Open opens the configured database without touching the schema
+func Open(cfg *config.Config) (*sql.DB, error) {
+	switch cfg.Storage.Driver {
+	case "", DriverPostgres:
+		return OpenPostgres(cfg)
+	case DriverSQLite:
+		return OpenSQLite(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// sqlStore implements Store over database/sql. PostgresStore and SQLiteStore
+// embed it and add whatever their database does differently.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// newSQLStore applies pending migrations to db and wraps it in a sqlStore
+func newSQLStore(db *sql.DB, d dialect) (sqlStore, error) {
+	migrator, err := newMigrator(db, d)
+	if err != nil {
+		return sqlStore{}, err
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return sqlStore{}, fmt.Errorf("failed to migrate %s: %w", d.name, err)
+	}
+	if applied > 0 {
+		log.Printf("Applied %d %s schema migration(s)", applied, d.name)
+	}
+
+	return sqlStore{db: db, dialect: d}, nil
+}
+
+// SavePost upserts a post and moves its contribution to the rollups from the
+// previously stored version, if any, to the new one
+func (s *sqlStore) SavePost(ctx context.Context, post reddit.Post) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin save transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.dialect.lockPost != "" {
+		if _, err := tx.ExecContext(ctx, s.dialect.lockPost, post.ID); err != nil {
+			return fmt.Errorf("failed to lock post: %w", err)
+		}
+	}
+
+	previous, err := s.scanPost(tx.QueryRowContext(ctx,
+		"SELECT "+s.dialect.postColumns+" FROM reddit_posts WHERE id = $1", post.ID))
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read previous post: %w", err)
+	}
+	if exists {
+		entities, err := s.loadEntities(ctx, tx, []string{post.ID})
+		if err != nil {
+			return err
+		}
+		previous.Entities = entities[post.ID]
+		// Both consumers cluster posts, so the first story saved for a post
+		// stands and a later one doesn't leave a story row behind
+		if previous.StoryID != "" {
+			post.StoryID = previous.StoryID
+		}
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO reddit_posts (
+			id, title, body, subreddit, score, url, created_at, sentiment, topics, story_id,
+			language, unsupported, domain, summary, toxicity, sentiment_model, analyzer_versions, unscored, %s
+		) VALUES ($1, $2, $3, $4, $5, $6, %s, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+		ON CONFLICT (%s) DO UPDATE SET
+			score = EXCLUDED.score,
+			summary = EXCLUDED.summary,
+			domain = COALESCE(EXCLUDED.domain, reddit_posts.domain),
+			sentiment = EXCLUDED.sentiment,
+			toxicity = EXCLUDED.toxicity,
+			sentiment_model = EXCLUDED.sentiment_model,
+			analyzer_versions = EXCLUDED.analyzer_versions,
+			emotion_anger = EXCLUDED.emotion_anger,
+			emotion_anticipation = EXCLUDED.emotion_anticipation,
+			emotion_disgust = EXCLUDED.emotion_disgust,
+			emotion_fear = EXCLUDED.emotion_fear,
+			emotion_joy = EXCLUDED.emotion_joy,
+			emotion_sadness = EXCLUDED.emotion_sadness,
+			emotion_surprise = EXCLUDED.emotion_surprise,
+			emotion_trust = EXCLUDED.emotion_trust,
+			topics = EXCLUDED.topics,
+			story_id = COALESCE(reddit_posts.story_id, EXCLUDED.story_id),
+			language = EXCLUDED.language,
+			unsupported = EXCLUDED.unsupported,
+			unscored = EXCLUDED.unscored
+	`, emotionColumns, s.dialect.fromUnix("$7"), s.dialect.postKey)
+
+	args := []any{
+		post.ID,
+		post.Title,
+		post.Body,
+		post.Subreddit,
+		post.Score,
+		post.URL,
+		post.CreatedAt,
+		post.Sentiment,
+		s.dialect.topicsArg(post.Topics),
+		nullString(post.StoryID),
+		nullString(post.Language),
+		post.Unsupported,
+		nullString(post.Domain),
+		nullString(post.Summary),
+		post.Toxicity,
+		nullString(post.SentimentModel),
+		nullString(post.AnalyzerVersions),
+		post.Unscored,
+	}
+	for _, value := range post.Emotions.Fields() {
+		args = append(args, *value)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save post: %w", err)
+	}
+	if err := s.saveEntities(ctx, tx, post); err != nil {
+		return err
+	}
+	if err := s.saveStory(ctx, tx, post); err != nil {
+		return err
+	}
+
+	// The upsert keeps the stored subreddit and creation time, and the stored
+	// domain unless there's a new one, so only sentiment, emotions, toxicity,
+	// whether they were analyzed, topics, entities and domain can move a post
+	// between rollups
+	current := post
+	if exists {
+		current.Subreddit = previous.Subreddit
+		current.CreatedAt = previous.CreatedAt
+		if current.Domain == "" {
+			current.Domain = previous.Domain
+		}
+	}
+	if !exists || !sameRollups(previous, current) {
+		if exists {
+			if err := s.applyRollups(ctx, tx, previous, -1); err != nil {
+				return err
+			}
+		}
+		if err := s.applyRollups(ctx, tx, current, 1); err != nil {
+			return err
+		}
+	} else if previous.Score != current.Score {
+		// Only the domain rollups sum scores
+		if err := s.applyDomainRollups(ctx, tx, previous, -1); err != nil {
+			return err
+		}
+		if err := s.applyDomainRollups(ctx, tx, current, 1); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit post: %w", err)
+	}
+	return nil
+}
+
+// GetPost returns a single post by ID, or ErrNotFound
+func (s *sqlStore) GetPost(ctx context.Context, id string) (reddit.Post, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+s.dialect.postColumns+" FROM reddit_posts WHERE id = $1", id)
+
+	post, err := s.scanPost(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reddit.Post{}, ErrNotFound
+	}
+	if err != nil {
+		return reddit.Post{}, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	posts := []reddit.Post{post}
+	if err := s.attachEntities(ctx, posts); err != nil {
+		return reddit.Post{}, err
+	}
+	return posts[0], nil
+}
+
+// QueryPosts returns one page of posts matching the filter
+func (s *sqlStore) QueryPosts(ctx context.Context, filter PostFilter) (PostPage, error) {
+	filter, err := filter.normalize()
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	query, args, err := buildPostQuery(s.dialect, filter)
+	if err != nil {
+		return PostPage{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return PostPage{}, fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	var page PostPage
+	for rows.Next() {
+		post, err := s.scanPost(rows)
+		if err != nil {
+			return PostPage{}, fmt.Errorf("failed to scan post: %w", err)
+		}
+		page.Posts = append(page.Posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return PostPage{}, fmt.Errorf("failed to query posts: %w", err)
+	}
+
+	if len(page.Posts) > filter.Limit {
+		page.Posts = page.Posts[:filter.Limit]
+		page.NextCursor = nextCursor(filter.SortBy, page.Posts[len(page.Posts)-1])
+	}
+
+	if err := s.attachEntities(ctx, page.Posts); err != nil {
+		return PostPage{}, err
+	}
+	return page, nil
+}
+
+// searchRows runs a search query whose rows are the post columns, rank and snippet
+func (s *sqlStore) searchRows(ctx context.Context, query string, args []any) ([]SearchResult, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		result.Post, err = s.scanPost(rows, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+
+	posts := make([]reddit.Post, len(results))
+	for i, result := range results {
+		posts[i] = result.Post
+	}
+	if err := s.attachEntities(ctx, posts); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Post.Entities = posts[i].Entities
+	}
+	return results, nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost scans a row selected with the dialect's postColumns, followed by
+// any extra columns
+func (s *sqlStore) scanPost(row rowScanner, extra ...any) (reddit.Post, error) {
+	var (
+		post                                       reddit.Post
+		body, summary, url, domain, storyID        sql.NullString
+		language, sentimentModel, analyzerVersions sql.NullString
+		sentiment                                  sql.NullFloat64
+	)
+
+	dest := []any{
+		&post.ID,
+		&post.Title,
+		&body,
+		&summary,
+		&post.Subreddit,
+		&post.Score,
+		&url,
+		&domain,
+		&post.CreatedAt,
+		&sentiment,
+		s.dialect.topicsDest(&post.Topics),
+		&storyID,
+		&language,
+		&post.Unsupported,
+		&post.Unscored,
+		&post.Toxicity,
+		&sentimentModel,
+		&analyzerVersions,
+	}
+	for _, value := range post.Emotions.Fields() {
+		dest = append(dest, value)
+	}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return reddit.Post{}, err
+	}
+
+	post.Body = body.String
+	post.Summary = summary.String
+	post.URL = url.String
+	post.Domain = domain.String
+	post.Sentiment = sentiment.Float64
+	post.StoryID = storyID.String
+	post.Language = language.String
+	post.SentimentModel = sentimentModel.String
+	post.AnalyzerVersions = analyzerVersions.String
+	return post, nil
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
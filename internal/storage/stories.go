@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goreddit/internal/reddit"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultStoryMinPosts = 2
+
+// Story is a group of posts about the same story, like one article shared to
+// several subreddits
+type Story struct {
+	ID    string `json:"id"`
+	Title string `json:"title"` // of the post that started the story
+	URL   string `json:"url,omitempty"`
+	Posts int    `json:"posts"`
+	// FirstPost and LastPost are when the story's first and latest posts were created
+	FirstPost time.Time `json:"first_post"`
+	LastPost  time.Time `json:"last_post"`
+	// Subreddits compares how each subreddit covering the story felt about it,
+	// most posts first
+	Subreddits []StorySubreddit `json:"subreddits"`
+	// SentimentSpread is the gap between the most positive and most negative
+	// subreddit's average sentiment
+	SentimentSpread float64 `json:"sentiment_spread"`
+}
+
+// StorySubreddit is one subreddit's share of a story
+type StorySubreddit struct {
+	Subreddit    string  `json:"subreddit"`
+	Posts        int     `json:"posts"`
+	AvgSentiment float64 `json:"avg_sentiment"`
+}
+
+// StoryQuery selects stories for ListStories. Stories are ordered by their
+// latest post, newest first.
+type StoryQuery struct {
+	Subreddit string    // stories with at least one post here
+	Since     time.Time // stories with a post at or after this, inclusive
+	Until     time.Time // and before this, exclusive
+	MinPosts  int       // defaults to 2, so that single posts aren't listed
+	Limit     int       // defaults to 50, capped at 500
+}
+
+// saveStory records the post's story, keeping the title and link of the
+// first post saved for it
+func (s *sqlStore) saveStory(ctx context.Context, tx *sql.Tx, post reddit.Post) error {
+	if post.StoryID == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO stories (id, title, url, created_at) VALUES ($1, $2, $3, %s)
+		ON CONFLICT (id) DO NOTHING`, s.dialect.fromUnix("$4")),
+		post.StoryID, post.Title, nullString(post.URL), post.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save story: %w", err)
+	}
+	return nil
+}
+
+// PostStory returns the story a post belongs to in storage: the stored
+// post's own or, for a post not stored yet, that of the earliest post linking
+// to the same URL within window of it. Consumers settle their clustering with
+// it, so that each keeps to the stories the other saved first.
+func (s *sqlStore) PostStory(ctx context.Context, post reddit.Post, window time.Duration) (string, error) {
+	var storyID sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT story_id FROM reddit_posts WHERE id = $1", post.ID).Scan(&storyID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to read post story: %w", err)
+	}
+	if storyID.Valid || post.URL == "" {
+		return storyID.String, nil
+	}
+
+	at := time.Unix(int64(post.CreatedAt), 0)
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT story_id FROM reddit_posts
+		WHERE url = $1 AND story_id IS NOT NULL AND id <> $2
+			AND created_at >= %s AND created_at <= %s
+		ORDER BY created_at, id LIMIT 1`, s.dialect.fromUnix("$3"), s.dialect.fromUnix("$4")),
+		post.URL, post.ID, at.Add(-window).Unix(), at.Add(window).Unix()).Scan(&storyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find story by URL: %w", err)
+	}
+	return storyID.String, nil
+}
+
+// deleteOrphanStories removes stories none of whose posts are left
+func (s *sqlStore) deleteOrphanStories(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM stories
+		WHERE NOT EXISTS (SELECT 1 FROM reddit_posts WHERE reddit_posts.story_id = stories.id)`)
+	if err != nil {
+		return fmt.Errorf("failed to delete stories of expired posts: %w", err)
+	}
+	return nil
+}
+
+// ListStories returns the stories matching the query with their
+// per-subreddit breakdown
+func (s *sqlStore) ListStories(ctx context.Context, q StoryQuery) ([]Story, error) {
+	if q.MinPosts <= 0 {
+		q.MinPosts = defaultStoryMinPosts
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultQueryLimit
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Until.After(q.Since) {
+		return nil, fmt.Errorf("%w: until must be after since", ErrInvalidFilter)
+	}
+
+	// Stories with a post in the window (and subreddit) are listed with all
+	// of their posts
+	b := &queryBuilder{}
+	b.where("story_id IS NOT NULL")
+	if q.Subreddit != "" {
+		b.where("LOWER(subreddit) = " + b.arg(strings.ToLower(q.Subreddit)))
+	}
+	if !q.Since.IsZero() {
+		b.where("created_at >= " + s.dialect.fromUnix(b.arg(float64(q.Since.Unix()))))
+	}
+	if !q.Until.IsZero() {
+		b.where("created_at < " + s.dialect.fromUnix(b.arg(float64(q.Until.Unix()))))
+	}
+
+	created := s.dialect.toUnix("reddit_posts.created_at")
+	query := fmt.Sprintf(`
+		SELECT stories.id, stories.title, stories.url, COUNT(*), MIN(%[1]s), MAX(%[1]s)
+		FROM stories JOIN reddit_posts ON reddit_posts.story_id = stories.id
+		WHERE stories.id IN (SELECT DISTINCT story_id FROM reddit_posts%[2]s)
+		GROUP BY stories.id, stories.title, stories.url
+		HAVING COUNT(*) >= %[3]s
+		ORDER BY MAX(%[1]s) DESC, stories.id
+		LIMIT %[4]s`, created, b.whereClause(), b.arg(q.MinPosts), b.arg(q.Limit))
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stories: %w", err)
+	}
+	defer rows.Close()
+
+	stories := []Story{}
+	for rows.Next() {
+		story, err := scanStory(rows)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stories: %w", err)
+	}
+
+	if err := s.attachStorySubreddits(ctx, stories); err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+// GetStory returns a single story by ID, or ErrNotFound
+func (s *sqlStore) GetStory(ctx context.Context, id string) (Story, error) {
+	created := s.dialect.toUnix("reddit_posts.created_at")
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT stories.id, stories.title, stories.url, COUNT(*), MIN(%[1]s), MAX(%[1]s)
+		FROM stories JOIN reddit_posts ON reddit_posts.story_id = stories.id
+		WHERE stories.id = $1
+		GROUP BY stories.id, stories.title, stories.url`, created), id)
+
+	story, err := scanStory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Story{}, ErrNotFound
+	}
+	if err != nil {
+		return Story{}, err
+	}
+
+	stories := []Story{story}
+	if err := s.attachStorySubreddits(ctx, stories); err != nil {
+		return Story{}, err
+	}
+	return stories[0], nil
+}
+
+func scanStory(row rowScanner) (Story, error) {
+	var (
+		story       Story
+		url         sql.NullString
+		first, last float64
+	)
+	if err := row.Scan(&story.ID, &story.Title, &url, &story.Posts, &first, &last); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Story{}, err
+		}
+		return Story{}, fmt.Errorf("failed to scan story: %w", err)
+	}
+	story.URL = url.String
+	story.FirstPost = time.Unix(int64(first), 0).UTC()
+	story.LastPost = time.Unix(int64(last), 0).UTC()
+	return story, nil
+}
+
+// attachStorySubreddits fills in the per-subreddit breakdown of stories
+func (s *sqlStore) attachStorySubreddits(ctx context.Context, stories []Story) error {
+	if len(stories) == 0 {
+		return nil
+	}
+
+	b := &queryBuilder{}
+	placeholders := make([]string, len(stories))
+	index := make(map[string]int, len(stories))
+	for i, story := range stories {
+		placeholders[i] = b.arg(story.ID)
+		index[story.ID] = i
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT story_id, LOWER(subreddit), COUNT(*), AVG(COALESCE(sentiment, 0))
+		FROM reddit_posts
+		WHERE story_id IN (%s)
+		GROUP BY story_id, LOWER(subreddit)`, strings.Join(placeholders, ", ")), b.args...)
+	if err != nil {
+		return fmt.Errorf("failed to query story subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var sub StorySubreddit
+		if err := rows.Scan(&id, &sub.Subreddit, &sub.Posts, &sub.AvgSentiment); err != nil {
+			return fmt.Errorf("failed to scan story subreddit: %w", err)
+		}
+		i := index[id]
+		stories[i].Subreddits = append(stories[i].Subreddits, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query story subreddits: %w", err)
+	}
+
+	for i := range stories {
+		subs := stories[i].Subreddits
+		sort.Slice(subs, func(a, b int) bool {
+			if subs[a].Posts != subs[b].Posts {
+				return subs[a].Posts > subs[b].Posts
+			}
+			return subs[a].Subreddit < subs[b].Subreddit
+		})
+		lowest, highest := 0.0, 0.0
+		for j, sub := range subs {
+			if j == 0 || sub.AvgSentiment < lowest {
+				lowest = sub.AvgSentiment
+			}
+			if j == 0 || sub.AvgSentiment > highest {
+				highest = sub.AvgSentiment
+			}
+		}
+		stories[i].SentimentSpread = highest - lowest
+	}
+	return nil
+}
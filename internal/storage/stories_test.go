@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goreddit/internal/reddit"
+)
+
+func TestSQLiteStoreStories(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) float64 { return float64(base.Add(d).Unix()) }
+
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "Quake hits Japan", URL: "https://apnews.com/quake", Subreddit: "WorldNews", CreatedAt: at(0), Sentiment: -0.5, StoryID: "a"},
+		reddit.Post{ID: "b", Title: "Japan quake", Subreddit: "news", CreatedAt: at(time.Hour), Sentiment: -1, StoryID: "a"},
+		reddit.Post{ID: "c", Title: "Japan earthquake", Subreddit: "worldnews", CreatedAt: at(2 * time.Hour), Sentiment: 0.5, StoryID: "a"},
+		reddit.Post{ID: "d", Title: "Budget passes", Subreddit: "politics", CreatedAt: at(3 * time.Hour), StoryID: "d"},
+		reddit.Post{ID: "e", Title: "No story", Subreddit: "politics", CreatedAt: at(4 * time.Hour)},
+	)
+
+	stories, err := store.ListStories(ctx, StoryQuery{})
+	if err != nil {
+		t.Fatalf("ListStories failed: %v", err)
+	}
+	if len(stories) != 1 {
+		t.Fatalf("Expected only the story with several posts, got %+v", stories)
+	}
+	story := stories[0]
+	if story.ID != "a" || story.Title != "Quake hits Japan" || story.URL != "https://apnews.com/quake" || story.Posts != 3 {
+		t.Errorf("Unexpected story: %+v", story)
+	}
+	if !story.FirstPost.Equal(base) || !story.LastPost.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("Unexpected story window: %v - %v", story.FirstPost, story.LastPost)
+	}
+	wantSubs := []StorySubreddit{
+		{Subreddit: "worldnews", Posts: 2, AvgSentiment: 0},
+		{Subreddit: "news", Posts: 1, AvgSentiment: -1},
+	}
+	if !reflect.DeepEqual(story.Subreddits, wantSubs) || story.SentimentSpread != 1 {
+		t.Errorf("Unexpected subreddit comparison: %+v (spread %v)", story.Subreddits, story.SentimentSpread)
+	}
+
+	// A story is listed with all its posts when any of them matches
+	stories, err = store.ListStories(ctx, StoryQuery{Subreddit: "news", Since: base.Add(30 * time.Minute), MinPosts: 1})
+	if err != nil {
+		t.Fatalf("ListStories failed: %v", err)
+	}
+	if len(stories) != 1 || stories[0].ID != "a" || stories[0].Posts != 3 {
+		t.Errorf("Unexpected stories for r/news: %+v", stories)
+	}
+
+	got, err := store.GetStory(ctx, "d")
+	if err != nil || got.Posts != 1 || len(got.Subreddits) != 1 {
+		t.Errorf("Unexpected single-post story: %+v (%v)", got, err)
+	}
+	if _, err := store.GetStory(ctx, "e"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	page, err := store.QueryPosts(ctx, PostFilter{StoryID: "a", Ascending: true})
+	if err != nil {
+		t.Fatalf("QueryPosts failed: %v", err)
+	}
+	if ids := postIDs(page.Posts); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) || page.Posts[1].StoryID != "a" {
+		t.Errorf("Expected the story's posts, got %+v", page.Posts)
+	}
+
+	// Re-saving a post without a story keeps the one it has
+	seedPosts(t, store, reddit.Post{ID: "b", Title: "Japan quake", Subreddit: "news", CreatedAt: at(time.Hour), Score: 10})
+	if post, _ := store.GetPost(ctx, "b"); post.StoryID != "a" {
+		t.Errorf("Expected post b to stay in story a, got %q", post.StoryID)
+	}
+
+	// Stories go once their posts have expired
+	if _, err := store.Maintain(ctx, RetentionPolicy{KeepFor: time.Hour}, base.Add(3*time.Hour+30*time.Minute)); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	if _, err := store.GetStory(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected story a to be removed with its posts, got %v", err)
+	}
+	if _, err := store.GetStory(ctx, "d"); err != nil {
+		t.Errorf("Expected story d to be kept, got %v", err)
+	}
+}
+
+func TestSQLiteStoreSettlesStories(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) float64 { return float64(base.Add(d).Unix()) }
+	url := "https://apnews.com/quake"
+
+	seedPosts(t, store, reddit.Post{ID: "a", Title: "Quake hits Japan", URL: url, Subreddit: "worldnews", CreatedAt: at(0), StoryID: "a"})
+
+	// A new post linking to the same URL joins its story, within the window
+	cases := []struct {
+		post reddit.Post
+		want string
+	}{
+		{reddit.Post{ID: "b", URL: url, CreatedAt: at(time.Hour)}, "a"},
+		{reddit.Post{ID: "c", URL: url, CreatedAt: at(72 * time.Hour)}, ""},
+		{reddit.Post{ID: "d", URL: "https://apnews.com/budget", CreatedAt: at(time.Hour)}, ""},
+		{reddit.Post{ID: "a", URL: url, CreatedAt: at(0)}, "a"},
+	}
+	for _, c := range cases {
+		got, err := store.PostStory(ctx, c.post, 48*time.Hour)
+		if err != nil {
+			t.Fatalf("PostStory failed: %v", err)
+		}
+		if got != c.want {
+			t.Errorf("Expected post %s in story %q, got %q", c.post.ID, c.want, got)
+		}
+	}
+
+	// The other consumer saving the post with a story of its own changes neither
+	// the post's story nor the stories there are
+	if err := store.SavePost(ctx, reddit.Post{ID: "a", Title: "Quake hits Japan", URL: url, Subreddit: "worldnews", CreatedAt: at(0), StoryID: "other"}); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+	got, err := store.GetPost(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetPost failed: %v", err)
+	}
+	if got.StoryID != "a" {
+		t.Errorf("Expected the first story saved to stand, got %q", got.StoryID)
+	}
+	if _, err := store.GetStory(ctx, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected no story row for the later story, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TopSubredditsQuery selects a window of the subreddit rollups
+type TopSubredditsQuery struct {
+	Since time.Time // inclusive, required
+	Until time.Time // exclusive, defaults to now
+	Limit int       // defaults to 50, capped at 500
+}
+
+// SubredditStats sums up the posts of one subreddit
+type SubredditStats struct {
+	Subreddit string  `json:"subreddit"`
+	Posts     int     `json:"posts"`
+	Share     float64 `json:"share"` // of the window's posts
+	// AvgSentiment is of the posts whose sentiment was analyzed, and
+	// AvgToxicity and ToxicShare of the posts whose toxicity was scored
+	AvgSentiment float64 `json:"avg_sentiment"`
+	AvgToxicity  float64 `json:"avg_toxicity"`
+	ToxicShare   float64 `json:"toxic_share"`
+}
+
+// TopSubreddits reads the subreddits with the most posts over a window from
+// the rollups
+func (s *sqlStore) TopSubreddits(ctx context.Context, q TopSubredditsQuery) ([]SubredditStats, error) {
+	b := &queryBuilder{}
+	limit, err := s.rollupWindow(b, "", q.Since, q.Until, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT subreddit, SUM(post_count) AS total, SUM(analyzed_count), SUM(scored_count),
+			SUM(sentiment_sum), SUM(toxicity_sum), SUM(toxic_count),
+			SUM(SUM(post_count)) OVER ()
+		FROM subreddit_rollups%s
+		GROUP BY subreddit
+		HAVING SUM(post_count) > 0
+		ORDER BY total DESC, subreddit
+		LIMIT %s`, b.whereClause(), b.arg(limit))
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	subreddits := []SubredditStats{}
+	for rows.Next() {
+		var (
+			ss                           SubredditStats
+			sentSum, toxSum, allPosts    float64
+			analyzed, scored, toxicCount int
+		)
+		if err := rows.Scan(&ss.Subreddit, &ss.Posts, &analyzed, &scored, &sentSum, &toxSum, &toxicCount, &allPosts); err != nil {
+			return nil, fmt.Errorf("failed to scan top subreddits: %w", err)
+		}
+		ss.AvgSentiment = average(sentSum, analyzed)
+		ss.AvgToxicity = average(toxSum, scored)
+		ss.ToxicShare = average(float64(toxicCount), scored)
+		if allPosts > 0 {
+			ss.Share = float64(ss.Posts) / allPosts
+		}
+		subreddits = append(subreddits, ss)
+	}
+	return subreddits, rows.Err()
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"goreddit/internal/reddit"
+)
+
+func TestSQLiteStoreTopSubreddits(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) float64 { return float64(base.Add(d).Unix()) }
+	seedPosts(t, store,
+		reddit.Post{ID: "a", Title: "A", Subreddit: "WorldNews", Sentiment: -1, Toxicity: 0.75, CreatedAt: at(time.Hour)},
+		reddit.Post{ID: "b", Title: "B", Subreddit: "worldnews", Sentiment: 0.5, Toxicity: 0.25, CreatedAt: at(2 * time.Hour)},
+		reddit.Post{ID: "c", Title: "C", Subreddit: "politics", Sentiment: 0.5, CreatedAt: at(3 * time.Hour)},
+		reddit.Post{ID: "d", Title: "D", Subreddit: "news", CreatedAt: at(2 * day)},
+	)
+
+	subreddits, err := store.TopSubreddits(ctx, TopSubredditsQuery{Since: base, Until: base.Add(day)})
+	if err != nil {
+		t.Fatalf("TopSubreddits failed: %v", err)
+	}
+	want := []SubredditStats{
+		{Subreddit: "worldnews", Posts: 2, Share: 2.0 / 3, AvgSentiment: -0.25, AvgToxicity: 0.5, ToxicShare: 0.5},
+		{Subreddit: "politics", Posts: 1, Share: 1.0 / 3, AvgSentiment: 0.5},
+	}
+	if !reflect.DeepEqual(subreddits, want) {
+		t.Errorf("Expected %+v, got %+v", want, subreddits)
+	}
+
+	subreddits, err = store.TopSubreddits(ctx, TopSubredditsQuery{Since: base, Until: base.Add(day), Limit: 1})
+	if err != nil || len(subreddits) != 1 || subreddits[0].Subreddit != "worldnews" {
+		t.Errorf("Expected only worldnews, got %+v (%v)", subreddits, err)
+	}
+}